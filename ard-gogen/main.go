@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tliron/go-ard"
+	"github.com/tliron/go-ard/ardcmd"
+)
+
+// Usage: ard-gogen (-schema schema.yaml | -infer example.yaml) [-package name] [-type name] [-tag name] [-output file.go]
+//
+// A schema file is a map of paths to type names, e.g. "servers[*].port: ard.integer".
+//
+// For use with go:generate:
+//
+//	//go:generate go run github.com/tliron/go-ard/ard-gogen -schema schema.yaml -package config -type Config -output config_gen.go
+func main() {
+	schemaPath := flag.String("schema", "", "schema file (map of paths to type names)")
+	inferPath := flag.String("infer", "", "example file from which to infer the schema")
+	package_ := flag.String("package", "", "package name (default \"main\")")
+	typeName := flag.String("type", "", "root type name (default \"Root\")")
+	tag := flag.String("tag", "", "struct field tag (default \"ard\")")
+	output := flag.String("output", "", "output file (default stdout)")
+	flag.Parse()
+
+	var schema ard.Schema
+	var err error
+	switch {
+	case (*schemaPath != "") && (*inferPath == ""):
+		schema, err = readSchema(*schemaPath)
+	case (*inferPath != "") && (*schemaPath == ""):
+		var value ard.Value
+		if value, err = ardcmd.ReadInput(context.Background(), *inferPath, ""); err == nil {
+			schema = ard.InferSchema(value)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "exactly one of -schema or -infer must be provided")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code, err := ard.GenerateGo(schema, &ard.GoGeneratorOptions{
+		Package:  *package_,
+		TypeName: *typeName,
+		Tag:      *tag,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		err = os.WriteFile(*output, code, 0644)
+	} else {
+		_, err = os.Stdout.Write(code)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func readSchema(path string) (ard.Schema, error) {
+	value, err := ardcmd.ReadInput(context.Background(), path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	map_, ok := ard.With(value).ConvertSimilar().StringMap()
+	if !ok {
+		return nil, fmt.Errorf("schema is not a map: %s", path)
+	}
+
+	schema := make(ard.Schema)
+	for pattern, type_ := range map_ {
+		if type__, ok := type_.(string); ok {
+			schema[pattern] = ard.TypeName(type__)
+		} else {
+			return nil, fmt.Errorf("schema type for %q is not a string: %s", pattern, path)
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,40 @@
+package ardcmd
+
+import (
+	contextpkg "context"
+	"fmt"
+
+	"github.com/tliron/go-ard"
+)
+
+// Reads an input and writes it to an output, possibly in a different format.
+// See [ReadInput] and [WriteOutput].
+func Convert(context contextpkg.Context, input string, inputFormat string, output string, outputFormat string) error {
+	if value, err := ReadInput(context, input, inputFormat); err == nil {
+		return WriteOutput(value, output, outputFormat, nil)
+	} else {
+		return err
+	}
+}
+
+// Reads an input and writes the value at a path in [ard.Path.String] format
+// (see [ard.Node.GetDotted]), e.g. `servers[0].name`, to an output. An empty
+// path writes the entire input. See [ReadInput] and [WriteOutput].
+//
+// Returns an error if the path is not found.
+func Query(context contextpkg.Context, input string, inputFormat string, path string, output string, outputFormat string) error {
+	value, err := ReadInput(context, input, inputFormat)
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		if node := ard.With(value).GetDotted(path); node.Exists() {
+			value = node.Value
+		} else {
+			return fmt.Errorf("path not found: %s", path)
+		}
+	}
+
+	return WriteOutput(value, output, outputFormat, nil)
+}
@@ -0,0 +1,64 @@
+package ardcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tliron/exturl"
+	"github.com/tliron/go-ard"
+)
+
+//
+// Format
+//
+
+// A command line flag value for an ARD format, accepting only
+// [ard.SupportedFormats]. The empty string means that the format should be
+// detected (see [DetectFormat]).
+//
+// Implements both [flag.Value] and pflag.Value (as used by cobra), e.g.:
+//
+//	var inputFormat ardcmd.Format
+//	flag.Var(&inputFormat, "input-format", ardcmd.FormatUsage("input format"))
+//	command.Flags().VarP(&inputFormat, "input-format", "i", ardcmd.FormatUsage("input format"))
+type Format string
+
+// ([fmt.Stringer] interface)
+func (self *Format) String() string {
+	return string(*self)
+}
+
+// ([flag.Value] interface)
+func (self *Format) Set(value string) error {
+	if (value == "") || ard.IsSupportedFormat(value) {
+		*self = Format(value)
+		return nil
+	} else {
+		return fmt.Errorf("must be one of: %s", strings.Join(ard.SupportedFormats(), ", "))
+	}
+}
+
+// (pflag.Value interface)
+func (self *Format) Type() string {
+	return "format"
+}
+
+// Returns a usage string for a [Format] flag, listing the supported formats.
+func FormatUsage(description string) string {
+	return fmt.Sprintf("%s (%s; detected from extension if empty)", description, strings.Join(ard.SupportedFormats(), ", "))
+}
+
+// Returns format if it's not empty. Otherwise attempts to detect it from the
+// extension of urlOrPath, e.g. ".yml" or ".json". If that fails returns
+// fallback.
+func DetectFormat(format string, urlOrPath string, fallback string) string {
+	if format != "" {
+		return format
+	}
+
+	if format_ := exturl.GetFormat(urlOrPath); ard.IsSupportedFormat(format_) {
+		return format_
+	}
+
+	return fallback
+}
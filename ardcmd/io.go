@@ -0,0 +1,64 @@
+package ardcmd
+
+import (
+	contextpkg "context"
+	"os"
+
+	"github.com/tliron/exturl"
+	"github.com/tliron/go-ard"
+)
+
+// Returns true if the input or output argument means stdin or stdout, i.e.
+// it is empty or "-".
+func IsStdio(urlOrPath string) bool {
+	return (urlOrPath == "") || (urlOrPath == "-")
+}
+
+// Reads an ARD [ard.Value] from stdin (see [IsStdio]), a file path, or a URL
+// supported by [exturl], e.g. "https:", "tar:", or "git:".
+//
+// The format is detected via [DetectFormat], with "yaml" as the fallback.
+func ReadInput(context contextpkg.Context, urlOrPath string, format string) (ard.Value, error) {
+	format = DetectFormat(format, urlOrPath, "yaml")
+
+	if IsStdio(urlOrPath) {
+		value, _, err := ard.Read(os.Stdin, format, false)
+		return value, err
+	}
+
+	urlContext := exturl.NewContext()
+	defer urlContext.Release()
+
+	if url, err := urlContext.NewValidAnyOrFileURL(context, urlOrPath, nil); err == nil {
+		value, _, err := ard.ReadURL(context, url, format, true, false)
+		return value, err
+	} else {
+		return nil, err
+	}
+}
+
+// Writes an ARD [ard.Value] to stdout (see [IsStdio]) or to a file path,
+// which will be created or truncated.
+//
+// The format is detected via [DetectFormat], with "yaml" as the fallback.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func WriteOutput(value ard.Value, path string, format string, reflector *ard.Reflector) error {
+	format = DetectFormat(format, path, "yaml")
+
+	if IsStdio(path) {
+		return ard.Write(value, os.Stdout, format, reflector)
+	}
+
+	if file, err := os.Create(path); err == nil {
+		if err := ard.Write(value, file, format, reflector); err == nil {
+			return file.Close()
+		} else {
+			file.Close()
+			return err
+		}
+	} else {
+		return err
+	}
+}
@@ -0,0 +1,76 @@
+package ardtest
+
+import (
+	"testing"
+
+	"github.com/tliron/go-ard"
+)
+
+// Encodes and then decodes the value via a supported format (see
+// [ard.SupportedFormats]) with [ard.RoundtripWithOptions], and compares the
+// result with the value via [ard.CheckFidelity]. On failure reports every
+// difference, with its path, via [testing.TB.Errorf] and returns false.
+//
+// The comparison is tolerant of differences that do not change the data:
+// [ard.Map] vs. [ard.StringMap], map keys converted to strings, and values
+// converted to another type with the same string representation, e.g. an
+// integer to a float or []byte to a Base64 string. Lost precision and
+// changed, removed, or added values are failures.
+//
+// The value can contain non-ARD values, e.g. structs and types registered via
+// [ard.RegisterScalarCodec], in which case it is first converted via
+// [ard.ValidCopy] with the options' reflector (or the default reflector for
+// the format) so that it can be compared.
+//
+// The options argument can be nil, in which case default options will be used.
+func AssertRoundtrip(t testing.TB, value ard.Value, format string, options *ard.RoundtripOptions) bool {
+	t.Helper()
+
+	if options == nil {
+		options = new(ard.RoundtripOptions)
+	}
+
+	reflector := options.Reflector
+	if reflector == nil {
+		reflector = ard.DefaultReflector(format)
+	}
+
+	expected, err := ard.ValidCopy(value, reflector)
+	if err != nil {
+		t.Errorf("%s roundtrip: invalid value: %s", format, err.Error())
+		return false
+	}
+
+	actual, err := ard.RoundtripWithOptions(value, format, options)
+	if err != nil {
+		t.Errorf("%s roundtrip: %s", format, err.Error())
+		return false
+	}
+
+	ok := true
+	for _, issue := range ard.CheckFidelity(expected, actual) {
+		switch issue.Type {
+		case ard.FidelityKeyConverted, ard.FidelityTypeConverted:
+		default:
+			t.Errorf("%s roundtrip: %s", format, issue.String())
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Calls [AssertRoundtrip] for all formats in [ard.SupportedFormats]. Returns
+// false if any of them failed.
+//
+// The options argument can be nil, in which case default options will be used.
+func AssertRoundtripAll(t testing.TB, value ard.Value, options *ard.RoundtripOptions) bool {
+	t.Helper()
+
+	ok := true
+	for _, format := range ard.SupportedFormats() {
+		if !AssertRoundtrip(t, value, format, options) {
+			ok = false
+		}
+	}
+	return ok
+}
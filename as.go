@@ -0,0 +1,91 @@
+package ard
+
+//
+// AsPolicy
+//
+
+// Conversion policies for the As functions, e.g. [AsString]. They can be
+// combined, e.g. AsNilMeansZero|AsConvertSimilar.
+type AsPolicy int
+
+const (
+	// See [Node.NilMeansZero].
+	AsNilMeansZero AsPolicy = 1 << iota
+
+	// See [Node.ConvertSimilar].
+	AsConvertSimilar
+)
+
+// Like [Node.String] but for a raw [Value], so that it does not need to be
+// wrapped via [With] for a single conversion.
+//
+// The policy argument is optional.
+func AsString(value Value, policy ...AsPolicy) (string, bool) {
+	return asNode(value, policy).String()
+}
+
+// Like [Node.Bytes] but for a raw [Value]. See [AsString].
+func AsBytes(value Value, policy ...AsPolicy) ([]byte, bool) {
+	return asNode(value, policy).Bytes()
+}
+
+// Like [Node.Integer] but for a raw [Value]. See [AsString].
+func AsInt(value Value, policy ...AsPolicy) (int64, bool) {
+	return asNode(value, policy).Integer()
+}
+
+// Like [Node.UnsignedInteger] but for a raw [Value]. See [AsString].
+func AsUint(value Value, policy ...AsPolicy) (uint64, bool) {
+	return asNode(value, policy).UnsignedInteger()
+}
+
+// Like [Node.Float] but for a raw [Value]. See [AsString].
+func AsFloat(value Value, policy ...AsPolicy) (float64, bool) {
+	return asNode(value, policy).Float()
+}
+
+// Like [Node.Boolean] but for a raw [Value]. See [AsString].
+func AsBool(value Value, policy ...AsPolicy) (bool, bool) {
+	return asNode(value, policy).Boolean()
+}
+
+// Like [Node.Quantity] but for a raw [Value]. See [AsString].
+func AsQuantity(value Value, policy ...AsPolicy) (Quantity, bool) {
+	return asNode(value, policy).Quantity()
+}
+
+// Like [Node.Map] but for a raw [Value]. See [AsString].
+func AsMap(value Value, policy ...AsPolicy) (Map, bool) {
+	return asNode(value, policy).Map()
+}
+
+// Like [Node.StringMap] but for a raw [Value]. See [AsString].
+func AsStringMap(value Value, policy ...AsPolicy) (StringMap, bool) {
+	return asNode(value, policy).StringMap()
+}
+
+// Like [Node.List] but for a raw [Value]. See [AsString].
+func AsList(value Value, policy ...AsPolicy) (List, bool) {
+	return asNode(value, policy).List()
+}
+
+// Like [Node.StringList] but for a raw [Value]. See [AsString].
+func AsStringList(value Value, policy ...AsPolicy) ([]string, bool) {
+	return asNode(value, policy).StringList()
+}
+
+// Utils
+
+func asNode(value Value, policy []AsPolicy) *Node {
+	var policy_ AsPolicy
+	for _, p := range policy {
+		policy_ |= p
+	}
+
+	return &Node{
+		Value:          value,
+		key:            "",
+		nilMeansZero:   policy_&AsNilMeansZero != 0,
+		convertSimilar: policy_&AsConvertSimilar != 0,
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/tliron/go-ard/benchmarks"
+)
+
+// Usage: ard-benchmark [-filter regexp] > new.txt
+//
+// Compare results across runs with benchstat: benchstat old.txt new.txt
+func main() {
+	filter := flag.String("filter", "", "regular expression for benchmark names")
+	flag.Parse()
+
+	var filter_ *regexp.Regexp
+	if *filter != "" {
+		var err error
+		if filter_, err = regexp.Compile(*filter); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if err := benchmarks.Run(os.Stdout, filter_); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,170 @@
+package benchmarks
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/tliron/go-ard"
+)
+
+var Formats = []string{"yaml", "json", "xjson", "xml", "cbor", "messagepack"}
+
+//
+// Benchmark
+//
+
+type Benchmark struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Returns benchmarks for all the documents: decode and encode per format,
+// [ard.Copy], [ard.Merge], [ard.Reflector.Pack], [ard.Reflector.Unpack], and
+// [ard.Node] traversal.
+//
+// Names are in the form "Operation/document" or "Operation/format/document".
+func Benchmarks(documents []Document) []Benchmark {
+	var benchmarks []Benchmark
+
+	for _, format := range Formats {
+		for _, document := range documents {
+			code, err := ard.Encode(document.Value, format, nil)
+			if err != nil {
+				panic(err)
+			}
+
+			benchmarks = append(benchmarks, Benchmark{
+				Name: fmt.Sprintf("Decode/%s/%s", format, document.Name),
+				F: func(b *testing.B) {
+					b.SetBytes(int64(len(code)))
+					for range b.N {
+						if _, _, err := ard.Decode(code, format, false); err != nil {
+							b.Fatal(err)
+						}
+					}
+				},
+			})
+
+			benchmarks = append(benchmarks, Benchmark{
+				Name: fmt.Sprintf("Encode/%s/%s", format, document.Name),
+				F: func(b *testing.B) {
+					b.SetBytes(int64(len(code)))
+					for range b.N {
+						if err := ard.Write(document.Value, io.Discard, format, nil); err != nil {
+							b.Fatal(err)
+						}
+					}
+				},
+			})
+		}
+	}
+
+	for _, document := range documents {
+		benchmarks = append(benchmarks, Benchmark{
+			Name: "Copy/" + document.Name,
+			F: func(b *testing.B) {
+				for range b.N {
+					ard.Copy(document.Value)
+				}
+			},
+		})
+
+		benchmarks = append(benchmarks, Benchmark{
+			Name: "Merge/" + document.Name,
+			F: func(b *testing.B) {
+				for range b.N {
+					b.StopTimer()
+					target := ard.Copy(document.Value)
+					b.StartTimer()
+					ard.Merge(target, document.Value, false)
+				}
+			},
+		})
+
+		benchmarks = append(benchmarks, Benchmark{
+			Name: "Pack/" + document.Name,
+			F: func(b *testing.B) {
+				reflector := ard.NewReflector()
+				for range b.N {
+					var record Record
+					if err := reflector.Pack(document.Value, &record); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		})
+
+		var record Record
+		if err := ard.NewReflector().Pack(document.Value, &record); err != nil {
+			panic(err)
+		}
+
+		benchmarks = append(benchmarks, Benchmark{
+			Name: "Unpack/" + document.Name,
+			F: func(b *testing.B) {
+				reflector := ard.NewReflector()
+				for range b.N {
+					if _, err := reflector.Unpack(&record); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		})
+
+		benchmarks = append(benchmarks, Benchmark{
+			Name: "Traverse/" + document.Name,
+			F: func(b *testing.B) {
+				for range b.N {
+					traverse(ard.With(document.Value))
+				}
+			},
+		})
+	}
+
+	return benchmarks
+}
+
+// Runs the benchmarks for [Documents] via [testing.Benchmark], writing the
+// results to the writer in the same format as "go test -bench", so that they
+// can be compared across runs with tools such as benchstat.
+//
+// The filter argument can be nil, otherwise only benchmarks with matching
+// names will be run.
+func Run(writer io.Writer, filter *regexp.Regexp) error {
+	for _, benchmark := range Benchmarks(Documents()) {
+		if (filter != nil) && !filter.MatchString(benchmark.Name) {
+			continue
+		}
+
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			benchmark.F(b)
+		})
+
+		if _, err := fmt.Fprintf(writer, "Benchmark%s\t%s\t%s\n", benchmark.Name, result.String(), result.MemString()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Utils
+
+func traverse(node *ard.Node) int {
+	count := 0
+	if _, ok := node.Get("name").String(); ok {
+		count++
+	}
+	if _, ok := node.Get("count").Integer(); ok {
+		count++
+	}
+	if children, ok := node.Get("children").List(); ok {
+		for _, child := range children {
+			count += traverse(ard.With(child))
+		}
+	}
+	return count
+}
@@ -0,0 +1,77 @@
+package benchmarks
+
+import (
+	"fmt"
+
+	"github.com/tliron/go-ard"
+)
+
+//
+// Document
+//
+
+type Document struct {
+	Name  string
+	Value ard.Value
+}
+
+// Representative documents of various sizes and shapes. They are generated
+// deterministically, so that results are comparable between runs.
+//
+// Each document is a tree of records (see [Record]) with the given depth and
+// number of children per record.
+func Documents() []Document {
+	return []Document{
+		{"small", NewDocument(2, 3)},
+		{"medium", NewDocument(3, 10)},
+		{"huge", NewDocument(4, 20)},
+		{"deep", NewDocument(200, 1)},
+		{"wide", NewDocument(1, 10000)},
+	}
+}
+
+// Creates a tree of records with the given depth and number of children per
+// record. Each record is a [ard.Map] that can be packed into a [Record].
+func NewDocument(depth int, width int) ard.Value {
+	var counter int64
+	return newRecord(depth, width, &counter)
+}
+
+//
+// Record
+//
+
+// The Go struct for packing and unpacking documents.
+type Record struct {
+	Name     string   `ard:"name"`
+	Count    int64    `ard:"count"`
+	Ratio    float64  `ard:"ratio"`
+	Enabled  bool     `ard:"enabled"`
+	Tags     []string `ard:"tags"`
+	Children []Record `ard:"children"`
+}
+
+// Utils
+
+func newRecord(depth int, width int, counter *int64) ard.Map {
+	count := *counter
+	*counter++
+
+	record := ard.Map{
+		"name":    fmt.Sprintf("record-%d", count),
+		"count":   count,
+		"ratio":   float64(count) / 7.0,
+		"enabled": count%2 == 0,
+		"tags":    ard.List{"alpha", "beta", fmt.Sprintf("tag-%d", count%10)},
+	}
+
+	if depth > 0 {
+		children := make(ard.List, width)
+		for index := range children {
+			children[index] = newRecord(depth-1, width, counter)
+		}
+		record["children"] = children
+	}
+
+	return record
+}
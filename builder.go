@@ -0,0 +1,143 @@
+package ard
+
+//
+// MapBuilder
+//
+
+// Fluent builder for a [Map], e.g.:
+//
+//	map_, err := ard.BuildMap().
+//		Put("name", "server").
+//		PutList("ports", ard.BuildList().Add(80).Add(443)).
+//		PutMap("tls", ard.BuildMap().Put("enabled", true)).
+//		Build()
+//
+// Values are validated and canonicalized via [ValidCopyStringMapsToMaps] when
+// built, so the result is always valid ARD with all maps as [Map]. Errors,
+// including duplicate keys, are reported by [MapBuilder.Build] with their
+// paths.
+//
+// A builder can be built more than once. Each build creates a new value.
+type MapBuilder struct {
+	entries []builderEntry
+}
+
+// Creates a new [MapBuilder].
+func BuildMap() *MapBuilder {
+	return new(MapBuilder)
+}
+
+// Puts a value. Complex keys are supported (see [MakeKey]).
+func (self *MapBuilder) Put(key Value, value Value) *MapBuilder {
+	self.entries = append(self.entries, builderEntry{key, value})
+	return self
+}
+
+// Puts a [Map] built by a nested builder.
+func (self *MapBuilder) PutMap(key Value, value *MapBuilder) *MapBuilder {
+	return self.Put(key, value)
+}
+
+// Puts a [List] built by a nested builder.
+func (self *MapBuilder) PutList(key Value, value *ListBuilder) *MapBuilder {
+	return self.Put(key, value)
+}
+
+// Builds the [Map]. Returns a [*PathError] for the first invalid key or
+// value.
+func (self *MapBuilder) Build() (Map, error) {
+	return self.build(nil)
+}
+
+func (self *MapBuilder) build(path Path) (Map, error) {
+	map_ := make(Map)
+	for _, entry := range self.entries {
+		path_ := path.AppendKey(entry.key)
+
+		key, err := MakeKey(entry.key)
+		if err != nil {
+			return nil, newPathErrorf(path_, "is not a valid key: %s", err.Error())
+		}
+
+		if _, ok := findMapKey(map_, key, true); ok {
+			return nil, newPathErrorf(path_, "is a duplicate key")
+		}
+
+		if map_[key], err = buildValue(entry.value, path_); err != nil {
+			return nil, err
+		}
+	}
+	return map_, nil
+}
+
+//
+// ListBuilder
+//
+
+// Fluent builder for a [List]. See [MapBuilder].
+//
+// A builder can be built more than once. Each build creates a new value.
+type ListBuilder struct {
+	elements []Value
+}
+
+// Creates a new [ListBuilder].
+func BuildList() *ListBuilder {
+	return new(ListBuilder)
+}
+
+// Adds a value.
+func (self *ListBuilder) Add(value Value) *ListBuilder {
+	self.elements = append(self.elements, value)
+	return self
+}
+
+// Adds a [Map] built by a nested builder.
+func (self *ListBuilder) AddMap(value *MapBuilder) *ListBuilder {
+	return self.Add(value)
+}
+
+// Adds a [List] built by a nested builder.
+func (self *ListBuilder) AddList(value *ListBuilder) *ListBuilder {
+	return self.Add(value)
+}
+
+// Builds the [List]. Returns a [*PathError] for the first invalid value.
+func (self *ListBuilder) Build() (List, error) {
+	return self.build(nil)
+}
+
+func (self *ListBuilder) build(path Path) (List, error) {
+	list := make(List, len(self.elements))
+	for index, element := range self.elements {
+		var err error
+		if list[index], err = buildValue(element, path.AppendList(index)); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Utils
+
+type builderEntry struct {
+	key   Value
+	value Value
+}
+
+func buildValue(value Value, path Path) (Value, error) {
+	switch value_ := value.(type) {
+	case *MapBuilder:
+		return value_.build(path)
+
+	case *ListBuilder:
+		return value_.build(path)
+
+	default:
+		if value__, err := ValidCopyStringMapsToMaps(value, nil); err == nil {
+			return value__, nil
+		} else {
+			return nil, prependPathError(path, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package ard
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tliron/kutil/util"
+)
+
+//
+// BytesDecoding
+//
+
+// Flags for the string representations supported by [DecodeBytes].
+type BytesDecoding int
+
+const (
+	// Base64 representation.
+	Base64BytesDecoding BytesDecoding = 1 << iota
+
+	// Hex representation, with an optional "0x" or "0X" prefix.
+	HexBytesDecoding
+
+	// The string's UTF-8 bytes as is.
+	RawBytesDecoding
+
+	// URL-safe base64 representation (RFC 4648 section 5).
+	URLBase64BytesDecoding
+
+	// Base64 representation without padding.
+	UnpaddedBase64BytesDecoding
+
+	// URL-safe base64 representation without padding.
+	UnpaddedURLBase64BytesDecoding
+)
+
+// The decoding used when none is specified.
+const DefaultBytesDecoding = Base64BytesDecoding | HexBytesDecoding
+
+// Decodes a string representation of bytes. The decoding argument
+// specifies which representations are supported. If it is 0 then
+// [DefaultBytesDecoding] will be used together with the decoding for the
+// package-wide default [BytesEncoding] (see [SetDefaultBytesEncoding]).
+//
+// Representations are attempted in this order: hex with a "0x" prefix, plain
+// hex, base64 (standard, URL-safe, unpadded, and unpadded URL-safe), and
+// finally raw UTF-8. Note that every even-length plain hex string is also
+// valid base64, thus when [HexBytesDecoding] is enabled a base64 string that
+// consists only of hex digits will be decoded as hex. Disable
+// [HexBytesDecoding] to avoid this ambiguity.
+func DecodeBytes(s string, decoding BytesDecoding) ([]byte, error) {
+	if decoding == 0 {
+		decoding = DefaultBytesDecoding | DefaultBytesEncoding().decoding()
+	}
+
+	var err error
+
+	if decoding&HexBytesDecoding != 0 {
+		if hex_, ok := strings.CutPrefix(s, "0x"); ok {
+			return hex.DecodeString(hex_)
+		} else if hex_, ok := strings.CutPrefix(s, "0X"); ok {
+			return hex.DecodeString(hex_)
+		}
+
+		// Before base64, because plain hex strings are also valid base64
+		var bytes []byte
+		if bytes, err = hex.DecodeString(s); err == nil {
+			return bytes, nil
+		}
+	}
+
+	for _, base64Decoding := range base64Decodings {
+		if decoding&base64Decoding.decoding != 0 {
+			var bytes []byte
+			if bytes, err = base64Decoding.encoding.DecodeString(s); err == nil {
+				return bytes, nil
+			}
+		}
+	}
+
+	if decoding&RawBytesDecoding != 0 {
+		return util.StringToBytes(s), nil
+	}
+
+	if err == nil {
+		err = errors.New("no supported bytes decoding")
+	}
+
+	return nil, err
+}
+
+//
+// BytesEncoding
+//
+
+// String representations for bytes.
+type BytesEncoding int
+
+const (
+	// Standard base64 representation (the default).
+	Base64BytesEncoding BytesEncoding = 0
+
+	// Lowercase hex representation, without a prefix.
+	HexBytesEncoding BytesEncoding = 1
+
+	// URL-safe base64 representation (RFC 4648 section 5).
+	URLBase64BytesEncoding BytesEncoding = 2
+
+	// Standard base64 representation without padding.
+	UnpaddedBase64BytesEncoding BytesEncoding = 3
+
+	// URL-safe base64 representation without padding.
+	UnpaddedURLBase64BytesEncoding BytesEncoding = 4
+)
+
+// Encodes bytes to a string representation.
+func EncodeBytes(bytes []byte, encoding BytesEncoding) string {
+	switch encoding {
+	case HexBytesEncoding:
+		return hex.EncodeToString(bytes)
+	case URLBase64BytesEncoding:
+		return base64.URLEncoding.EncodeToString(bytes)
+	case UnpaddedBase64BytesEncoding:
+		return base64.RawStdEncoding.EncodeToString(bytes)
+	case UnpaddedURLBase64BytesEncoding:
+		return base64.RawURLEncoding.EncodeToString(bytes)
+	default:
+		return util.ToBase64(bytes)
+	}
+}
+
+// The decoding that supports only this encoding
+func (self BytesEncoding) decoding() BytesDecoding {
+	switch self {
+	case HexBytesEncoding:
+		return HexBytesDecoding
+	case URLBase64BytesEncoding:
+		return URLBase64BytesDecoding
+	case UnpaddedBase64BytesEncoding:
+		return UnpaddedBase64BytesDecoding
+	case UnpaddedURLBase64BytesEncoding:
+		return UnpaddedURLBase64BytesDecoding
+	default:
+		return Base64BytesDecoding
+	}
+}
+
+var defaultBytesEncoding atomic.Int64
+
+// Sets the package-wide default [BytesEncoding], which is used for bytes in
+// text formats: YAML "!!binary" scalars, XJSON "$ard.bytes" maps, and XML
+// bytes elements. These are decoded with the same encoding, so it must be set
+// before both writing and reading. It is also supported by [DecodeBytes] when
+// its decoding argument is 0, and thus by [Node.Bytes].
+//
+// With the default, [Base64BytesEncoding], YAML "!!binary" scalars are decoded
+// by the YAML decoder, which decodes them as strings. With other encodings they
+// are decoded as []byte.
+//
+// Note that the YAML specification requires standard base64 for "!!binary", so
+// other YAML implementations would not be able to read other encodings.
+//
+// This function is safe to call concurrently.
+func SetDefaultBytesEncoding(encoding BytesEncoding) {
+	defaultBytesEncoding.Store(int64(encoding))
+}
+
+// Returns the package-wide default [BytesEncoding] as set by
+// [SetDefaultBytesEncoding].
+//
+// This function is safe to call concurrently.
+func DefaultBytesEncoding() BytesEncoding {
+	return BytesEncoding(defaultBytesEncoding.Load())
+}
+
+// Utils
+
+var base64Decodings = []struct {
+	decoding BytesDecoding
+	encoding *base64.Encoding
+}{
+	{Base64BytesDecoding, base64.StdEncoding},
+	{URLBase64BytesDecoding, base64.URLEncoding},
+	{UnpaddedBase64BytesDecoding, base64.RawStdEncoding},
+	{UnpaddedURLBase64BytesDecoding, base64.RawURLEncoding},
+}
+
+// Encodes bytes in text formats according to [DefaultBytesEncoding]
+func encodeTextBytes(bytes []byte) string {
+	return EncodeBytes(bytes, DefaultBytesEncoding())
+}
+
+// Decodes bytes in text formats according to [DefaultBytesEncoding]
+func decodeTextBytes(s string) ([]byte, error) {
+	return DecodeBytes(s, DefaultBytesEncoding().decoding())
+}
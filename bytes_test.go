@@ -0,0 +1,41 @@
+package ard
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		decoding BytesDecoding
+		expected []byte
+	}{
+		{"00ff", 0, []byte{0x00, 0xff}},
+		{"DEADbeef", 0, []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"0x00ff", 0, []byte{0x00, 0xff}},
+		{"0X00FF", 0, []byte{0x00, 0xff}},
+		{"aGVsbG8=", 0, []byte("hello")},
+		{"00ff", Base64BytesDecoding, []byte{0xd3, 0x47, 0xdf}},
+		{"aGVsbG8", UnpaddedBase64BytesDecoding | HexBytesDecoding, []byte("hello")},
+		{"hello!", RawBytesDecoding | HexBytesDecoding, []byte("hello!")},
+	} {
+		if bytes_, err := DecodeBytes(test.s, test.decoding); err != nil {
+			t.Errorf("%q: %s", test.s, err.Error())
+		} else if !bytes.Equal(bytes_, test.expected) {
+			t.Errorf("%q: expected %x, got %x", test.s, test.expected, bytes_)
+		}
+	}
+
+	if _, err := DecodeBytes("hello!", 0); err == nil {
+		t.Error("\"hello!\": expected an error")
+	}
+}
+
+func TestNodeBytesHex(t *testing.T) {
+	if bytes_, ok := With("00ff").ConvertSimilar().Bytes(); !ok {
+		t.Error("not converted")
+	} else if !bytes.Equal(bytes_, []byte{0x00, 0xff}) {
+		t.Errorf("expected 00ff, got %x", bytes_)
+	}
+}
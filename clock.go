@@ -0,0 +1,80 @@
+package ard
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//
+// Clock
+//
+
+// Source of the current time for features that need timestamps, e.g.
+// [Metrics] durations and [Document.Modified]. Setting a [FixedClock] via
+// [SetClock] makes them deterministic in tests.
+//
+// Implementations must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+}
+
+var globalClock atomic.Pointer[Clock]
+
+// Sets the package-wide [Clock].
+//
+// Can be nil, which restores the system clock (the default).
+//
+// This function is safe to call concurrently.
+func SetClock(clock Clock) {
+	if clock != nil {
+		globalClock.Store(&clock)
+	} else {
+		globalClock.Store(nil)
+	}
+}
+
+// Returns the package-wide [Clock]. Downstream code can use it, too, in order
+// to share the configuration.
+//
+// This function is safe to call concurrently.
+func GetClock() Clock {
+	if clock := globalClock.Load(); clock != nil {
+		return *clock
+	} else {
+		return SystemClock{}
+	}
+}
+
+//
+// SystemClock
+//
+
+// A [Clock] that calls [time.Now].
+type SystemClock struct{}
+
+// ([Clock] interface)
+func (self SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+//
+// FixedClock
+//
+
+// A [Clock] that always returns the same time.
+type FixedClock time.Time
+
+// ([Clock] interface)
+func (self FixedClock) Now() time.Time {
+	return time.Time(self)
+}
+
+// Utils
+
+func now() time.Time {
+	return GetClock().Now()
+}
+
+func since(start time.Time) time.Duration {
+	return now().Sub(start)
+}
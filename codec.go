@@ -0,0 +1,188 @@
+package ard
+
+import (
+	"bytes"
+	contextpkg "context"
+	"io"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tliron/exturl"
+)
+
+//
+// MapMode
+//
+
+type MapMode int
+
+const (
+	// All maps are [Map], which supports keys of any type
+	AnyKeyedMaps MapMode = 0
+
+	// All maps are [StringMap], with keys converted using [MapKeyToString]
+	StringKeyedMaps MapMode = 1
+)
+
+// ([fmt.Stringer] interface)
+func (self MapMode) String() string {
+	switch self {
+	case AnyKeyedMaps:
+		return "any"
+	case StringKeyedMaps:
+		return "string"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+// Converts all maps in the value to the mode's map type, recursively.
+// Conversion happens in place, unless the value is itself a map of the
+// other type, in which case a new map will be returned.
+func (self MapMode) Convert(value Value) Value {
+	value, _ = convert(value, self.conversionMode())
+	return value
+}
+
+func (self MapMode) conversionMode() conversionMode {
+	if self == StringKeyedMaps {
+		return convertMapsToStringMaps
+	} else {
+		return convertStringMapsToMaps
+	}
+}
+
+//
+// Codec
+//
+
+// A single place for the options that determine the shape of ARD values,
+// which are otherwise scattered across functions as useStringMaps arguments
+// with differing defaults. For example, [Read] always returns [Map] while
+// [ReadJSON] can return [StringMap].
+//
+// All functions of a codec consistently honor its MapMode.
+//
+// Methods are nil-safe, in which case the package-wide default codec is used
+// (see [SetDefaultCodec]).
+type Codec struct {
+	MapMode MapMode
+
+	// The format for which [Codec.Unpack], [Codec.Pack], and [Codec.ValidCopy]
+	// use the default reflector (see [DefaultReflector]) when Reflector is
+	// nil. Can be empty, in which case the package-wide default reflector is
+	// used. Ignored by functions that have a format argument.
+	Format string
+
+	// When nil will use [DefaultReflector] for the format
+	Reflector *Reflector
+}
+
+var defaultCodec atomic.Pointer[Codec]
+
+// Sets the package-wide default [Codec], which is used when calling methods
+// on a nil codec.
+//
+// Can be nil, which restores the default: [AnyKeyedMaps] and the default
+// reflectors.
+//
+// The codec should not be modified after it is set, as it will be shared.
+//
+// This function is safe to call concurrently.
+func SetDefaultCodec(codec *Codec) {
+	defaultCodec.Store(codec)
+}
+
+// Returns the package-wide default [Codec] as set by [SetDefaultCodec]. It
+// should not be modified.
+//
+// This function is safe to call concurrently.
+func DefaultCodec() *Codec {
+	if codec := defaultCodec.Load(); codec != nil {
+		return codec
+	} else {
+		return new(Codec)
+	}
+}
+
+// Like [Read] but with maps according to the codec's MapMode.
+func (self *Codec) Read(reader io.Reader, format string, locate bool) (Value, Locator, error) {
+	self = self.get()
+	if value, locator, err := Read(reader, format, locate); err == nil {
+		return self.MapMode.Convert(value), locator, nil
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Like [ReadURL] but with maps according to the codec's MapMode.
+func (self *Codec) ReadURL(context contextpkg.Context, url exturl.URL, format string, forceFormat bool, locate bool) (Value, Locator, error) {
+	self = self.get()
+	if value, locator, err := ReadURL(context, url, format, forceFormat, locate); err == nil {
+		return self.MapMode.Convert(value), locator, nil
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Like [Decode] but with maps according to the codec's MapMode.
+func (self *Codec) Decode(code []byte, format string, locate bool) (Value, Locator, error) {
+	return self.Read(bytes.NewReader(code), format, locate)
+}
+
+// Like [Roundtrip] but with maps according to the codec's MapMode.
+func (self *Codec) Roundtrip(value Value, format string) (Value, error) {
+	self = self.get()
+	return RoundtripWithOptions(value, format, &RoundtripOptions{
+		MapMode:   self.MapMode,
+		Reflector: self.Reflector,
+	})
+}
+
+// Like [Reflector.Unpack] but with maps according to the codec's MapMode.
+func (self *Codec) Unpack(packedValue any) (Value, error) {
+	self = self.get()
+	return self.reflector(self.Format).unpackRoot(packedValue, self.MapMode == StringKeyedMaps)
+}
+
+// See [Reflector.Pack].
+func (self *Codec) Pack(value Value, packedValuePtr any) error {
+	self = self.get()
+	return self.reflector(self.Format).Pack(value, packedValuePtr)
+}
+
+// Like [Copy] but with maps according to the codec's MapMode.
+func (self *Codec) Copy(value Value) Value {
+	value, _ = copy_(value, nil, self.get().MapMode.conversionMode(), nil, nil)
+	return value
+}
+
+// Like [ValidCopy] but with maps according to the codec's MapMode.
+func (self *Codec) ValidCopy(value Value) (Value, error) {
+	self = self.get()
+	return validCopy(value, self.reflector(self.Format), self.MapMode.conversionMode(), false, nil)
+}
+
+// Like [Merge] but with maps according to the codec's MapMode. The target is
+// converted in place (see [MapMode.Convert]) so that maps of both types are
+// merged key by key.
+func (self *Codec) Merge(target Value, source Value, appendLists bool) Value {
+	self = self.get()
+	return Merge(self.MapMode.Convert(target), self.Copy(source), appendLists)
+}
+
+func (self *Codec) get() *Codec {
+	if self != nil {
+		return self
+	} else {
+		return DefaultCodec()
+	}
+}
+
+func (self *Codec) reflector(format string) *Reflector {
+	if self.Reflector != nil {
+		return self.Reflector
+	} else {
+		return DefaultReflector(format)
+	}
+}
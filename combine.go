@@ -0,0 +1,280 @@
+package ard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const RefCode = "$ard.ref"
+
+//
+// Provenance
+//
+
+// Maps paths in [Path.String] format to the names of the documents from which
+// their values came. See [CombineDocuments].
+type Provenance map[string]string
+
+// Returns the name of the document from which the value at the path came.
+// Returns false if the path is not in the combined value.
+func (self Provenance) Get(path Path) (string, bool) {
+	name, ok := self[path.String()]
+	return name, ok
+}
+
+// Combines a set of named documents, e.g. decoded files that import each
+// other, into a single value.
+//
+// When rootKeyPerDoc is true each document is grafted into a root [Map] under
+// its name as the key. Otherwise the documents are deep merged via [Merge]
+// (with lists overridden) in the sorted order of their names, such that later
+// documents override earlier ones.
+//
+// Before combining, all reference maps are replaced by a [Copy] of the values
+// they refer to. A reference map is a [Map] or [StringMap] with a single
+// "$ard.ref" key and a string reference value in the form "name#path", where
+// name is the name of a document and path is in [Path.String] format, e.g.:
+//
+//	{"$ard.ref": "common#defaults.ports[0]"}
+//
+// An empty name refers to the document containing the reference, and an
+// empty path (or no "#") refers to the whole document. Paths refer to the
+// documents as they are before combining, and references along a path are
+// followed, e.g. "a#x.y" works even if "x" in document "a" is itself a
+// reference. Referred values may themselves contain references, in which case
+// they will be resolved first. Circular references are errors.
+//
+// The documents are not modified. Also returned is the [Provenance] of every
+// path in the combined value (except the root). For merged maps it is the
+// last document that contained them.
+func CombineDocuments(docs map[string]Value, rootKeyPerDoc bool) (Value, Provenance, error) {
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// References are looked up in the unresolved documents, so that the
+	// results do not depend on the order of resolution
+	resolver := documentResolver{
+		docs:      docs,
+		resolving: make(map[string]struct{}),
+		following: make(map[string]struct{}),
+	}
+	resolved := make(map[string]Value, len(docs))
+	for _, name := range names {
+		if doc, err := resolver.resolve(Copy(docs[name]), name); err == nil {
+			resolved[name] = doc
+		} else {
+			return nil, nil, fmt.Errorf("document %q: %w", name, err)
+		}
+	}
+
+	var combined Value
+	if rootKeyPerDoc {
+		combined_ := make(Map)
+		for _, name := range names {
+			combined_[name] = resolved[name]
+		}
+		combined = combined_
+	} else {
+		for index, name := range names {
+			if index == 0 {
+				combined = resolved[name]
+			} else {
+				combined = Merge(combined, resolved[name], false)
+			}
+		}
+	}
+
+	provenance := make(Provenance)
+	addProvenance(provenance, combined, nil, func(path Path) (string, bool) {
+		if rootKeyPerDoc {
+			return path[0].Value.(string), true
+		}
+
+		// Last document wins
+		for index := len(names) - 1; index >= 0; index-- {
+			if With(resolved[names[index]]).getPath(path) != NoNode {
+				return names[index], true
+			}
+		}
+		return "", false
+	})
+
+	return combined, provenance, nil
+}
+
+// Utils
+
+type documentResolver struct {
+	docs      map[string]Value // unresolved, never modified
+	resolving map[string]struct{}
+	following map[string]struct{}
+}
+
+// Resolves in place
+func (self *documentResolver) resolve(value Value, name string) (Value, error) {
+	if reference, ok := getReference(value); ok {
+		name_, path, err := parseReference(reference, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// The referred value might contain this reference
+		identity := name_ + "#" + path.String()
+		if _, ok := self.resolving[identity]; ok {
+			return nil, fmt.Errorf("circular reference: %s", reference)
+		}
+		self.resolving[identity] = struct{}{}
+		defer delete(self.resolving, identity)
+
+		value_, name_, err := self.follow(value, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if value_, err := self.resolve(Copy(value_), name_); err == nil {
+			return value_, nil
+		} else {
+			return nil, fmt.Errorf("%s: %w", reference, err)
+		}
+	}
+
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			if element_, err := self.resolve(element, name); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			if element_, err := self.resolve(element, name); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+		}
+
+	case List:
+		for index, element := range value_ {
+			if element_, err := self.resolve(element, name); err == nil {
+				value_[index] = element_
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// Follows references until the value is not a reference. Returns the
+// unresolved value and the name of the document containing it.
+func (self *documentResolver) follow(value Value, name string) (Value, string, error) {
+	reference, ok := getReference(value)
+	if !ok {
+		return value, name, nil
+	}
+
+	name_, path, err := parseReference(reference, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	identity := name_ + "#" + path.String()
+	if _, ok := self.following[identity]; ok {
+		return nil, "", fmt.Errorf("circular reference: %s", reference)
+	}
+	self.following[identity] = struct{}{}
+	defer delete(self.following, identity)
+
+	value, ok = self.docs[name_]
+	if !ok {
+		return nil, "", fmt.Errorf("reference to unknown document: %s", reference)
+	}
+
+	// References along the path are followed, too
+	for index := range path {
+		if value, name_, err = self.follow(value, name_); err != nil {
+			return nil, "", err
+		}
+
+		if node := With(value).getPath(path[index : index+1]); node != NoNode {
+			value = node.Value
+		} else {
+			return nil, "", fmt.Errorf("reference not found: %s", reference)
+		}
+	}
+
+	return self.follow(value, name_)
+}
+
+func getReference(value Value) (string, bool) {
+	switch value_ := value.(type) {
+	case Map:
+		if len(value_) == 1 {
+			if reference, ok := value_[RefCode]; ok {
+				reference_, ok := reference.(string)
+				return reference_, ok
+			}
+		}
+
+	case StringMap:
+		if len(value_) == 1 {
+			if reference, ok := value_[RefCode]; ok {
+				reference_, ok := reference.(string)
+				return reference_, ok
+			}
+		}
+	}
+
+	return "", false
+}
+
+func parseReference(reference string, name string) (string, Path, error) {
+	name_, path, _ := strings.Cut(reference, "#")
+	if name_ == "" {
+		name_ = name
+	}
+
+	if path == "" {
+		return name_, nil, nil
+	}
+
+	if path_, err := ParsePath(path); err == nil {
+		return name_, path_, nil
+	} else {
+		return "", nil, fmt.Errorf("malformed reference %q: %w", reference, err)
+	}
+}
+
+func addProvenance(provenance Provenance, value Value, path Path, getName func(path Path) (string, bool)) {
+	if len(path) > 0 {
+		if name, ok := getName(path); ok {
+			provenance[path.String()] = name
+		}
+	}
+
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			addProvenance(provenance, element, path.AppendKey(key), getName)
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			addProvenance(provenance, element, path.AppendField(key), getName)
+		}
+
+	case List:
+		for index, element := range value_ {
+			addProvenance(provenance, element, path.AppendList(index), getName)
+		}
+	}
+}
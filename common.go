@@ -2,6 +2,7 @@ package ard
 
 import (
 	"bytes"
+	"encoding/base64"
 	"io"
 
 	"github.com/tliron/kutil/util"
@@ -26,3 +27,13 @@ func readBase64(reader io.Reader) (io.Reader, error) {
 		return nil, err
 	}
 }
+
+func writeBase64(writer io.Writer, write func(writer io.Writer) error) error {
+	encoder := base64.NewEncoder(base64.StdEncoding, writer)
+	if err := write(encoder); err == nil {
+		// Flushes the final block
+		return encoder.Close()
+	} else {
+		return err
+	}
+}
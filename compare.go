@@ -0,0 +1,361 @@
+package ard
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tliron/yamlkeys"
+)
+
+// Compares two ARD values according to a total order. Returns -1 if a is
+// less than b, 1 if a is greater than b, and 0 if they are equal.
+//
+// Values are ordered first by type and then by value. The type order is:
+// nil, bool, numbers, string, []byte, [time.Time], [List], maps ([Map] and
+// [StringMap]), and finally all other types.
+//
+//   - Booleans: false before true.
+//   - Numbers: numbers of all types are compared numerically, with NaN before
+//     all other numbers. Numerically equal numbers are ordered by kind: signed
+//     integers, unsigned integers, and then floats.
+//   - Strings and []byte: lexicographically by bytes.
+//   - Timestamps: chronologically.
+//   - Lists: lexicographically by element, such that a shorter list comes
+//     before a longer list that has it as a prefix.
+//   - Maps: entries are sorted by key and then compared lexicographically,
+//     first by key and then by value, such that a map with fewer entries comes
+//     before a larger map that has them as a prefix. A [Map] comes before an
+//     equal [StringMap]. Complex keys are unwrapped (see [MakeKey]).
+//   - Other types: by type name and then by [ValueToString].
+//
+// Note that values that are equal via [Equals] always compare as 0, but the
+// reverse is not guaranteed, e.g. int64(1) and int(1) compare as 0.
+func Compare(a Value, b Value) int {
+	aRank := getTypeRank(a)
+	bRank := getTypeRank(b)
+
+	if aRank != bRank {
+		return compareInts(aRank, bRank)
+	}
+
+	switch aRank {
+	case booleanRank:
+		aBool := a.(bool)
+		bBool := b.(bool)
+		if aBool == bBool {
+			return 0
+		} else if !aBool {
+			return -1
+		} else {
+			return 1
+		}
+
+	case numberRank:
+		return compareNumbers(a, b)
+
+	case stringRank:
+		return strings.Compare(a.(string), b.(string))
+
+	case bytesRank:
+		return bytes.Compare(a.([]byte), b.([]byte))
+
+	case timestampRank:
+		return a.(time.Time).Compare(b.(time.Time))
+
+	case listRank:
+		aList := a.(List)
+		bList := b.(List)
+		for index, aElement := range aList {
+			if index >= len(bList) {
+				return 1
+			}
+			if c := Compare(aElement, bList[index]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(aList), len(bList))
+
+	case mapRank:
+		aEntries := sortedCompareEntries(a)
+		bEntries := sortedCompareEntries(b)
+		for index, aEntry := range aEntries {
+			if index >= len(bEntries) {
+				return 1
+			}
+			bEntry := bEntries[index]
+			if c := Compare(aEntry.key, bEntry.key); c != 0 {
+				return c
+			}
+			if c := Compare(aEntry.value, bEntry.value); c != 0 {
+				return c
+			}
+		}
+		if c := compareInts(len(aEntries), len(bEntries)); c != 0 {
+			return c
+		}
+		_, aIsStringMap := a.(StringMap)
+		_, bIsStringMap := b.(StringMap)
+		if aIsStringMap == bIsStringMap {
+			return 0
+		} else if bIsStringMap {
+			return -1
+		} else {
+			return 1
+		}
+
+	default:
+		if c := strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b)); c != 0 {
+			return c
+		}
+		return strings.Compare(ValueToString(a), ValueToString(b))
+	}
+}
+
+// Utils
+
+// Ranks of types in the order
+const (
+	nullRank      = 0
+	booleanRank   = 1
+	numberRank    = 2
+	stringRank    = 3
+	bytesRank     = 4
+	timestampRank = 5
+	listRank      = 6
+	mapRank       = 7
+	otherRank     = 8
+)
+
+//
+// KeyValue
+//
+
+type KeyValue struct {
+	Key   Value
+	Value Value
+}
+
+// Returns the entries of a [Map] or [StringMap] sorted by key according to
+// [Compare], which is useful for deterministic iteration without converting
+// the whole value. Complex keys are unwrapped (see [MakeKey]).
+//
+// Returns an error if the argument is not a [Map] or [StringMap].
+func SortedEntries(map_ Value) ([]KeyValue, error) {
+	switch map_.(type) {
+	case Map, StringMap:
+		compareEntries := sortedCompareEntries(map_)
+		entries := make([]KeyValue, len(compareEntries))
+		for index, entry := range compareEntries {
+			entries[index] = KeyValue{entry.key, entry.value}
+		}
+		return entries, nil
+
+	default:
+		return nil, fmt.Errorf("not a map: %s", GetTypeName(map_))
+	}
+}
+
+type compareEntry struct {
+	key   Value
+	value Value
+}
+
+// Complex keys are unwrapped
+func sortedCompareEntries(map_ Value) []compareEntry {
+	entries := compareEntries(map_)
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	return entries
+}
+
+// Complex keys are unwrapped
+func compareEntries(map_ Value) []compareEntry {
+	var entries []compareEntry
+
+	switch map__ := map_.(type) {
+	case Map:
+		entries = make([]compareEntry, 0, len(map__))
+		for key, value := range map__ {
+			entries = append(entries, compareEntry{yamlkeys.KeyData(key), value})
+		}
+
+	case StringMap:
+		entries = make([]compareEntry, 0, len(map__))
+		for key, value := range map__ {
+			entries = append(entries, compareEntry{key, value})
+		}
+	}
+
+	return entries
+}
+
+func getTypeRank(value Value) int {
+	switch value.(type) {
+	case nil:
+		return nullRank
+	case bool:
+		return booleanRank
+	case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32:
+		return numberRank
+	case string:
+		return stringRank
+	case []byte:
+		return bytesRank
+	case time.Time:
+		return timestampRank
+	case List:
+		return listRank
+	case Map, StringMap:
+		return mapRank
+	default:
+		return otherRank
+	}
+}
+
+// Numeric kinds in order of precedence for numerically equal values
+const (
+	signedKind   = 0
+	unsignedKind = 1
+	floatKind    = 2
+)
+
+type number struct {
+	kind     int
+	signed   int64
+	unsigned uint64
+	float    float64
+}
+
+func toNumber(value Value) number {
+	switch value_ := value.(type) {
+	case int64:
+		return number{kind: signedKind, signed: value_}
+	case int32:
+		return number{kind: signedKind, signed: int64(value_)}
+	case int16:
+		return number{kind: signedKind, signed: int64(value_)}
+	case int8:
+		return number{kind: signedKind, signed: int64(value_)}
+	case int:
+		return number{kind: signedKind, signed: int64(value_)}
+	case uint64:
+		return number{kind: unsignedKind, unsigned: value_}
+	case uint32:
+		return number{kind: unsignedKind, unsigned: uint64(value_)}
+	case uint16:
+		return number{kind: unsignedKind, unsigned: uint64(value_)}
+	case uint8:
+		return number{kind: unsignedKind, unsigned: uint64(value_)}
+	case uint:
+		return number{kind: unsignedKind, unsigned: uint64(value_)}
+	case float64:
+		return number{kind: floatKind, float: value_}
+	case float32:
+		return number{kind: floatKind, float: float64(value_)}
+	default:
+		return number{}
+	}
+}
+
+func (self number) toFloat() float64 {
+	switch self.kind {
+	case signedKind:
+		return float64(self.signed)
+	case unsignedKind:
+		return float64(self.unsigned)
+	default:
+		return self.float
+	}
+}
+
+// NaN is ordered before all other numbers. Numerically equal values are
+// ordered by kind: signed integers, unsigned integers, and then floats.
+func compareNumbers(a Value, b Value) int {
+	aNumber := toNumber(a)
+	bNumber := toNumber(b)
+
+	var c int
+	switch {
+	case (aNumber.kind == signedKind) && (bNumber.kind == signedKind):
+		c = compareInts(aNumber.signed, bNumber.signed)
+
+	case (aNumber.kind == unsignedKind) && (bNumber.kind == unsignedKind):
+		c = compareInts(aNumber.unsigned, bNumber.unsigned)
+
+	case (aNumber.kind == signedKind) && (bNumber.kind == unsignedKind):
+		if aNumber.signed < 0 {
+			c = -1
+		} else {
+			c = compareInts(uint64(aNumber.signed), bNumber.unsigned)
+		}
+
+	case (aNumber.kind == unsignedKind) && (bNumber.kind == signedKind):
+		if bNumber.signed < 0 {
+			c = 1
+		} else {
+			c = compareInts(aNumber.unsigned, uint64(bNumber.signed))
+		}
+
+	default:
+		aFloat := aNumber.toFloat()
+		bFloat := bNumber.toFloat()
+		aNaN := math.IsNaN(aFloat)
+		bNaN := math.IsNaN(bFloat)
+		switch {
+		case aNaN && bNaN:
+			c = 0
+		case aNaN:
+			c = -1
+		case bNaN:
+			c = 1
+		default:
+			c = compareInts(aFloat, bFloat)
+		}
+	}
+
+	if c == 0 {
+		c = compareInts(aNumber.kind, bNumber.kind)
+	}
+
+	return c
+}
+
+func compareInts[T int | int64 | uint64 | float64](a T, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// Like [Equals] but numbers are compared by value regardless of their Go type
+func equalsNumerically(a Value, b Value) bool {
+	if (getTypeRank(a) == numberRank) && (getTypeRank(b) == numberRank) {
+		aNumber := toNumber(a)
+		bNumber := toNumber(b)
+		switch {
+		case (aNumber.kind == floatKind) || (bNumber.kind == floatKind):
+			return aNumber.toFloat() == bNumber.toFloat()
+
+		// Integers are compared exactly, as float64 cannot represent all of them
+		case aNumber.kind == bNumber.kind:
+			return (aNumber.signed == bNumber.signed) && (aNumber.unsigned == bNumber.unsigned)
+
+		case aNumber.kind == signedKind:
+			return (aNumber.signed >= 0) && (uint64(aNumber.signed) == bNumber.unsigned)
+
+		default:
+			return (bNumber.signed >= 0) && (uint64(bNumber.signed) == aNumber.unsigned)
+		}
+	}
+	return Equals(a, b)
+}
@@ -0,0 +1,73 @@
+package ard
+
+import (
+	"bytes"
+	contextpkg "context"
+	"io"
+
+	"github.com/tliron/kutil/util"
+	"github.com/tliron/yamlkeys"
+	"gopkg.in/yaml.v3"
+)
+
+// Like [Read] but honors cancellation of the context.
+//
+// Cancellation is checked whenever data is read from the reader, so it is not
+// guaranteed to interrupt decoding of data that has already been read. If the
+// context is done the returned error will be the context's error.
+func ReadContext(context contextpkg.Context, reader io.Reader, format string, locate bool) (Value, Locator, error) {
+	if err := context.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	value, locator, err := Read(util.NewContextualReader(context, reader), format, locate)
+	if err != nil {
+		if err_ := context.Err(); err_ != nil {
+			return nil, nil, err_
+		}
+	}
+	return value, locator, err
+}
+
+// Like [Decode] but honors cancellation of the context. See [ReadContext].
+func DecodeContext(context contextpkg.Context, code []byte, format string, locate bool) (Value, Locator, error) {
+	return ReadContext(context, bytes.NewReader(code), format, locate)
+}
+
+// Like [ReadAllYAML] but honors cancellation of the context, which is also
+// checked between documents. See [ReadContext].
+func ReadAllYAMLContext(context contextpkg.Context, reader io.Reader) (List, error) {
+	decoder := yaml.NewDecoder(util.NewContextualReader(context, reader))
+	var list List
+	for {
+		if err := context.Err(); err != nil {
+			return nil, err
+		}
+
+		var node yaml.Node
+		if err := decoder.Decode(&node); err == nil {
+			if value, err := yamlkeys.DecodeNode(&node); err == nil {
+				list = append(list, value)
+			} else {
+				return nil, err
+			}
+		} else if err == io.EOF {
+			return list, nil
+		} else if err_ := context.Err(); err_ != nil {
+			return nil, err_
+		} else {
+			return nil, yamlkeys.WrapWithDecodeError(err)
+		}
+	}
+}
+
+// Like [NewEventProducer] but honors cancellation of the context. For
+// streaming formats cancellation is checked whenever data is read from the
+// reader, so that producing events will fail after cancellation.
+func NewEventProducerContext(context contextpkg.Context, reader io.Reader, format string) (EventProducer, error) {
+	if err := context.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewEventProducer(util.NewContextualReader(context, reader), format)
+}
@@ -1,5 +1,13 @@
 package ard
 
+import (
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/tliron/yamlkeys"
+)
+
 // Converts any [StringMap] to [Map] recursively, ensuring that no
 // [StringMap] will be present. Conversion happens in place, unless the
 // input is itself a [StringMap], in which case a new [Map] will be
@@ -15,13 +23,132 @@ func ConvertStringMapsToMaps(value Value) (Value, bool) {
 // input is itself a [Map], in which case a new [StringMap] will be
 // returned.
 //
-// Keys are converted using [MapKeyToString].
+// Keys are converted using [MapKeyToString]. Note that distinct keys could be
+// converted to the same string, e.g. 1 and "1", in which case only one of
+// their entries will be kept, arbitrarily. To handle such collisions use
+// [ConvertMapsToStringMapsWithPolicy].
 //
 // Returns true if any conversion occurred.
 func ConvertMapsToStringMaps(value Value) (Value, bool) {
 	return convert(value, convertMapsToStringMaps)
 }
 
+// Like [ConvertMapsToStringMaps] but with a [KeyCollisionPolicy] for distinct
+// keys that are converted to the same string.
+//
+// Will return an error only for [KeyCollisionError]. In that case the value
+// may have been partially converted.
+func ConvertMapsToStringMapsWithPolicy(value Value, policy KeyCollisionPolicy) (Value, bool, error) {
+	return convertMapsToStringMapsWithPolicy(value, policy, nil)
+}
+
+//
+// KeyCollisionPolicy
+//
+
+// Determines what happens when distinct [Map] keys are converted to the same
+// [StringMap] key via [MapKeyToString], e.g. 1 and "1".
+//
+// Colliding keys are handled in [Compare] order, so that the result is
+// deterministic.
+type KeyCollisionPolicy int
+
+const (
+	// Return a [*PathError]
+	KeyCollisionError KeyCollisionPolicy = 0
+
+	// Keep the first key as is and append a suffix to the others, e.g. "1",
+	// "1~2", and "1~3". Suffixed keys never collide with other keys.
+	KeyCollisionSuffix KeyCollisionPolicy = 1
+
+	// Keep only the entry of the first key
+	KeyCollisionKeepFirst KeyCollisionPolicy = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self KeyCollisionPolicy) String() string {
+	switch self {
+	case KeyCollisionError:
+		return "error"
+	case KeyCollisionSuffix:
+		return "suffix"
+	case KeyCollisionKeepFirst:
+		return "keep-first"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+// Converts a single level of the value (not recursively) to canonical
+// representations. This is useful when traversing values that may mix
+// representations, as it avoids the cost of converting the whole tree
+// upfront. Nested values can be normalized as they are reached.
+//
+//   - [Map] and [StringMap] are converted to the mode's map type, with keys
+//     converted using [MapKeyToString] for [StringKeyedMaps]
+//   - Other Go maps, e.g. map[string]string, are converted to the mode's map
+//     type
+//   - Go slices and arrays other than []byte, e.g. []string, are converted to
+//     [List]
+//
+// Other values, including values that are already canonical, are returned
+// as is. Returns true if a conversion occurred, in which case the returned
+// value is new.
+func NormalizeValue(value Value, mode MapMode) (Value, bool) {
+	switch value_ := value.(type) {
+	case Map:
+		if mode == StringKeyedMaps {
+			stringMap := make(StringMap, len(value_))
+			for key, element := range value_ {
+				stringMap[MapKeyToString(key)] = element
+			}
+			return stringMap, true
+		}
+		return value, false
+
+	case StringMap:
+		if mode == AnyKeyedMaps {
+			map_ := make(Map, len(value_))
+			for key, element := range value_ {
+				map_[key] = element
+			}
+			return map_, true
+		}
+		return value, false
+
+	case List, []byte, nil:
+		return value, false
+	}
+
+	switch reflectValue := reflect.ValueOf(value); reflectValue.Kind() {
+	case reflect.Map:
+		if mode == StringKeyedMaps {
+			stringMap := make(StringMap, reflectValue.Len())
+			iterator := reflectValue.MapRange()
+			for iterator.Next() {
+				stringMap[MapKeyToString(iterator.Key().Interface())] = iterator.Value().Interface()
+			}
+			return stringMap, true
+		} else {
+			map_ := make(Map, reflectValue.Len())
+			iterator := reflectValue.MapRange()
+			for iterator.Next() {
+				map_[iterator.Key().Interface()] = iterator.Value().Interface()
+			}
+			return map_, true
+		}
+
+	case reflect.Slice, reflect.Array:
+		list := make(List, reflectValue.Len())
+		for index := range list {
+			list[index] = reflectValue.Index(index).Interface()
+		}
+		return list, true
+	}
+
+	return value, false
+}
+
 func convert(value Value, mode conversionMode) (Value, bool) {
 	switch value_ := value.(type) {
 	case StringMap:
@@ -102,3 +229,109 @@ func convert(value Value, mode conversionMode) (Value, bool) {
 
 	return value, false
 }
+
+func convertMapsToStringMapsWithPolicy(value Value, policy KeyCollisionPolicy, path Path) (Value, bool, error) {
+	var err error
+	switch value_ := value.(type) {
+	case Map:
+		keys, err := stringMapKeys(value_, policy, path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		stringMap := make(StringMap, len(keys))
+		for _, key := range keys {
+			if stringMap[key.string_], _, err = convertMapsToStringMapsWithPolicy(value_[key.key], policy, path.AppendKey(key.key)); err != nil {
+				return nil, false, err
+			}
+		}
+		return stringMap, true, nil
+
+	case StringMap:
+		changed := false
+		for key, element := range value_ {
+			var changed_ bool
+			if element, changed_, err = convertMapsToStringMapsWithPolicy(element, policy, path.AppendField(key)); err != nil {
+				return nil, false, err
+			} else if changed_ {
+				value_[key] = element
+				changed = true
+			}
+		}
+		return value, changed, nil
+
+	case List:
+		changed := false
+		for index, element := range value_ {
+			var changed_ bool
+			if element, changed_, err = convertMapsToStringMapsWithPolicy(element, policy, path.AppendList(index)); err != nil {
+				return nil, false, err
+			} else if changed_ {
+				value_[index] = element
+				changed = true
+			}
+		}
+		return value, changed, nil
+	}
+
+	return value, false, nil
+}
+
+type stringMapKey struct {
+	key     Value
+	string_ string
+}
+
+// Colliding keys are omitted or suffixed according to the policy
+func stringMapKeys(map_ Map, policy KeyCollisionPolicy, path Path) ([]stringMapKey, error) {
+	keys := make([]stringMapKey, 0, len(map_))
+	taken := make(map[string]struct{}, len(map_))
+	collision := false
+	for key := range map_ {
+		string_ := MapKeyToString(key)
+		if _, ok := taken[string_]; ok {
+			collision = true
+		} else {
+			taken[string_] = struct{}{}
+		}
+		keys = append(keys, stringMapKey{key, string_})
+	}
+
+	if !collision {
+		return keys, nil
+	}
+
+	sort.Slice(keys, func(i int, j int) bool {
+		return Compare(yamlkeys.KeyData(keys[i].key), yamlkeys.KeyData(keys[j].key)) < 0
+	})
+
+	firsts := make(map[string]Value, len(keys))
+	keys_ := keys[:0]
+	for _, key := range keys {
+		first, ok := firsts[key.string_]
+		if !ok {
+			firsts[key.string_] = key.key
+			keys_ = append(keys_, key)
+			continue
+		}
+
+		switch policy {
+		case KeyCollisionSuffix:
+			for suffix := 2; ; suffix++ {
+				string_ := key.string_ + "~" + strconv.Itoa(suffix)
+				if _, ok := taken[string_]; !ok {
+					taken[string_] = struct{}{}
+					keys_ = append(keys_, stringMapKey{key.key, string_})
+					break
+				}
+			}
+
+		case KeyCollisionKeepFirst:
+
+		default:
+			return nil, newPathErrorf(path, "has %s key %s that collides with %s key %s", GetTypeName(key.key), ValueToString(key.key), GetTypeName(first), ValueToString(first))
+		}
+	}
+
+	return keys_, nil
+}
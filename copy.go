@@ -1,5 +1,9 @@
 package ard
 
+import (
+	"fmt"
+)
+
 // Deep copy.
 //
 // The input can be a mix of ARD and non-ARD values (e.g. Go structs). Non-ARD
@@ -11,7 +15,7 @@ package ard
 // into a new [StringMap]. To convert them to a unified map type use
 // [CopyStringMapsToMaps] or [CopyMapsToStringMaps].
 func Copy(value Value) Value {
-	value, _ = copy_(value, nil, noConversion)
+	value, _ = copy_(value, nil, noConversion, nil, nil)
 	return value
 }
 
@@ -19,26 +23,44 @@ func Copy(value Value) Value {
 //
 // For in-place conversion use [ConvertStringMapsToMaps].
 func CopyStringMapsToMaps(value Value) Value {
-	value, _ = copy_(value, nil, convertStringMapsToMaps)
+	value, _ = copy_(value, nil, convertStringMapsToMaps, nil, nil)
 	return value
 }
 
 // Like [Copy] but converts all [Map] to [StringMap].
 //
-// Keys are converted using [MapKeyToString].
+// Keys are converted using [MapKeyToString]. Note that distinct keys could be
+// converted to the same string, e.g. 1 and "1", in which case only one of
+// their entries will be kept, arbitrarily. To handle such collisions use
+// [CopyMapsToStringMapsWithPolicy].
 //
 // For in-place conversion use [ConvertStringMapsToMaps].
 func CopyMapsToStringMaps(value Value) Value {
-	value, _ = copy_(value, nil, convertMapsToStringMaps)
+	value, _ = copy_(value, nil, convertMapsToStringMaps, nil, nil)
 	return value
 }
 
+// Like [CopyMapsToStringMaps] but with a [KeyCollisionPolicy] for distinct
+// keys that are converted to the same string.
+//
+// Will return an error only for [KeyCollisionError].
+func CopyMapsToStringMapsWithPolicy(value Value, policy KeyCollisionPolicy) (Value, error) {
+	value, _, err := convertMapsToStringMapsWithPolicy(Copy(value), policy, nil)
+	return value, err
+}
+
 // Deep copy and return a valid ARD value.
 //
 // The input can be a mix of ARD and non-ARD values (e.g. Go structs). The
 // returned value is guaranteed to be valid ARD. This works by reflecting any non-ARD
 // via the provided [*Reflector]. The reflector argument can be nil, in which case a
-// default reflector will be used. To leave non-ARD values as is use [Copy].
+// default reflector will be used (see [SetDefaultReflector]). To leave non-ARD
+// values as is use [Copy]. A [TaggedValue] is replaced by a copy of its Value
+// and a [Raw] is replaced by its decoded value.
+//
+// When [Reflector].CollectErrors is true the copy is returned together with
+// [Errors], in which case values that failed are nil in the copy, at the paths
+// of the errors (see [Errors.Paths]).
 //
 // This function can be used to "canonicalize" values to ARD, for which is should
 // generally be more efficient than calling [Roundtrip].
@@ -49,10 +71,10 @@ func CopyMapsToStringMaps(value Value) Value {
 // [ValidCopyStringMapsToMaps] or [ValidCopyMapsToStringMaps].
 func ValidCopy(value Value, reflector *Reflector) (Value, error) {
 	if reflector == nil {
-		reflector = NewReflector()
+		reflector = DefaultReflector("")
 	}
 
-	return copy_(value, reflector, noConversion)
+	return validCopy(value, reflector, noConversion, false, nil)
 }
 
 // Like [ValidCopy] but converts all [StringMap] to [Map].
@@ -60,10 +82,10 @@ func ValidCopy(value Value, reflector *Reflector) (Value, error) {
 // For in-place conversion use [ConvertStringMapsToMaps].
 func ValidCopyStringMapsToMaps(value Value, reflector *Reflector) (Value, error) {
 	if reflector == nil {
-		reflector = NewReflector()
+		reflector = DefaultReflector("")
 	}
 
-	return copy_(value, reflector, convertStringMapsToMaps)
+	return validCopy(value, reflector, convertStringMapsToMaps, false, nil)
 }
 
 // Like [ValidCopy] but converts all [Map] to [StringMap].
@@ -73,21 +95,77 @@ func ValidCopyStringMapsToMaps(value Value, reflector *Reflector) (Value, error)
 // For in-place conversion use [ConvertMapsToStringMaps].
 func ValidCopyMapsToStringMaps(value Value, reflector *Reflector) (Value, error) {
 	if reflector == nil {
-		reflector = NewReflector()
+		reflector = DefaultReflector("")
+	}
+
+	return validCopy(value, reflector, convertMapsToStringMaps, false, nil)
+}
+
+// Like [Copy] but with a [Guard] for very large values.
+//
+// Will return an error if the guard's limits are exceeded or if its
+// progress function aborts.
+func CopyWithGuard(value Value, guard *Guard) (Value, error) {
+	return copy_(value, nil, noConversion, guard.newState(), nil)
+}
+
+// Like [ValidCopy] but with a [Guard] for very large values.
+//
+// Will return an error if the guard's limits are exceeded or if its
+// progress function aborts.
+func ValidCopyWithGuard(value Value, reflector *Reflector, guard *Guard) (Value, error) {
+	if reflector == nil {
+		reflector = DefaultReflector("")
+	}
+
+	return validCopy(value, reflector, noConversion, false, guard.newState())
+}
+
+// When keepTags is true [TaggedValue] is copied rather than replaced by its
+// Value (for encoders that support tags)
+func validCopy(value Value, reflector *Reflector, mode conversionMode, keepTags bool, guard *guardState) (Value, error) {
+	if reflector.CollectErrors {
+		var errs copyErrors
+		if value, err := copyValue(value, reflector, mode, keepTags, guard, &errs); err == nil {
+			return value, errs.errs.orNil()
+		} else {
+			return nil, err
+		}
+	} else {
+		return copyValue(value, reflector, mode, keepTags, guard, nil)
 	}
+}
 
-	return copy_(value, reflector, convertMapsToStringMaps)
+// When reflector and guard are nil will never return an error.
+//
+// When errs is not nil will collect reflection errors instead of returning them.
+func copy_(value Value, reflector *Reflector, mode conversionMode, guard *guardState, errs *copyErrors) (Value, error) {
+	return copyValue(value, reflector, mode, false, guard, errs)
 }
 
-// When reflector is nil will never return an error.
-func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error) {
+func copyValue(value Value, reflector *Reflector, mode conversionMode, keepTags bool, guard *guardState, errs *copyErrors) (Value, error) {
+	if err := guard.visit(); err != nil {
+		return nil, err
+	}
+
+	switch value.(type) {
+	case Map, StringMap, List:
+		if err := guard.push(); err != nil {
+			return nil, err
+		}
+		defer guard.pop()
+	}
+
 	var err error
 	switch value_ := value.(type) {
 	case Map:
 		if mode == convertMapsToStringMaps {
 			copiedMap := make(StringMap)
 			for key, value__ := range value_ {
-				if copiedMap[MapKeyToString(key)], err = copy_(value__, reflector, mode); err != nil {
+				errs.pushKey(key)
+				copiedMap[MapKeyToString(key)], err = copyValue(value__, reflector, mode, keepTags, guard, errs)
+				errs.pop()
+				if err != nil {
 					return nil, err
 				}
 			}
@@ -95,7 +173,10 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 		} else {
 			copiedMap := make(Map)
 			for key, value__ := range value_ {
-				if copiedMap[key], err = copy_(value__, reflector, mode); err != nil {
+				errs.pushKey(key)
+				copiedMap[key], err = copyValue(value__, reflector, mode, keepTags, guard, errs)
+				errs.pop()
+				if err != nil {
 					return nil, err
 				}
 			}
@@ -106,7 +187,10 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 		if mode == convertStringMapsToMaps {
 			copiedMap := make(Map)
 			for key, value__ := range value_ {
-				if copiedMap[key], err = copy_(value__, reflector, mode); err != nil {
+				errs.pushKey(key)
+				copiedMap[key], err = copyValue(value__, reflector, mode, keepTags, guard, errs)
+				errs.pop()
+				if err != nil {
 					return nil, err
 				}
 			}
@@ -114,7 +198,10 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 		} else {
 			copiedMap := make(StringMap)
 			for key, value__ := range value_ {
-				if copiedMap[key], err = copy_(value__, reflector, mode); err != nil {
+				errs.pushKey(key)
+				copiedMap[key], err = copyValue(value__, reflector, mode, keepTags, guard, errs)
+				errs.pop()
+				if err != nil {
 					return nil, err
 				}
 			}
@@ -124,12 +211,41 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 	case List:
 		copiedList := make(List, len(value_))
 		for index, entry := range value_ {
-			if copiedList[index], err = copy_(entry, reflector, mode); err != nil {
+			errs.pushIndex(index)
+			copiedList[index], err = copyValue(entry, reflector, mode, keepTags, guard, errs)
+			errs.pop()
+			if err != nil {
 				return nil, err
 			}
 		}
 		return copiedList, nil
 
+	case *TaggedValue:
+		if (reflector != nil) && !keepTags {
+			// The tag is discarded
+			return copyValue(value_.Value, reflector, mode, keepTags, guard, errs)
+		}
+
+		if value__, err := copyValue(value_.Value, reflector, mode, keepTags, guard, errs); err == nil {
+			return &TaggedValue{value_.Tag, value__}, nil
+		} else {
+			return nil, err
+		}
+
+	case *Raw:
+		if reflector != nil {
+			if value__, err := value_.Decode(); err == nil {
+				return copyValue(value__, reflector, mode, keepTags, guard, errs)
+			} else if errs != nil {
+				errs.collect(err)
+				return nil, nil
+			} else {
+				return nil, err
+			}
+		}
+
+		return &Raw{value_.Format, append([]byte(nil), value_.Bytes...)}, nil
+
 	default:
 		if reflector != nil {
 			if IsPrimitiveType(value) {
@@ -146,6 +262,9 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 						value, _ = convert(value, mode)
 					}
 					return value, nil
+				} else if errs != nil {
+					errs.collect(err)
+					return nil, nil
 				} else {
 					return nil, err
 				}
@@ -155,3 +274,36 @@ func copy_(value Value, reflector *Reflector, mode conversionMode) (Value, error
 		}
 	}
 }
+
+//
+// copyErrors
+//
+
+// Methods are nil-safe
+type copyErrors struct {
+	path Path
+	errs Errors
+}
+
+func (self *copyErrors) pushKey(key Value) {
+	if self != nil {
+		self.path = self.path.AppendKey(key)
+	}
+}
+
+func (self *copyErrors) pushIndex(index int) {
+	if self != nil {
+		self.path = self.path.AppendList(index)
+	}
+}
+
+func (self *copyErrors) pop() {
+	if self != nil {
+		self.path = self.path[:len(self.path)-1]
+	}
+}
+
+func (self *copyErrors) collect(err error) {
+	path := self.path
+	self.errs = append(self.errs, &PathError{path, fmt.Errorf("%s: %w", path.String(), err)})
+}
@@ -2,46 +2,20 @@ package ard
 
 import (
 	"bytes"
-	"fmt"
 	templatepkg "text/template"
 )
 
 // Decodes supported formats to an ARD [Value].
 //
-// All resulting maps are guaranteed to be [Map] (and not [StringMap]).
+// All resulting maps are guaranteed to be [Map] (and not [StringMap]). Use
+// [Codec.Decode] to choose the map type.
 //
 // If locate is true then a [Locator] will be returned if possible.
 // Currently only YAML decoding supports this feature.
 //
 // Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
 func Decode(code []byte, format string, locate bool) (Value, Locator, error) {
-	switch format {
-	case "yaml":
-		return DecodeYAML(code, locate)
-
-	case "json":
-		value, err := DecodeJSON(code, false)
-		return value, nil, err
-
-	case "xjson":
-		value, err := DecodeXJSON(code, false)
-		return value, nil, err
-
-	case "xml":
-		value, err := DecodeXML(code)
-		return value, nil, err
-
-	case "cbor":
-		value, err := DecodeCBOR(code, false)
-		return value, nil, err
-
-	case "messagepack":
-		value, err := DecodeMessagePack(code, false, false)
-		return value, nil, err
-
-	default:
-		return nil, nil, fmt.Errorf("unsupported format: %q", format)
-	}
+	return Read(bytes.NewReader(code), format, locate)
 }
 
 // Convenience function to parse and render a template and then decode it.
@@ -77,6 +51,13 @@ func DecodeYAML(code []byte, locate bool) (Value, Locator, error) {
 	return ReadYAML(bytes.NewReader(code), locate)
 }
 
+// Like [DecodeYAML] but with [YAMLDecodeOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func DecodeYAMLWithOptions(code []byte, locate bool, options *YAMLDecodeOptions) (Value, Locator, error) {
+	return ReadYAMLWithOptions(bytes.NewReader(code), locate, options)
+}
+
 // Decodes JSON to an ARD [Value].
 //
 // If useStringMaps is true returns maps as [StringMap], otherwise they will
@@ -85,6 +66,13 @@ func DecodeJSON(code []byte, useStringMaps bool) (Value, error) {
 	return ReadJSON(bytes.NewReader(code), useStringMaps)
 }
 
+// Decodes JSON to an ARD [Value].
+//
+// The options argument can be nil, in which case default options will be used.
+func DecodeJSONWithOptions(code []byte, options *JSONDecodeOptions) (Value, error) {
+	return ReadJSONWithOptions(bytes.NewReader(code), options)
+}
+
 // Decodes JSON to an ARD [Value] while interpreting the XJSON extensions.
 //
 // If useStringMaps is true returns maps as [StringMap], otherwise they will
@@ -93,6 +81,11 @@ func DecodeXJSON(code []byte, useStringMaps bool) (Value, error) {
 	return ReadXJSON(bytes.NewReader(code), useStringMaps)
 }
 
+// Like [DecodeXJSON] but for minimal XJSON. See [ReadMinimalXJSON].
+func DecodeMinimalXJSON(code []byte, useStringMaps bool) (Value, error) {
+	return ReadMinimalXJSON(bytes.NewReader(code), useStringMaps)
+}
+
 // Decodes XML to an ARD [Value].
 //
 // A specific schema is expected (currently undocumented).
@@ -118,3 +111,11 @@ func DecodeCBOR(code []byte, base64 bool) (Value, error) {
 func DecodeMessagePack(code []byte, base64 bool, useStringMaps bool) (Value, error) {
 	return ReadMessagePack(bytes.NewReader(code), base64, useStringMaps)
 }
+
+// Like [DecodeMessagePack] but with [MessagePackDecodeOptions]. See
+// [ReadMessagePackWithOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func DecodeMessagePackWithOptions(code []byte, base64 bool, options *MessagePackDecodeOptions) (Value, error) {
+	return ReadMessagePackWithOptions(bytes.NewReader(code), base64, options)
+}
@@ -0,0 +1,193 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//
+// Severity
+//
+
+type Severity int
+
+const (
+	SeverityError   Severity = 0
+	SeverityWarning Severity = 1
+)
+
+// ([fmt.Stringer] interface)
+func (self Severity) String() string {
+	switch self {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("%d", int(self))
+	}
+}
+
+//
+// Diagnostic
+//
+
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Path     Path // nil if unknown
+	Line     int  // -1 if unknown
+	Column   int  // -1 if unknown
+
+	// Additional information for warnings, as alternating keys and values
+	// (see [WarnFunc])
+	KeysAndValues []any
+
+	// The original error for errors
+	Err error
+}
+
+// ([fmt.Stringer] interface)
+func (self *Diagnostic) String() string {
+	var builder strings.Builder
+	builder.WriteString(self.Severity.String())
+	if self.Line != -1 {
+		builder.WriteString(fmt.Sprintf(" (%d:%d)", self.Line, self.Column))
+	}
+	builder.WriteString(": ")
+	builder.WriteString(self.Message)
+	return builder.String()
+}
+
+//
+// Diagnostics
+//
+
+// Graded feedback collected from an operation, e.g. for linters and dry-run
+// validation. See [Diagnose].
+type Diagnostics []*Diagnostic
+
+// Runs the function with a [WarnFunc] that records warnings, and then records
+// the errors it returns. [Errors] are flattened so that each error gets its
+// own diagnostic.
+//
+// The warn argument can be passed to [Reflector].Warn (warnings emitted via
+// [SetWarnFunc] are not recorded). For collecting all errors, rather than just
+// the first one, set [Reflector].CollectErrors to true.
+//
+// The locator argument can be nil, otherwise it will be used to add positions
+// to diagnostics that have paths.
+func Diagnose(locator Locator, f func(warn WarnFunc) error) Diagnostics {
+	var diagnostics Diagnostics
+
+	err := f(func(message string, keysAndValues ...any) {
+		diagnostic := Diagnostic{
+			Severity:      SeverityWarning,
+			Message:       message,
+			Line:          -1,
+			Column:        -1,
+			KeysAndValues: keysAndValues,
+		}
+
+		// Our warnings use the "path" key with a string in Path.String format
+		for index := 0; index+1 < len(keysAndValues); index += 2 {
+			if keysAndValues[index] == "path" {
+				if path, ok := keysAndValues[index+1].(string); ok {
+					if path_, err := ParsePath(path); err == nil {
+						diagnostic.Path = path_
+						diagnostic.Message = path + " " + message
+					}
+				}
+				break
+			}
+		}
+
+		diagnostics = append(diagnostics, locateDiagnostic(&diagnostic, locator))
+	})
+
+	if err != nil {
+		var errs Errors
+		if !errors.As(err, &errs) {
+			errs = Errors{err}
+		}
+
+		for _, err := range errs {
+			diagnostic := Diagnostic{
+				Severity: SeverityError,
+				Message:  err.Error(),
+				Line:     -1,
+				Column:   -1,
+				Err:      err,
+			}
+
+			var coercionError *CoercionError
+			var pathError interface{ getPath() Path }
+			if errors.As(err, &coercionError) {
+				diagnostic.Path = coercionError.Path
+				diagnostic.Line = coercionError.Line
+				diagnostic.Column = coercionError.Column
+			} else if errors.As(err, &pathError) {
+				diagnostic.Path = pathError.getPath()
+			}
+
+			diagnostics = append(diagnostics, locateDiagnostic(&diagnostic, locator))
+		}
+	}
+
+	return diagnostics
+}
+
+// Returns only the diagnostics with [SeverityError].
+func (self Diagnostics) Errors() Diagnostics {
+	return self.filter(SeverityError)
+}
+
+// Returns only the diagnostics with [SeverityWarning].
+func (self Diagnostics) Warnings() Diagnostics {
+	return self.filter(SeverityWarning)
+}
+
+// Returns true if there is at least one diagnostic with [SeverityError].
+func (self Diagnostics) HasErrors() bool {
+	for _, diagnostic := range self {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ([fmt.Stringer] interface)
+func (self Diagnostics) String() string {
+	var builder strings.Builder
+	for index, diagnostic := range self {
+		if index > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(diagnostic.String())
+	}
+	return builder.String()
+}
+
+func (self Diagnostics) filter(severity Severity) Diagnostics {
+	var diagnostics Diagnostics
+	for _, diagnostic := range self {
+		if diagnostic.Severity == severity {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics
+}
+
+// Utils
+
+func locateDiagnostic(diagnostic *Diagnostic, locator Locator) *Diagnostic {
+	if (locator != nil) && (diagnostic.Path != nil) && (diagnostic.Line == -1) {
+		if line, column, ok := locator.Locate(diagnostic.Path...); ok {
+			diagnostic.Line = line
+			diagnostic.Column = column
+		}
+	}
+	return diagnostic
+}
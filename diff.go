@@ -0,0 +1,427 @@
+package ard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compares two values structurally, returning a [DiffReport] of the changes
+// needed to turn a into b. [Map] and [StringMap] are compared key by key,
+// recursively. How lists are compared is determined by [DiffOptions].Lists.
+//
+// Map entries are reported sorted by key according to [Compare], so that
+// reports are deterministic.
+//
+// The options argument can be nil, in which case defaults will be used.
+func Diff(a Value, b Value, options *DiffOptions) DiffReport {
+	if options == nil {
+		options = new(DiffOptions)
+	}
+
+	differ := differ{options: options}
+	differ.diff(a, b, nil, nil)
+	return differ.report
+}
+
+//
+// DiffListMode
+//
+
+type DiffListMode int
+
+const (
+	// List elements are compared index by index. Extra elements at the end
+	// are added or removed.
+	DiffListsByIndex DiffListMode = 0
+
+	// List elements are aligned via their longest common subsequence (using
+	// [Equals]). Elements not in the subsequence are added or removed, thus
+	// insertions in the middle of a list do not affect the elements after
+	// them.
+	DiffListsByLCS DiffListMode = 1
+
+	// List elements that are maps are matched by the value of their first
+	// key in [DiffOptions].ListKeys, and matched elements are compared
+	// recursively. Matched elements that changed their relative order are
+	// reported as moved. Elements without a key are aligned as in
+	// [DiffListsByLCS].
+	DiffListsByKey DiffListMode = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self DiffListMode) String() string {
+	switch self {
+	case DiffListsByIndex:
+		return "index"
+	case DiffListsByLCS:
+		return "lcs"
+	case DiffListsByKey:
+		return "key"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// DiffOptions
+//
+
+type DiffOptions struct {
+	Lists DiffListMode
+
+	// For [DiffListsByKey]. When empty defaults to "id" and "name".
+	ListKeys []string
+
+	// When true map keys with nil values are treated as if they were absent,
+	// as in JSON Merge Patch and many configuration systems. Thus a key that
+	// changes from nil to absent is not reported, and a key that changes from
+	// a value to nil is reported as [DiffRemoved].
+	//
+	// Otherwise a key with a nil value is distinct from an absent key, and a
+	// change between nil and a value is reported as [DiffChanged].
+	NilMeansAbsent bool
+}
+
+var defaultDiffListKeys = []string{"id", "name"}
+
+//
+// DiffChangeType
+//
+
+type DiffChangeType int
+
+const (
+	// Value exists only in b
+	DiffAdded DiffChangeType = 0
+
+	// Value exists only in a
+	DiffRemoved DiffChangeType = 1
+
+	// Value differs between a and b
+	DiffChanged DiffChangeType = 2
+
+	// List element was matched by key but its relative order changed
+	DiffMoved DiffChangeType = 3
+)
+
+// ([fmt.Stringer] interface)
+func (self DiffChangeType) String() string {
+	switch self {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	case DiffMoved:
+		return "moved"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// DiffChange
+//
+
+type DiffChange struct {
+	Type DiffChangeType
+
+	// Location in a (nil for DiffAdded)
+	Path Path
+
+	// Location in b (nil for DiffRemoved). It differs from Path when list
+	// elements are aligned by LCS or by key.
+	NewPath Path
+
+	Old Value // nil for DiffAdded
+	New Value // nil for DiffRemoved
+}
+
+// ([fmt.Stringer] interface)
+func (self *DiffChange) String() string {
+	switch self.Type {
+	case DiffAdded:
+		return fmt.Sprintf("%s %s", self.Type, self.NewPath)
+	case DiffRemoved, DiffChanged:
+		return fmt.Sprintf("%s %s", self.Type, self.Path)
+	default:
+		return fmt.Sprintf("%s %s to %s", self.Type, self.Path, self.NewPath)
+	}
+}
+
+//
+// DiffReport
+//
+
+type DiffReport []*DiffChange
+
+// ([fmt.Stringer] interface)
+func (self DiffReport) String() string {
+	var builder strings.Builder
+	for index, change := range self {
+		if index > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(change.String())
+	}
+	return builder.String()
+}
+
+// Filters the report by change type.
+func (self DiffReport) Filter(type_ DiffChangeType) DiffReport {
+	var report DiffReport
+	for _, change := range self {
+		if change.Type == type_ {
+			report = append(report, change)
+		}
+	}
+	return report
+}
+
+//
+// differ
+//
+
+type differ struct {
+	options *DiffOptions
+	report  DiffReport
+}
+
+func (self *differ) diff(a Value, b Value, aPath Path, bPath Path) {
+	switch a_ := a.(type) {
+	case Map:
+		if _, ok := b.(Map); ok {
+			self.diffMaps(a_, b, aPath, bPath)
+			return
+		}
+
+	case StringMap:
+		if _, ok := b.(StringMap); ok {
+			self.diffMaps(a_, b, aPath, bPath)
+			return
+		}
+
+	case List:
+		if b_, ok := b.(List); ok {
+			switch self.options.Lists {
+			case DiffListsByLCS:
+				self.diffListsByLCS(a_, b_, allIndexes(len(a_)), allIndexes(len(b_)), aPath, bPath)
+			case DiffListsByKey:
+				self.diffListsByKey(a_, b_, aPath, bPath)
+			default:
+				self.diffListsByIndex(a_, b_, aPath, bPath)
+			}
+			return
+		}
+	}
+
+	if !Equals(a, b) {
+		self.record(DiffChanged, aPath, bPath, a, b)
+	}
+}
+
+func (self *differ) diffMaps(a Value, b Value, aPath Path, bPath Path) {
+	aEntries := sortedCompareEntries(a)
+	bEntries := sortedCompareEntries(b)
+	if self.options.NilMeansAbsent {
+		aEntries = withoutNilEntries(aEntries)
+		bEntries = withoutNilEntries(bEntries)
+	}
+
+	// Merge-join the sorted entries
+	aIndex, bIndex := 0, 0
+	for (aIndex < len(aEntries)) || (bIndex < len(bEntries)) {
+		var comparison int
+		if aIndex == len(aEntries) {
+			comparison = 1
+		} else if bIndex == len(bEntries) {
+			comparison = -1
+		} else {
+			comparison = Compare(aEntries[aIndex].key, bEntries[bIndex].key)
+		}
+
+		if comparison < 0 {
+			aEntry := aEntries[aIndex]
+			self.record(DiffRemoved, aPath.AppendKey(aEntry.key), nil, aEntry.value, nil)
+			aIndex++
+		} else if comparison > 0 {
+			bEntry := bEntries[bIndex]
+			self.record(DiffAdded, nil, bPath.AppendKey(bEntry.key), nil, bEntry.value)
+			bIndex++
+		} else {
+			aEntry, bEntry := aEntries[aIndex], bEntries[bIndex]
+			self.diff(aEntry.value, bEntry.value, aPath.AppendKey(aEntry.key), bPath.AppendKey(bEntry.key))
+			aIndex++
+			bIndex++
+		}
+	}
+}
+
+func (self *differ) diffListsByIndex(a List, b List, aPath Path, bPath Path) {
+	for index, aElement := range a {
+		if index < len(b) {
+			self.diff(aElement, b[index], aPath.AppendList(index), bPath.AppendList(index))
+		} else {
+			self.record(DiffRemoved, aPath.AppendList(index), nil, aElement, nil)
+		}
+	}
+
+	for index := len(a); index < len(b); index++ {
+		self.record(DiffAdded, nil, bPath.AppendList(index), nil, b[index])
+	}
+}
+
+// Only the elements at the provided indexes are aligned.
+func (self *differ) diffListsByLCS(a List, b List, aIndexes []int, bIndexes []int, aPath Path, bPath Path) {
+	// Lengths of common subsequences of the suffixes
+	lengths := make([][]int, len(aIndexes)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(bIndexes)+1)
+	}
+	for i := len(aIndexes) - 1; i >= 0; i-- {
+		for j := len(bIndexes) - 1; j >= 0; j-- {
+			if Equals(a[aIndexes[i]], b[bIndexes[j]]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for (i < len(aIndexes)) || (j < len(bIndexes)) {
+		if (i < len(aIndexes)) && (j < len(bIndexes)) && Equals(a[aIndexes[i]], b[bIndexes[j]]) {
+			i++
+			j++
+		} else if (j == len(bIndexes)) || ((i < len(aIndexes)) && (lengths[i+1][j] >= lengths[i][j+1])) {
+			self.record(DiffRemoved, aPath.AppendList(aIndexes[i]), nil, a[aIndexes[i]], nil)
+			i++
+		} else {
+			self.record(DiffAdded, nil, bPath.AppendList(bIndexes[j]), nil, b[bIndexes[j]])
+			j++
+		}
+	}
+}
+
+func (self *differ) diffListsByKey(a List, b List, aPath Path, bPath Path) {
+	listKeys := self.options.ListKeys
+	if len(listKeys) == 0 {
+		listKeys = defaultDiffListKeys
+	}
+
+	// Index b's keyed elements (first occurrence wins)
+	bKeyed := make(map[any]int)
+	var bUnkeyed []int
+	for index, element := range b {
+		if key, ok := getDiffListKey(element, listKeys); ok {
+			if _, ok := bKeyed[key]; !ok {
+				bKeyed[key] = index
+				continue
+			}
+		}
+		bUnkeyed = append(bUnkeyed, index)
+	}
+
+	// Match a's keyed elements
+	type match struct{ aIndex, bIndex int }
+	var matches []match
+	var aUnkeyed []int
+	bMatched := make(map[int]struct{})
+	for index, element := range a {
+		if key, ok := getDiffListKey(element, listKeys); ok {
+			if bIndex, ok := bKeyed[key]; ok {
+				if _, ok := bMatched[bIndex]; !ok {
+					bMatched[bIndex] = struct{}{}
+					matches = append(matches, match{index, bIndex})
+					continue
+				}
+			}
+		}
+		aUnkeyed = append(aUnkeyed, index)
+	}
+
+	// Unmatched keyed elements of b are aligned with the unkeyed ones
+	for _, index := range bKeyed {
+		if _, ok := bMatched[index]; !ok {
+			bUnkeyed = append(bUnkeyed, index)
+		}
+	}
+	sort.Ints(bUnkeyed)
+
+	// Matched elements not in the longest increasing subsequence of b
+	// indexes (in a order) have moved
+	var stable []bool
+	if len(matches) > 0 {
+		lengths := make([]int, len(matches))
+		previous := make([]int, len(matches))
+		best := 0
+		for i := range matches {
+			lengths[i] = 1
+			previous[i] = -1
+			for j := 0; j < i; j++ {
+				if (matches[j].bIndex < matches[i].bIndex) && (lengths[j]+1 > lengths[i]) {
+					lengths[i] = lengths[j] + 1
+					previous[i] = j
+				}
+			}
+			if lengths[i] > lengths[best] {
+				best = i
+			}
+		}
+
+		stable = make([]bool, len(matches))
+		for i := best; i != -1; i = previous[i] {
+			stable[i] = true
+		}
+	}
+
+	for index, match := range matches {
+		aElementPath := aPath.AppendList(match.aIndex)
+		bElementPath := bPath.AppendList(match.bIndex)
+		if !stable[index] {
+			self.record(DiffMoved, aElementPath, bElementPath, a[match.aIndex], b[match.bIndex])
+		}
+		self.diff(a[match.aIndex], b[match.bIndex], aElementPath, bElementPath)
+	}
+
+	self.diffListsByLCS(a, b, aUnkeyed, bUnkeyed, aPath, bPath)
+}
+
+func (self *differ) record(type_ DiffChangeType, aPath Path, bPath Path, old Value, new Value) {
+	self.report = append(self.report, &DiffChange{type_, aPath, bPath, old, new})
+}
+
+// Utils
+
+// Filters in place
+func withoutNilEntries(entries []compareEntry) []compareEntry {
+	entries_ := entries[:0]
+	for _, entry := range entries {
+		if entry.value != nil {
+			entries_ = append(entries_, entry)
+		}
+	}
+	return entries_
+}
+
+func getDiffListKey(element Value, listKeys []string) (any, bool) {
+	for _, listKey := range listKeys {
+		if value, _, ok, _ := getFromMap(element, listKey, false); ok {
+			if (value != nil) && IsPrimitiveType(value) && IsSimpleKey(value) {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func allIndexes(length int) []int {
+	indexes := make([]int, length)
+	for index := range indexes {
+		indexes[index] = index
+	}
+	return indexes
+}
@@ -0,0 +1,363 @@
+package ard
+
+import (
+	"bytes"
+	contextpkg "context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tliron/exturl"
+	"github.com/tliron/kutil/util"
+)
+
+var (
+	ErrTransactionDone     = errors.New("transaction already committed or rolled back")
+	ErrTransactionConflict = errors.New("document changed since transaction began")
+)
+
+//
+// Document
+//
+
+// A shared ARD [Value] that can be safely edited via transactions. See
+// [Document.Begin].
+//
+// The value returned by [Document.Value] must be treated as read-only. All
+// edits should happen in a [Transaction].
+type Document struct {
+	// Source URL. Can be nil.
+	URL exturl.URL
+
+	// Format of the source, used as the default for writing. Can be empty.
+	Format string
+
+	// Locator for the source. Can be nil. Note that it refers to the value as
+	// originally read, not as changed via transactions.
+	Locator Locator
+
+	// Decode options used for the source, also used by [Document.Reload]. Can
+	// be nil, in which case default options will be used.
+	Options *DocumentOptions
+
+	value    Value
+	version  uint64
+	modified time.Time
+	lock     sync.RWMutex
+}
+
+func NewDocument(value Value) *Document {
+	return &Document{value: value, modified: now()}
+}
+
+// Reads and decodes supported formats to a [Document]. Calls [Read].
+func ReadDocument(reader io.Reader, format string, locate bool) (*Document, error) {
+	return ReadDocumentWithOptions(reader, format, &DocumentOptions{Locate: locate})
+}
+
+// Like [ReadDocument] but with [DocumentOptions], which are recorded in the
+// document.
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadDocumentWithOptions(reader io.Reader, format string, options *DocumentOptions) (*Document, error) {
+	if value, locator, err := options.read(reader, format); err == nil {
+		return &Document{
+			Format:   format,
+			Locator:  locator,
+			Options:  options,
+			value:    value,
+			modified: now(),
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Reads and decodes from a URL to a [Document], recording the URL and the
+// detected format. See [ReadURL].
+func ReadURLDocument(context contextpkg.Context, url exturl.URL, format string, forceFormat bool, locate bool) (*Document, error) {
+	return ReadURLDocumentWithOptions(context, url, format, forceFormat, &DocumentOptions{Locate: locate})
+}
+
+// Like [ReadURLDocument] but with [DocumentOptions], which are recorded in the
+// document.
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadURLDocumentWithOptions(context contextpkg.Context, url exturl.URL, format string, forceFormat bool, options *DocumentOptions) (*Document, error) {
+	format = getURLFormat(url, format, forceFormat)
+	if value, locator, err := options.readURL(context, url, format); err == nil {
+		return &Document{
+			URL:      url,
+			Format:   format,
+			Locator:  locator,
+			Options:  options,
+			value:    value,
+			modified: now(),
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Encodes the current value and writes it to an [io.Writer]. Calls [Write].
+//
+// If format is empty then the document's format will be used.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+//
+// This function is safe to call concurrently.
+func (self *Document) Write(writer io.Writer, format string, reflector *Reflector) error {
+	if format == "" {
+		if format = self.Format; format == "" {
+			return errors.New("document has no format")
+		}
+	}
+
+	return Write(self.Value(), writer, format, reflector)
+}
+
+// Encodes the current value in the document's format and writes it back to
+// the document's URL. Currently only file URLs are supported.
+//
+// The value is fully encoded before the file is written, thus an encoding
+// error leaves the file as is.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+//
+// This function is safe to call concurrently.
+func (self *Document) Save(reflector *Reflector) error {
+	if fileUrl, ok := self.URL.(*exturl.FileURL); ok {
+		var buffer bytes.Buffer
+		if err := self.Write(&buffer, "", reflector); err == nil {
+			return os.WriteFile(fileUrl.Path, buffer.Bytes(), 0666)
+		} else {
+			return err
+		}
+	} else if self.URL == nil {
+		return errors.New("document has no URL")
+	} else {
+		return fmt.Errorf("unsupported URL for saving: %s", self.URL.String())
+	}
+}
+
+// Reads the document's URL again in the document's format and with its
+// [DocumentOptions], replacing the current value (and the locator) as if by a
+// committed [Transaction]. Thus transactions that began before will conflict.
+//
+// This function is safe to call concurrently.
+func (self *Document) Reload(context contextpkg.Context) error {
+	if self.URL == nil {
+		return errors.New("document has no URL")
+	} else if self.Format == "" {
+		return errors.New("document has no format")
+	}
+
+	if value, locator, err := self.Options.readURL(context, self.URL, self.Format); err == nil {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.value = value
+		self.Locator = locator
+		self.version++
+		self.modified = now()
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Returns the current value. It must be treated as read-only.
+//
+// This function is safe to call concurrently.
+func (self *Document) Value() Value {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.value
+}
+
+// Returns when the document was created or read, or when a transaction was
+// last committed to it, according to the package-wide [Clock].
+//
+// This function is safe to call concurrently.
+func (self *Document) Modified() time.Time {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.modified
+}
+
+// Begins a transaction. Edits in the transaction are not visible in the
+// document until [Transaction.Commit] is called.
+//
+// This function is safe to call concurrently.
+func (self *Document) Begin() *Transaction {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return &Transaction{
+		document: self,
+		value:    self.value,
+		version:  self.version,
+	}
+}
+
+//
+// DocumentOptions
+//
+
+// Decode options for a [Document].
+type DocumentOptions struct {
+	// When true a [Locator] will be created if possible (see [Read]).
+	Locate bool
+
+	// Used for "yaml". Can be nil.
+	YAML *YAMLDecodeOptions
+
+	// Used for "json". Can be nil.
+	JSON *JSONDecodeOptions
+
+	// Used for "messagepack". Can be nil.
+	MessagePack *MessagePackDecodeOptions
+}
+
+// Nil-safe
+func (self *DocumentOptions) read(reader io.Reader, format string) (Value, Locator, error) {
+	if self == nil {
+		return Read(reader, format, false)
+	}
+
+	var value Value
+	var err error
+	switch format {
+	case "yaml":
+		return ReadYAMLWithOptions(reader, self.Locate, self.YAML)
+
+	case "json":
+		if self.JSON == nil {
+			return Read(reader, format, self.Locate)
+		}
+		value, err = ReadJSONWithOptions(reader, self.JSON)
+
+	case "messagepack":
+		if self.MessagePack == nil {
+			return Read(reader, format, self.Locate)
+		}
+		value, err = ReadMessagePackWithOptions(reader, false, self.MessagePack)
+
+	default:
+		return Read(reader, format, self.Locate)
+	}
+
+	return value, nil, err
+}
+
+// Nil-safe
+func (self *DocumentOptions) readURL(context contextpkg.Context, url exturl.URL, format string) (Value, Locator, error) {
+	if reader, err := url.Open(context); err == nil {
+		reader = util.NewContextualReadCloser(context, reader)
+		defer reader.Close()
+
+		return self.read(reader, format)
+	} else {
+		return nil, nil, err
+	}
+}
+
+//
+// Transaction
+//
+
+// A transaction view of a [Document].
+//
+// Transactions are copy-on-write: the document's value is deep-copied only when
+// it is first accessed for editing via [Transaction.Node] or replaced via
+// [Transaction.Set]. Thus partially-applied edits never leak into the shared
+// document.
+//
+// A transaction is not itself safe for concurrent use.
+type Transaction struct {
+	document *Document
+	value    Value
+	version  uint64
+	copied   bool
+	done     bool
+}
+
+// Returns the transaction's value. If no edits have been made yet this is the
+// document's value, which must be treated as read-only.
+func (self *Transaction) Value() Value {
+	return self.value
+}
+
+// Returns a [Node] for editing the transaction's value.
+//
+// Note that [Node.Set] cannot replace the root value itself. Use
+// [Transaction.Set] for that.
+func (self *Transaction) Node() *Node {
+	if self.done {
+		return NoNode
+	}
+
+	if !self.copied {
+		self.value = Copy(self.value)
+		self.copied = true
+	}
+
+	return With(self.value)
+}
+
+// Replaces the transaction's value.
+func (self *Transaction) Set(value Value) error {
+	if self.done {
+		return ErrTransactionDone
+	}
+
+	self.value = value
+	self.copied = true
+	return nil
+}
+
+// Applies the transaction's value to the document atomically.
+//
+// Returns [ErrTransactionConflict] if another transaction was committed to the
+// document since this one began, in which case the document is not changed.
+// Either way the transaction is done and cannot be used again.
+func (self *Transaction) Commit() error {
+	if self.done {
+		return ErrTransactionDone
+	}
+
+	self.done = true
+
+	if !self.copied {
+		// Nothing changed
+		return nil
+	}
+
+	self.document.lock.Lock()
+	defer self.document.lock.Unlock()
+
+	if self.document.version != self.version {
+		return ErrTransactionConflict
+	}
+
+	self.document.value = self.value
+	self.document.version++
+	self.document.modified = now()
+	return nil
+}
+
+// Discards the transaction's edits. The transaction is done and cannot be used
+// again.
+//
+// Calling it after [Transaction.Commit] has no effect, so it can be safely
+// deferred.
+func (self *Transaction) Rollback() {
+	if !self.done {
+		self.done = true
+		self.value = nil
+	}
+}
@@ -0,0 +1,88 @@
+package ard
+
+import (
+	contextpkg "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tliron/exturl"
+)
+
+func TestDocumentOptionsReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "document.json")
+	if err := os.WriteFile(path, []byte(`{"a":1}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	urlContext := exturl.NewContext()
+	defer urlContext.Release()
+
+	context := contextpkg.Background()
+	options := DocumentOptions{JSON: &JSONDecodeOptions{UseStringMaps: true}}
+	document, err := ReadURLDocumentWithOptions(context, urlContext.NewFileURL(path), "", false, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := document.Value().(StringMap); !ok {
+		t.Fatalf("expected StringMap, got %T", document.Value())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"b":2}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := document.Begin()
+	if err := transaction.Set(StringMap{"c": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := document.Reload(context); err != nil {
+		t.Fatal(err)
+	}
+
+	if map_, ok := document.Value().(StringMap); !ok {
+		t.Fatalf("expected StringMap after reload, got %T", document.Value())
+	} else if _, ok := map_["b"]; !ok {
+		t.Errorf("expected reloaded value, got %#v", map_)
+	}
+
+	if err := transaction.Commit(); err == nil {
+		t.Error("expected conflict for transaction that began before reload")
+	}
+}
+
+func TestDocumentSaveEncodeError(t *testing.T) {
+	content := []byte(`{"a":1}`)
+	path := filepath.Join(t.TempDir(), "document.json")
+	if err := os.WriteFile(path, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	urlContext := exturl.NewContext()
+	defer urlContext.Release()
+
+	document, err := ReadURLDocument(contextpkg.Background(), urlContext.NewFileURL(path), "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := document.Begin()
+	if err := transaction.Set(Map{"a": make(chan int)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := document.Save(nil); err == nil {
+		t.Fatal("expected encoding error")
+	}
+
+	if saved, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(saved) != string(content) {
+		t.Errorf("file changed after failed save: %q", saved)
+	}
+}
@@ -0,0 +1,133 @@
+package ard
+
+import (
+	"io"
+
+	"github.com/tliron/yamlkeys"
+	"gopkg.in/yaml.v3"
+)
+
+//
+// Effective
+//
+
+// A read-only snapshot of the effective value of a set of merged documents,
+// together with the [Provenance] of its values. It answers the question: "what
+// is the effective configuration and where did each part come from?"
+//
+// The snapshot cannot be changed: it is isolated from the documents it was
+// created from, and its accessors return copies.
+type Effective struct {
+	value      Value
+	provenance Provenance
+}
+
+// Deep merges a set of named documents as [CombineDocuments] does (with
+// rootKeyPerDoc false) and returns a snapshot of the result.
+//
+// The documents are not modified.
+func NewEffective(docs map[string]Value) (*Effective, error) {
+	if value, provenance, err := CombineDocuments(docs, false); err == nil {
+		return &Effective{value, provenance}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns a [Copy] of the effective value, which can be freely modified.
+func (self *Effective) Value() Value {
+	return Copy(self.value)
+}
+
+// Returns a copy of the [Provenance] of the effective value.
+func (self *Effective) Provenance() Provenance {
+	provenance := make(Provenance, len(self.provenance))
+	for path, name := range self.provenance {
+		provenance[path] = name
+	}
+	return provenance
+}
+
+// Encodes the effective value together with its [Provenance] to a supported
+// format (see [SupportedFormats]) and writes it to an [io.Writer].
+//
+// For "yaml" the provenance is written as line comments, e.g.
+// "port: 8080 # from: base", on those map entries that came from a different
+// document than their containing map. Map keys are sorted.
+//
+// For other formats, which do not support comments, a [StringMap] is written
+// with the value under "value" and the provenance as a sidecar [StringMap]
+// under "provenance".
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func (self *Effective) Export(writer io.Writer, format string, reflector *Reflector) error {
+	if format != "yaml" {
+		provenance := make(StringMap, len(self.provenance))
+		for path, name := range self.provenance {
+			provenance[path] = name
+		}
+
+		return Write(StringMap{
+			"value":      self.value,
+			"provenance": provenance,
+		}, writer, format, reflector)
+	}
+
+	node, err := ToYAMLDocumentNodeWithOptions(self.value, &YAMLNodeOptions{SortKeys: true}, reflector)
+	if err != nil {
+		return err
+	}
+
+	self.annotateYAML(node, nil, "")
+
+	encoder := yaml.NewEncoder(writer)
+	if err := encoder.Encode(node); err == nil {
+		return encoder.Close()
+	} else {
+		return err
+	}
+}
+
+// Utils
+
+// Comments are only added where the provenance changes, to avoid noise
+func (self *Effective) annotateYAML(node *yaml.Node, path Path, parentName string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			self.annotateYAML(child, path, parentName)
+		}
+
+	case yaml.MappingNode:
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			keyNode := node.Content[index]
+			valueNode := node.Content[index+1]
+
+			key, _, err := yamlkeys.DecodeKeyNode(keyNode)
+			if err != nil {
+				continue
+			}
+
+			path_ := path.AppendKey(key)
+			name, ok := self.provenance.Get(path_)
+			if !ok {
+				name = parentName
+			} else if name != parentName {
+				comment := "from: " + name
+				if valueNode.Kind == yaml.ScalarNode {
+					valueNode.LineComment = comment
+				} else {
+					keyNode.LineComment = comment
+				}
+			}
+
+			self.annotateYAML(valueNode, path_, name)
+		}
+
+	case yaml.SequenceNode:
+		for index, child := range node.Content {
+			self.annotateYAML(child, path.AppendList(index), parentName)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ard
+
+import (
+	"fmt"
+)
+
+// Checks whether a value can be encoded to a supported format (see
+// [SupportedFormats]) without errors and without silently degrading values,
+// so that callers can fail fast or fix up values before encoding. Returns
+// nil if there are no issues.
+//
+// Returned [Errors] contain a [*PathError] for every issue found, in
+// traversal order:
+//
+//   - Non-ARD values that cannot be converted by the default reflector for
+//     the format (see [DefaultReflector]), e.g. channels and functions.
+//   - For "json", NaN and infinite floats (but see
+//     [JSONEncodeOptions].SpecialFloatStrings).
+//   - [*TaggedValue] for formats other than "yaml", which would encode it as
+//     a struct.
+//   - [*Raw] for formats other than "json" and "xjson", which would either
+//     fail or encode it as a struct.
+//
+// Map keys are not checked. Note that lossy conversions that are not errors,
+// such as non-string map keys in JSON, are not reported. Use [ConvertFormat]
+// to find those.
+func CheckEncodable(value Value, format string) Errors {
+	if !IsSupportedFormat(format) {
+		return Errors{fmt.Errorf("unsupported format: %q", format)}
+	}
+
+	checker := encodableChecker{
+		format:    format,
+		reflector: DefaultReflector(format),
+	}
+	checker.check(value, nil)
+	return checker.errs
+}
+
+// Utils
+
+type encodableChecker struct {
+	format    string
+	reflector *Reflector
+	errs      Errors
+}
+
+func (self *encodableChecker) check(value Value, path Path) {
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			self.check(element, path.AppendKey(key))
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			self.check(element, path.AppendField(key))
+		}
+
+	case List:
+		for index, element := range value_ {
+			self.check(element, path.AppendList(index))
+		}
+
+	case float64:
+		self.checkFloat(value_, path)
+
+	case float32:
+		self.checkFloat(float64(value_), path)
+
+	case *TaggedValue:
+		if self.format == "yaml" {
+			self.check(value_.Value, path)
+		} else {
+			self.errs = append(self.errs, newPathErrorf(path, "has a tag, which is not supported by %s: %s", self.format, value_.Tag))
+		}
+
+	case *Raw:
+		if (self.format != "json") && (self.format != "xjson") {
+			self.errs = append(self.errs, newPathErrorf(path, "is raw %s, which is not supported by %s", value_.Format, self.format))
+		}
+
+	default:
+		if IsPrimitiveType(value) {
+			return
+		}
+
+		if value__, err := validCopy(value, self.reflector, noConversion, false, nil); err == nil {
+			if !IsPrimitiveType(value__) {
+				self.check(value__, path)
+			}
+		} else {
+			self.errs = append(self.errs, prependPathError(path, err))
+		}
+	}
+}
+
+func (self *encodableChecker) checkFloat(float float64, path Path) {
+	if self.format == "json" {
+		if token, ok := formatSpecialFloat(float); ok {
+			self.errs = append(self.errs, newPathErrorf(path, "is not supported by JSON: %s", token))
+		}
+	}
+}
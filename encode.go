@@ -0,0 +1,74 @@
+package ard
+
+import (
+	"bytes"
+)
+
+// Encodes an ARD [Value] to supported formats.
+//
+// Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func Encode(value Value, format string, reflector *Reflector) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := Write(value, &buffer, format, reflector); err == nil {
+		return buffer.Bytes(), nil
+	} else {
+		return nil, err
+	}
+}
+
+// Encodes an ARD [Value] to YAML with [YAMLNodeOptions]. See
+// [WriteYAMLWithOptions].
+//
+// The reflector and options arguments can be nil, in which case defaults will
+// be used.
+func EncodeYAMLWithOptions(value Value, reflector *Reflector, options *YAMLNodeOptions) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := WriteYAMLWithOptions(value, &buffer, reflector, options); err == nil {
+		return buffer.Bytes(), nil
+	} else {
+		return nil, err
+	}
+}
+
+// Encodes an ARD [Value] to JSON with [JSONEncodeOptions]. See
+// [WriteJSONWithOptions].
+//
+// The reflector and options arguments can be nil, in which case defaults will
+// be used.
+func EncodeJSONWithOptions(value Value, reflector *Reflector, options *JSONEncodeOptions) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := WriteJSONWithOptions(value, &buffer, reflector, options); err == nil {
+		return buffer.Bytes(), nil
+	} else {
+		return nil, err
+	}
+}
+
+// Encodes an ARD [Value] to CBOR with [CBOREncodeOptions]. See
+// [WriteCBORWithOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func EncodeCBORWithOptions(value Value, options *CBOREncodeOptions) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := WriteCBORWithOptions(value, &buffer, options); err == nil {
+		return buffer.Bytes(), nil
+	} else {
+		return nil, err
+	}
+}
+
+// Encodes an ARD [Value] to MessagePack with [MessagePackEncodeOptions]. See
+// [WriteMessagePackWithOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func EncodeMessagePackWithOptions(value Value, options *MessagePackEncodeOptions) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := WriteMessagePackWithOptions(value, &buffer, options); err == nil {
+		return buffer.Bytes(), nil
+	} else {
+		return nil, err
+	}
+}
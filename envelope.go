@@ -0,0 +1,223 @@
+package ard
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Version of the envelope header written by [WriteEnvelope]. Envelopes with
+// higher versions cannot be read by [ReadEnvelope].
+const EnvelopeVersion = 1
+
+const envelopePrefix = "ard+"
+
+const maxEnvelopeHeaderLength = 256
+
+//
+// EnvelopeCompression
+//
+
+type EnvelopeCompression string
+
+const (
+	EnvelopeNoCompression EnvelopeCompression = "none"
+	EnvelopeGzip          EnvelopeCompression = "gzip"
+)
+
+//
+// EnvelopeOptions
+//
+
+type EnvelopeOptions struct {
+	// Defaults to [EnvelopeNoCompression].
+	Compression EnvelopeCompression
+
+	// Used for encoding. If nil then the default reflector will be used.
+	Reflector *Reflector
+}
+
+//
+// EnvelopeHeader
+//
+
+// The header of an envelope. It is a single line of text, e.g.:
+//
+//	ard+cbor;v=1;compression=gzip;crc32=8a9136aa
+//
+// The checksum is the CRC-32 (IEEE) of the payload as stored, i.e. after
+// compression, so that corruption can be detected before decompressing.
+type EnvelopeHeader struct {
+	Format      string
+	Version     int
+	Compression EnvelopeCompression
+	Checksum    uint32
+}
+
+// ([fmt.Stringer] interface)
+func (self *EnvelopeHeader) String() string {
+	return fmt.Sprintf("%s%s;v=%d;compression=%s;crc32=%08x", envelopePrefix, self.Format, self.Version, self.Compression, self.Checksum)
+}
+
+// Encodes an ARD [Value] to a supported format (see [SupportedFormats]) and
+// writes it to an [io.Writer] prefixed with an [EnvelopeHeader], so that
+// stored data can be read later via [ReadEnvelope] without out-of-band
+// knowledge of how it was written.
+//
+// The whole payload is encoded (and compressed) into memory before it is
+// written, because its checksum is in the header.
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteEnvelope(value Value, writer io.Writer, format string, options *EnvelopeOptions) error {
+	if options == nil {
+		options = new(EnvelopeOptions)
+	}
+
+	header := EnvelopeHeader{
+		Format:      format,
+		Version:     EnvelopeVersion,
+		Compression: options.Compression,
+	}
+	if header.Compression == "" {
+		header.Compression = EnvelopeNoCompression
+	}
+
+	var payload bytes.Buffer
+	switch header.Compression {
+	case EnvelopeNoCompression:
+		if err := Write(value, &payload, format, options.Reflector); err != nil {
+			return err
+		}
+
+	case EnvelopeGzip:
+		gzipWriter := gzip.NewWriter(&payload)
+		if err := Write(value, gzipWriter, format, options.Reflector); err != nil {
+			return err
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported ARD envelope compression: %q", header.Compression)
+	}
+
+	header.Checksum = crc32.ChecksumIEEE(payload.Bytes())
+
+	if _, err := io.WriteString(writer, header.String()+"\n"); err != nil {
+		return err
+	}
+	_, err := writer.Write(payload.Bytes())
+	return err
+}
+
+// Reads an envelope written by [WriteEnvelope] from an [io.Reader] and
+// decodes it to an ARD [Value] according to its header, which is returned,
+// too. The checksum is verified before decoding.
+//
+// All resulting maps are guaranteed to be [Map] (and not [StringMap]), as
+// with [Read].
+func ReadEnvelope(reader io.Reader) (Value, *EnvelopeHeader, error) {
+	bufferedReader := bufio.NewReader(reader)
+
+	line, err := bufferedReader.ReadSlice('\n')
+	if err != nil {
+		if (err == bufio.ErrBufferFull) || (err == io.EOF) {
+			return nil, nil, errors.New("not an ARD envelope: no header")
+		}
+		return nil, nil, err
+	}
+
+	header, err := parseEnvelopeHeader(string(line[:len(line)-1]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := io.ReadAll(bufferedReader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if checksum := crc32.ChecksumIEEE(payload); checksum != header.Checksum {
+		return nil, header, fmt.Errorf("ARD envelope checksum mismatch: expected %08x, got %08x", header.Checksum, checksum)
+	}
+
+	var payloadReader io.Reader = bytes.NewReader(payload)
+	if header.Compression == EnvelopeGzip {
+		if payloadReader, err = gzip.NewReader(payloadReader); err != nil {
+			return nil, header, err
+		}
+	}
+
+	if value, _, err := Read(payloadReader, header.Format, false); err == nil {
+		return value, header, nil
+	} else {
+		return nil, header, err
+	}
+}
+
+// Utils
+
+func parseEnvelopeHeader(line string) (*EnvelopeHeader, error) {
+	if len(line) > maxEnvelopeHeaderLength {
+		return nil, errors.New("not an ARD envelope: header too long")
+	}
+
+	line, ok := strings.CutPrefix(line, envelopePrefix)
+	if !ok {
+		return nil, errors.New("not an ARD envelope: no header")
+	}
+
+	fields := strings.Split(line, ";")
+	header := EnvelopeHeader{Format: fields[0]}
+	if !IsSupportedFormat(header.Format) {
+		return nil, fmt.Errorf("unsupported format in ARD envelope: %q", header.Format)
+	}
+
+	var hasVersion, hasCompression, hasChecksum bool
+	for _, field := range fields[1:] {
+		// Unknown fields are ignored, for forward compatibility
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "v":
+			if version, err := strconv.Atoi(value); err == nil {
+				if version > EnvelopeVersion {
+					return nil, fmt.Errorf("unsupported ARD envelope version: %d", version)
+				}
+				header.Version = version
+				hasVersion = true
+			} else {
+				return nil, fmt.Errorf("malformed ARD envelope version: %q", value)
+			}
+
+		case "compression":
+			header.Compression = EnvelopeCompression(value)
+			switch header.Compression {
+			case EnvelopeNoCompression, EnvelopeGzip:
+				hasCompression = true
+			default:
+				return nil, fmt.Errorf("unsupported ARD envelope compression: %q", value)
+			}
+
+		case "crc32":
+			if checksum, err := strconv.ParseUint(value, 16, 32); err == nil {
+				header.Checksum = uint32(checksum)
+				hasChecksum = true
+			} else {
+				return nil, fmt.Errorf("malformed ARD envelope checksum: %q", value)
+			}
+		}
+	}
+
+	if !hasVersion || !hasCompression || !hasChecksum {
+		return nil, fmt.Errorf("malformed ARD envelope header: %q", envelopePrefix+line)
+	}
+
+	return &header, nil
+}
@@ -1,5 +1,11 @@
 package ard
 
+import (
+	"bytes"
+	"reflect"
+	"sort"
+)
+
 // Checks for deep equality between two ARD values.
 //
 // Primitives are compared via the `=` operator.
@@ -8,6 +14,82 @@ package ard
 // To gloss over the difference in type, call [CopyStringMapsToMaps]
 // on one or both of the values first.
 func Equals(a Value, b Value) bool {
+	return equals(a, b, nil)
+}
+
+//
+// Comparer
+//
+
+// Returns true if the values are equal. See [EqualsFunc].
+type Comparer = func(a Value, b Value) bool
+
+// Like [Equals] but with custom comparers per type, e.g. for timestamps with
+// a tolerance or for case-insensitive strings.
+//
+// The comparer is chosen according to the [GetTypeName] of a. Otherwise, if
+// both values are accepted by the [TypeValidators] of a comparer's type (e.g.
+// [TypeSemVer]), then that comparer is used, with types tried in sorted order.
+// Comparers for [TypeMap] and [TypeList] replace the deep comparison.
+//
+// The comparers argument can be nil, in which case this function is identical
+// to [Equals].
+func EqualsFunc(a Value, b Value, comparers map[TypeName]Comparer) bool {
+	if len(comparers) == 0 {
+		return equals(a, b, nil)
+	}
+
+	equaler := equaler{comparers: comparers}
+	for type_ := range comparers {
+		if _, ok := TypeZeroes[type_]; !ok {
+			if _, ok := TypeValidators[type_]; ok {
+				equaler.validated = append(equaler.validated, type_)
+			}
+		}
+	}
+	sort.Slice(equaler.validated, func(i int, j int) bool {
+		return equaler.validated[i] < equaler.validated[j]
+	})
+
+	return equals(a, b, &equaler)
+}
+
+// Utils
+
+type equaler struct {
+	comparers map[TypeName]Comparer
+	validated []TypeName // types with validators, sorted
+}
+
+// Returns false if there is no comparer
+func (self *equaler) compare(a Value, b Value) (bool, bool) {
+	type_ := GetTypeName(a)
+	if _, ok := a.(StringMap); ok {
+		type_ = TypeMap
+	}
+
+	if comparer, ok := self.comparers[type_]; ok {
+		return comparer(a, b), true
+	}
+
+	for _, type_ := range self.validated {
+		validator := TypeValidators[type_]
+		if validator(a) && validator(b) {
+			return self.comparers[type_](a, b), true
+		}
+	}
+
+	return false, false
+}
+
+// The equaler can be nil
+func equals(a Value, b Value, equaler *equaler) bool {
+	if equaler != nil {
+		if equal, ok := equaler.compare(a, b); ok {
+			return equal
+		}
+	}
+
 	switch a_ := a.(type) {
 	case Map:
 		if bMap, ok := b.(Map); ok {
@@ -26,7 +108,7 @@ func Equals(a Value, b Value) bool {
 			// Are all values in A equal to those in B?
 			for key, aValue := range a_ {
 				if bValue, ok := bMap[key]; ok {
-					if !Equals(aValue, bValue) {
+					if !equals(aValue, bValue, equaler) {
 						return false
 					}
 				} else {
@@ -56,7 +138,7 @@ func Equals(a Value, b Value) bool {
 			// Are all values in A equal to those in B?
 			for key, aValue := range a_ {
 				if bValue, ok := bMap[key]; ok {
-					if !Equals(aValue, bValue) {
+					if !equals(aValue, bValue, equaler) {
 						return false
 					}
 				} else {
@@ -78,7 +160,7 @@ func Equals(a Value, b Value) bool {
 
 			for index, aValue := range a_ {
 				bValue := bList[index]
-				if !Equals(aValue, bValue) {
+				if !equals(aValue, bValue, equaler) {
 					return false
 				}
 			}
@@ -88,7 +170,19 @@ func Equals(a Value, b Value) bool {
 			return false
 		}
 
+	case []byte:
+		// Slices are not comparable via ==
+		if bBytes, ok := b.([]byte); ok {
+			return bytes.Equal(a_, bBytes)
+		} else {
+			return false
+		}
+
 	default:
+		if type_ := reflect.TypeOf(a); (type_ != nil) && !type_.Comparable() {
+			// Would panic via ==
+			return reflect.DeepEqual(a, b)
+		}
 		return a == b
 	}
 }
@@ -0,0 +1,134 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//
+// PathError
+//
+
+// An error at a specific path in an ARD value.
+type PathError struct {
+	Path Path
+	Err  error
+}
+
+// ([error] interface)
+func (self *PathError) Error() string {
+	return self.Err.Error()
+}
+
+func (self *PathError) Unwrap() error {
+	return self.Err
+}
+
+func (self *PathError) getPath() Path {
+	return self.Path
+}
+
+// The message will be prefixed with the path
+func newPathErrorf(path Path, format string, args ...any) *PathError {
+	return &PathError{path, fmt.Errorf("%s "+format, append([]any{path.String()}, args...)...)}
+}
+
+// The message will be prefixed with the prefix, preserving the path if it's a [*PathError]
+func wrapPathError(err error, prefix string) error {
+	var pathError *PathError
+	if errors.As(err, &pathError) {
+		return &PathError{pathError.Path, fmt.Errorf("%s%w", prefix, err)}
+	} else {
+		return fmt.Errorf("%s%w", prefix, err)
+	}
+}
+
+// Prepends the path to the path of a [*PathError] (the message will be
+// prefixed with the combined path). Other errors are wrapped in a new
+// [*PathError].
+func prependPathError(path Path, err error) error {
+	if pathError, ok := err.(*PathError); ok {
+		path_ := append(path[:len(path):len(path)], pathError.Path...)
+		message := strings.TrimPrefix(pathError.Err.Error(), pathError.Path.String())
+		return &PathError{path_, &rewordedError{path_.String() + message, pathError.Err}}
+	} else {
+		return newPathErrorf(path, "%w", err)
+	}
+}
+
+//
+// rewordedError
+//
+
+// Changes the message of an error while still wrapping it
+type rewordedError struct {
+	message string
+	err     error
+}
+
+// ([error] interface)
+func (self *rewordedError) Error() string {
+	return self.message
+}
+
+func (self *rewordedError) Unwrap() error {
+	return self.err
+}
+
+//
+// Errors
+//
+
+// Multiple errors collected during a recursive operation, e.g. [Reflector.Pack]
+// and [ValidCopy] when [Reflector].CollectErrors is true, and [CoerceToSchema].
+//
+// Use [errors.As] to extract it from a returned error.
+type Errors []error
+
+// ([error] interface)
+func (self Errors) Error() string {
+	var builder strings.Builder
+	for index, err := range self {
+		if index > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(err.Error())
+	}
+	return builder.String()
+}
+
+func (self Errors) Unwrap() []error {
+	return self
+}
+
+// Returns the paths of the errors. The path will be nil for errors that do
+// not have one.
+func (self Errors) Paths() []Path {
+	paths := make([]Path, len(self))
+	for index, err := range self {
+		var pathError interface{ getPath() Path }
+		if errors.As(err, &pathError) {
+			paths[index] = pathError.getPath()
+		}
+	}
+	return paths
+}
+
+// Nested [Errors] are flattened
+func appendErrors(errs Errors, err error) Errors {
+	if errs_, ok := err.(Errors); ok {
+		return append(errs, errs_...)
+	} else {
+		return append(errs, err)
+	}
+}
+
+// Returns nil if there are no errors
+func (self Errors) orNil() error {
+	if len(self) > 0 {
+		return self
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,160 @@
+package ard
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const EvalCode = "$ard.eval"
+
+// Replaces all expression maps in the value with the results of their
+// evaluation via [Eval]. An expression map is a [Map] or [StringMap] with a
+// single "$ard.eval" key and a string expression value, e.g.:
+//
+//	{"$ard.eval": "parent.size * 2"}
+//
+// The variables available to the expression are "root", the whole value, and
+// "parent", the map or list containing the expression map.
+//
+// Expressions may refer to values that are themselves expression maps, in
+// which case they will be evaluated first. Circular references are errors.
+//
+// Resolution happens in place when possible. The root value is returned, which
+// is only different from the argument if it itself is an expression map.
+func ResolveEval(value Value) (Value, error) {
+	resolver := evalResolver{root: value, resolving: make(map[uintptr]struct{})}
+
+	if value_, err := resolver.resolveValue(value, nil, nil); err == nil {
+		resolver.root = value_
+	} else {
+		return nil, err
+	}
+
+	if err := resolver.resolveNested(resolver.root, nil); err == nil {
+		return resolver.root, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Utils
+
+type evalResolver struct {
+	root      Value
+	resolving map[uintptr]struct{}
+}
+
+func (self *evalResolver) resolveNested(value Value, path Path) error {
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			path_ := path.AppendKey(key)
+			if element_, err := self.resolveValue(element, value_, key); err == nil {
+				value_[key] = element_
+				if err := self.resolveNested(element_, path_); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("%s: %w", path_.String(), err)
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			path_ := path.AppendField(key)
+			if element_, err := self.resolveValue(element, value_, key); err == nil {
+				value_[key] = element_
+				if err := self.resolveNested(element_, path_); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("%s: %w", path_.String(), err)
+			}
+		}
+
+	case List:
+		for index, element := range value_ {
+			path_ := path.AppendList(index)
+			if element_, err := self.resolveValue(element, value_, index); err == nil {
+				value_[index] = element_
+				if err := self.resolveNested(element_, path_); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("%s: %w", path_.String(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// If the value is an expression map will return the result of the evaluation
+// and store it in the container, otherwise will return the value as is
+func (self *evalResolver) resolveValue(value Value, container Value, key Value) (Value, error) {
+	expression, ok := getEvalExpression(value)
+	if !ok {
+		return value, nil
+	}
+
+	identity := reflect.ValueOf(value).Pointer()
+	if _, ok := self.resolving[identity]; ok {
+		return nil, fmt.Errorf("circular reference in expression: %s", expression)
+	}
+	self.resolving[identity] = struct{}{}
+	defer delete(self.resolving, identity)
+
+	evaluator := evaluator{
+		lookup: func(name string) (Value, bool, error) {
+			switch name {
+			case "root":
+				return self.root, true, nil
+			case "parent":
+				return container, container != nil, nil
+			default:
+				return nil, false, nil
+			}
+		},
+		resolve: self.resolveValue,
+	}
+
+	if result, err := evaluator.eval(expression); err == nil {
+		// Store the result so that it is not evaluated again
+		switch container_ := container.(type) {
+		case Map, StringMap:
+			if err := putInMap(container_, key, result, nil); err != nil {
+				return nil, err
+			}
+		case List:
+			if index, ok := toIndex(key); ok {
+				container_[index] = result
+			}
+		}
+
+		return result, nil
+	} else {
+		return nil, err
+	}
+}
+
+func getEvalExpression(value Value) (string, bool) {
+	switch value_ := value.(type) {
+	case Map:
+		if len(value_) == 1 {
+			if expression, ok := value_[EvalCode]; ok {
+				expression_, ok := expression.(string)
+				return expression_, ok
+			}
+		}
+
+	case StringMap:
+		if len(value_) == 1 {
+			if expression, ok := value_[EvalCode]; ok {
+				expression_, ok := expression.(string)
+				return expression_, ok
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,629 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Evaluates a simple expression against variables.
+//
+// Supported are:
+//
+//   - literals: integers, floats, strings (single or double quoted), true,
+//     false, and null
+//   - references: variable names followed by ".name" for map keys, and by
+//     "[index]" or ["key"] for list indexes and arbitrary map keys
+//   - arithmetic: +, -, *, /, % (integer arithmetic is used if both operands
+//     are integers, in which case overflow is an error; + concatenates
+//     strings)
+//   - comparison: ==, != (numbers are compared by value, otherwise via
+//     [Equals]), <, <=, >, >= (via [Compare])
+//   - logic: &&, ||, ! (the right operand of && and || is only evaluated if
+//     needed)
+//   - grouping with parentheses
+//
+// Example: "parent.size * 2 + root.servers[0].port".
+func Eval(expression string, variables StringMap) (Value, error) {
+	evaluator := evaluator{
+		lookup: func(name string) (Value, bool, error) {
+			value, ok := variables[name]
+			return value, ok, nil
+		},
+	}
+
+	return evaluator.eval(expression)
+}
+
+//
+// evaluator
+//
+
+type evaluator struct {
+	// Looks up variables
+	lookup func(name string) (Value, bool, error)
+
+	// If set, is called on every value retrieved from a container
+	resolve func(value Value, container Value, key Value) (Value, error)
+
+	tokens   []evalToken
+	position int
+
+	// When true, expressions are parsed but not evaluated (for short-circuit
+	// logic)
+	skip bool
+}
+
+func (self *evaluator) eval(expression string) (Value, error) {
+	var err error
+	if self.tokens, err = tokenizeEval(expression); err != nil {
+		return nil, err
+	}
+	self.position = 0
+
+	if value, err := self.parseOr(); err == nil {
+		if token := self.peek(); token.kind != evalEnd {
+			return nil, fmt.Errorf("unexpected %q in expression: %s", token.text, expression)
+		}
+		return value, nil
+	} else {
+		return nil, fmt.Errorf("%w in expression: %s", err, expression)
+	}
+}
+
+func (self *evaluator) peek() evalToken {
+	return self.tokens[self.position]
+}
+
+func (self *evaluator) next() evalToken {
+	token := self.tokens[self.position]
+	if token.kind != evalEnd {
+		self.position++
+	}
+	return token
+}
+
+func (self *evaluator) accept(operators ...string) (string, bool) {
+	token := self.peek()
+	if token.kind == evalOperator {
+		for _, operator := range operators {
+			if token.text == operator {
+				self.position++
+				return operator, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (self *evaluator) expect(operator string) error {
+	if _, ok := self.accept(operator); ok {
+		return nil
+	} else {
+		return fmt.Errorf("expected %q but found %q", operator, self.peek().text)
+	}
+}
+
+func (self *evaluator) parseOr() (Value, error) {
+	if left, err := self.parseAnd(); err == nil {
+		for {
+			if _, ok := self.accept("||"); !ok {
+				return left, nil
+			}
+
+			if left == true {
+				// Short-circuit
+				if err := self.skipOver(self.parseAnd); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if right, err := self.parseAnd(); err == nil {
+				if self.skip {
+					left = nil
+				} else if left, err = evalLogic(left, right, false); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *evaluator) parseAnd() (Value, error) {
+	if left, err := self.parseComparison(); err == nil {
+		for {
+			if _, ok := self.accept("&&"); !ok {
+				return left, nil
+			}
+
+			if left == false {
+				// Short-circuit
+				if err := self.skipOver(self.parseComparison); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if right, err := self.parseComparison(); err == nil {
+				if self.skip {
+					left = nil
+				} else if left, err = evalLogic(left, right, true); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *evaluator) parseComparison() (Value, error) {
+	if left, err := self.parseAdditive(); err == nil {
+		if operator, ok := self.accept("==", "!=", "<=", ">=", "<", ">"); ok {
+			if right, err := self.parseAdditive(); err == nil {
+				return evalComparison(operator, left, right), nil
+			} else {
+				return nil, err
+			}
+		}
+		return left, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *evaluator) parseAdditive() (Value, error) {
+	if left, err := self.parseMultiplicative(); err == nil {
+		for {
+			operator, ok := self.accept("+", "-")
+			if !ok {
+				return left, nil
+			}
+
+			if right, err := self.parseMultiplicative(); err == nil {
+				if self.skip {
+					left = nil
+				} else if left, err = evalArithmetic(operator, left, right); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *evaluator) parseMultiplicative() (Value, error) {
+	if left, err := self.parseUnary(); err == nil {
+		for {
+			operator, ok := self.accept("*", "/", "%")
+			if !ok {
+				return left, nil
+			}
+
+			if right, err := self.parseUnary(); err == nil {
+				if self.skip {
+					left = nil
+				} else if left, err = evalArithmetic(operator, left, right); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *evaluator) parseUnary() (Value, error) {
+	if operator, ok := self.accept("-", "!"); ok {
+		if value, err := self.parseUnary(); err == nil {
+			if self.skip {
+				return nil, nil
+			} else if operator == "!" {
+				if boolean, ok := value.(bool); ok {
+					return !boolean, nil
+				} else {
+					return nil, fmt.Errorf("not a boolean: %s", GetTypeName(value))
+				}
+			} else {
+				return evalArithmetic("-", int64(0), value)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return self.parsePrimary()
+}
+
+func (self *evaluator) parsePrimary() (Value, error) {
+	token := self.next()
+	switch token.kind {
+	case evalLiteral:
+		return token.value, nil
+
+	case evalIdentifier:
+		if self.skip {
+			return self.parseAccessors(nil)
+		}
+
+		var value Value
+		if value_, ok, err := self.lookup(token.text); err == nil {
+			if !ok {
+				return nil, fmt.Errorf("unknown variable %q", token.text)
+			}
+			value = value_
+		} else {
+			return nil, err
+		}
+		return self.parseAccessors(value)
+
+	case evalOperator:
+		if token.text == "(" {
+			if value, err := self.parseOr(); err == nil {
+				if err := self.expect(")"); err == nil {
+					return value, nil
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	if token.kind == evalEnd {
+		return nil, errors.New("unexpected end")
+	} else {
+		return nil, fmt.Errorf("unexpected %q", token.text)
+	}
+}
+
+func (self *evaluator) parseAccessors(value Value) (Value, error) {
+	for {
+		var key Value
+		if _, ok := self.accept("."); ok {
+			if token := self.next(); token.kind == evalIdentifier {
+				key = token.text
+			} else {
+				return nil, fmt.Errorf("expected name after \".\" but found %q", token.text)
+			}
+		} else if _, ok := self.accept("["); ok {
+			var err error
+			if key, err = self.parseOr(); err != nil {
+				return nil, err
+			}
+			if err = self.expect("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			return value, nil
+		}
+
+		if self.skip {
+			continue
+		}
+
+		var err error
+		if value, err = self.access(value, key); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Parses without evaluating
+func (self *evaluator) skipOver(parse func() (Value, error)) error {
+	skip := self.skip
+	self.skip = true
+	_, err := parse()
+	self.skip = skip
+	return err
+}
+
+func (self *evaluator) access(container Value, key Value) (Value, error) {
+	var value Value
+	switch container_ := container.(type) {
+	case Map, StringMap:
+		if value_, _, ok, _ := getFromMap(container_, key, true); ok {
+			value = value_
+		} else {
+			return nil, fmt.Errorf("key not found: %s", MapKeyToString(key))
+		}
+
+	case List:
+		index, ok := toIndex(key)
+		if !ok {
+			return nil, fmt.Errorf("not a list index: %s", MapKeyToString(key))
+		}
+		if (index < 0) || (index >= len(container_)) {
+			return nil, fmt.Errorf("list index out of range: %d", index)
+		}
+		value = container_[index]
+
+	default:
+		return nil, fmt.Errorf("cannot access %s in %s", MapKeyToString(key), GetTypeName(container))
+	}
+
+	if self.resolve != nil {
+		return self.resolve(value, container, key)
+	} else {
+		return value, nil
+	}
+}
+
+// Utils
+
+func toIndex(value Value) (int, bool) {
+	number := toNumber(value)
+	switch value.(type) {
+	case int64, int32, int16, int8, int:
+		return int(number.signed), true
+	case uint64, uint32, uint16, uint8, uint:
+		if number.unsigned <= math.MaxInt {
+			return int(number.unsigned), true
+		}
+	}
+	return 0, false
+}
+
+func evalLogic(left Value, right Value, and bool) (Value, error) {
+	if left_, ok := left.(bool); ok {
+		if right_, ok := right.(bool); ok {
+			if and {
+				return left_ && right_, nil
+			} else {
+				return left_ || right_, nil
+			}
+		} else {
+			return nil, fmt.Errorf("not a boolean: %s", GetTypeName(right))
+		}
+	} else {
+		return nil, fmt.Errorf("not a boolean: %s", GetTypeName(left))
+	}
+}
+
+func evalComparison(operator string, left Value, right Value) bool {
+	switch operator {
+	case "==":
+		return equalsNumerically(left, right)
+	case "!=":
+		return !equalsNumerically(left, right)
+	case "<":
+		return Compare(left, right) < 0
+	case "<=":
+		return Compare(left, right) <= 0
+	case ">":
+		return Compare(left, right) > 0
+	default: // ">="
+		return Compare(left, right) >= 0
+	}
+}
+
+func evalArithmetic(operator string, left Value, right Value) (Value, error) {
+	if operator == "+" {
+		if left_, ok := left.(string); ok {
+			if right_, ok := right.(string); ok {
+				return left_ + right_, nil
+			}
+		}
+	}
+
+	if getTypeRank(left) != numberRank {
+		return nil, fmt.Errorf("not a number: %s", GetTypeName(left))
+	}
+	if getTypeRank(right) != numberRank {
+		return nil, fmt.Errorf("not a number: %s", GetTypeName(right))
+	}
+
+	leftNumber := toNumber(left)
+	rightNumber := toNumber(right)
+
+	if (leftNumber.kind != floatKind) && (rightNumber.kind != floatKind) {
+		// Integer arithmetic (exact, so that we can detect overflow)
+		left_ := leftNumber.toBigInt()
+		right_ := rightNumber.toBigInt()
+		switch operator {
+		case "+":
+			left_.Add(left_, right_)
+		case "-":
+			left_.Sub(left_, right_)
+		case "*":
+			left_.Mul(left_, right_)
+		case "/":
+			if right_.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			left_.Quo(left_, right_)
+		default: // "%"
+			if right_.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			left_.Rem(left_, right_)
+		}
+
+		if left_.IsInt64() {
+			return left_.Int64(), nil
+		} else if left_.IsUint64() {
+			return left_.Uint64(), nil
+		} else {
+			return nil, fmt.Errorf("integer overflow: %s %s %s", ValueToString(left), operator, ValueToString(right))
+		}
+	}
+
+	left_ := leftNumber.toFloat()
+	right_ := rightNumber.toFloat()
+	switch operator {
+	case "+":
+		return left_ + right_, nil
+	case "-":
+		return left_ - right_, nil
+	case "*":
+		return left_ * right_, nil
+	case "/":
+		return left_ / right_, nil
+	default: // "%"
+		return math.Mod(left_, right_), nil
+	}
+}
+
+func (self number) toBigInt() *big.Int {
+	if self.kind == unsignedKind {
+		return new(big.Int).SetUint64(self.unsigned)
+	} else {
+		return big.NewInt(self.signed)
+	}
+}
+
+//
+// evalToken
+//
+
+type evalTokenKind int
+
+const (
+	evalEnd        evalTokenKind = 0
+	evalLiteral    evalTokenKind = 1
+	evalIdentifier evalTokenKind = 2
+	evalOperator   evalTokenKind = 3
+)
+
+type evalToken struct {
+	kind  evalTokenKind
+	text  string
+	value Value
+}
+
+var evalOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", "."}
+
+func tokenizeEval(expression string) ([]evalToken, error) {
+	var tokens []evalToken
+	runes := []rune(expression)
+	length := len(runes)
+
+	for index := 0; index < length; {
+		rune_ := runes[index]
+
+		switch {
+		case unicode.IsSpace(rune_):
+			index++
+
+		case unicode.IsDigit(rune_):
+			start := index
+			float := false
+			for index < length {
+				rune_ = runes[index]
+				if unicode.IsDigit(rune_) {
+					index++
+				} else if (rune_ == '.') && (index+1 < length) && unicode.IsDigit(runes[index+1]) {
+					float = true
+					index++
+				} else if (rune_ == 'e') || (rune_ == 'E') {
+					float = true
+					index++
+					if (index < length) && ((runes[index] == '+') || (runes[index] == '-')) {
+						index++
+					}
+				} else {
+					break
+				}
+			}
+
+			text := string(runes[start:index])
+			if float {
+				if value, err := strconv.ParseFloat(text, 64); err == nil {
+					tokens = append(tokens, evalToken{evalLiteral, text, value})
+				} else {
+					return nil, fmt.Errorf("malformed number %q in expression: %s", text, expression)
+				}
+			} else {
+				if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+					tokens = append(tokens, evalToken{evalLiteral, text, value})
+				} else if value, err := strconv.ParseUint(text, 10, 64); err == nil {
+					tokens = append(tokens, evalToken{evalLiteral, text, value})
+				} else {
+					return nil, fmt.Errorf("malformed number %q in expression: %s", text, expression)
+				}
+			}
+
+		case (rune_ == '"') || (rune_ == '\''):
+			quote := rune_
+			start := index
+			var builder strings.Builder
+			index++
+			for {
+				if index >= length {
+					return nil, fmt.Errorf("unterminated string in expression: %s", expression)
+				}
+
+				rune_ = runes[index]
+				index++
+				if rune_ == quote {
+					break
+				} else if (rune_ == '\\') && (index < length) {
+					rune_ = runes[index]
+					index++
+					switch rune_ {
+					case 'n':
+						rune_ = '\n'
+					case 't':
+						rune_ = '\t'
+					case 'r':
+						rune_ = '\r'
+					}
+				}
+				builder.WriteRune(rune_)
+			}
+			tokens = append(tokens, evalToken{evalLiteral, string(runes[start:index]), builder.String()})
+
+		case unicode.IsLetter(rune_) || (rune_ == '_') || (rune_ == '$'):
+			start := index
+			for (index < length) && (unicode.IsLetter(runes[index]) || unicode.IsDigit(runes[index]) || (runes[index] == '_') || (runes[index] == '$')) {
+				index++
+			}
+
+			text := string(runes[start:index])
+			switch text {
+			case "true":
+				tokens = append(tokens, evalToken{evalLiteral, text, true})
+			case "false":
+				tokens = append(tokens, evalToken{evalLiteral, text, false})
+			case "null":
+				tokens = append(tokens, evalToken{evalLiteral, text, nil})
+			default:
+				tokens = append(tokens, evalToken{evalIdentifier, text, nil})
+			}
+
+		default:
+			found := false
+			for _, operator := range evalOperators {
+				if strings.HasPrefix(string(runes[index:]), operator) {
+					tokens = append(tokens, evalToken{evalOperator, operator, nil})
+					index += len([]rune(operator))
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf("unexpected %q in expression: %s", string(rune_), expression)
+			}
+		}
+	}
+
+	return append(tokens, evalToken{kind: evalEnd}), nil
+}
@@ -0,0 +1,949 @@
+package ard
+
+import (
+	contextpkg "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+//
+// EventKind
+//
+
+type EventKind int
+
+const (
+	StartMapEvent  EventKind = 0
+	KeyEvent       EventKind = 1
+	ScalarEvent    EventKind = 2
+	StartListEvent EventKind = 3
+	EndEvent       EventKind = 4
+)
+
+// ([fmt.Stringer] interface)
+func (self EventKind) String() string {
+	switch self {
+	case StartMapEvent:
+		return "StartMap"
+	case KeyEvent:
+		return "Key"
+	case ScalarEvent:
+		return "Scalar"
+	case StartListEvent:
+		return "StartList"
+	case EndEvent:
+		return "End"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(self))
+	}
+}
+
+//
+// Event
+//
+
+// A single event in a stream representing an ARD [Value].
+//
+// A map is represented by a [StartMapEvent], followed by pairs of a [KeyEvent]
+// and the events of its value, and finally an [EndEvent]. A list is represented
+// by a [StartListEvent], followed by the events of its elements, and finally an
+// [EndEvent]. Primitives are represented by a single [ScalarEvent].
+type Event struct {
+	Kind EventKind
+
+	// The key for [KeyEvent] (can be a complex key) or the primitive value
+	// for [ScalarEvent]. Otherwise nil.
+	Value Value
+
+	// The location of the value in the document. For [KeyEvent] it is the
+	// location of the value being keyed, and for [EndEvent] it is the location
+	// of the map or list being ended.
+	Path Path
+}
+
+//
+// EventProducer
+//
+
+type EventProducer interface {
+	// Returns the next event in the stream. Returns [io.EOF] when there are
+	// no more events.
+	NextEvent() (Event, error)
+}
+
+//
+// EventConsumer
+//
+
+type EventConsumer interface {
+	ConsumeEvent(event Event) error
+}
+
+// Sends all events from the producer to the consumer until the producer is
+// exhausted.
+func PipeEvents(producer EventProducer, consumer EventConsumer) error {
+	for {
+		if event, err := producer.NextEvent(); err == nil {
+			if err := consumer.ConsumeEvent(event); err != nil {
+				return err
+			}
+		} else if err == io.EOF {
+			return nil
+		} else {
+			return err
+		}
+	}
+}
+
+// Convenience function to build an ARD [Value] from all the events of the producer.
+//
+// If useStringMaps is true returns maps as [StringMap], otherwise they will
+// be [Map].
+func BuildValue(producer EventProducer, useStringMaps bool) (Value, error) {
+	builder := NewValueBuilder(useStringMaps)
+	if err := PipeEvents(producer, builder); err == nil {
+		return builder.Value()
+	} else {
+		return nil, err
+	}
+}
+
+// Skips all the events of the next value in the stream, including all of its
+// nested values, if any. Useful for ignoring unwanted subtrees.
+func SkipEventValue(producer EventProducer) error {
+	if event, err := producer.NextEvent(); err == nil {
+		switch event.Kind {
+		case ScalarEvent:
+			return nil
+
+		case StartMapEvent, StartListEvent:
+			return skipEvents(producer, 1)
+
+		default:
+			return fmt.Errorf("%s: not at the start of a value: %s", event.Path.String(), event.Kind.String())
+		}
+	} else {
+		return err
+	}
+}
+
+// Creates an [EventProducer] for supported formats.
+//
+// Only the "json" format is read in streaming fashion. Other formats are
+// first fully read via [Read].
+func NewEventProducer(reader io.Reader, format string) (EventProducer, error) {
+	switch format {
+	case "json":
+		return NewJSONEventProducer(reader), nil
+
+	default:
+		if value, _, err := Read(reader, format, false); err == nil {
+			return NewValueEventProducer(value), nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
+//
+// ValueEventProducer
+//
+
+// Produces events by traversing an existing ARD [Value].
+//
+// Note that the order of map entries is not deterministic.
+type ValueEventProducer struct {
+	value   Value
+	started bool
+	stack   []*valueEventFrame
+}
+
+func NewValueEventProducer(value Value) *ValueEventProducer {
+	return &ValueEventProducer{value: value}
+}
+
+// ([EventProducer] interface)
+func (self *ValueEventProducer) NextEvent() (Event, error) {
+	if !self.started {
+		self.started = true
+		return self.start(self.value, nil), nil
+	}
+
+	last := len(self.stack) - 1
+	if last == -1 {
+		return Event{}, io.EOF
+	}
+
+	frame := self.stack[last]
+
+	if frame.list != nil {
+		if frame.index < len(frame.list) {
+			index := frame.index
+			frame.index++
+			return self.start(frame.list[index], frame.path.AppendList(index)), nil
+		}
+	} else if frame.index < len(frame.keys) {
+		key := frame.keys[frame.index]
+		path := frame.path.AppendKey(key)
+		if frame.valueNext {
+			frame.valueNext = false
+			value := frame.values[frame.index]
+			frame.index++
+			return self.start(value, path), nil
+		} else {
+			frame.valueNext = true
+			return Event{Kind: KeyEvent, Value: key, Path: path}, nil
+		}
+	}
+
+	self.stack = self.stack[:last]
+	return Event{Kind: EndEvent, Path: frame.path}, nil
+}
+
+func (self *ValueEventProducer) start(value Value, path Path) Event {
+	switch value_ := value.(type) {
+	case Map:
+		frame := valueEventFrame{path: path, keys: make(List, 0, len(value_)), values: make(List, 0, len(value_))}
+		for key, value__ := range value_ {
+			frame.keys = append(frame.keys, key)
+			frame.values = append(frame.values, value__)
+		}
+		self.stack = append(self.stack, &frame)
+		return Event{Kind: StartMapEvent, Path: path}
+
+	case StringMap:
+		frame := valueEventFrame{path: path, keys: make(List, 0, len(value_)), values: make(List, 0, len(value_))}
+		for key, value__ := range value_ {
+			frame.keys = append(frame.keys, key)
+			frame.values = append(frame.values, value__)
+		}
+		self.stack = append(self.stack, &frame)
+		return Event{Kind: StartMapEvent, Path: path}
+
+	case List:
+		if value_ == nil {
+			// Make sure we can distinguish it from a map frame
+			value_ = List{}
+		}
+		self.stack = append(self.stack, &valueEventFrame{path: path, list: value_})
+		return Event{Kind: StartListEvent, Path: path}
+
+	default:
+		return Event{Kind: ScalarEvent, Value: value, Path: path}
+	}
+}
+
+type valueEventFrame struct {
+	path      Path
+	list      List
+	keys      List
+	values    List
+	index     int
+	valueNext bool
+}
+
+//
+// JSONEventProducer
+//
+
+// Produces events from JSON in streaming fashion, such that the entire document
+// does not have to be read into memory. Only the first JSON value in the stream
+// is read.
+//
+// Like [ReadJSON], numbers are produced as float64.
+//
+// Values at selected paths can be produced undecoded as [*Raw] or skipped
+// entirely. See [JSONEventProducerOptions].
+type JSONEventProducer struct {
+	decoder   *json.Decoder
+	options   *JSONEventProducerOptions
+	filter    *pathFilter
+	filterErr error
+	pending   json.Token
+	stack     []*jsonEventFrame
+	started   bool
+	done      bool
+}
+
+func NewJSONEventProducer(reader io.Reader) *JSONEventProducer {
+	return NewJSONEventProducerWithOptions(reader, nil)
+}
+
+// Like [NewJSONEventProducer] but with [JSONEventProducerOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func NewJSONEventProducerWithOptions(reader io.Reader, options *JSONEventProducerOptions) *JSONEventProducer {
+	if options == nil {
+		options = new(JSONEventProducerOptions)
+	}
+	filter, err := newPathFilter(options.IncludePaths, options.ExcludePaths)
+	return &JSONEventProducer{decoder: json.NewDecoder(reader), options: options, filter: filter, filterErr: err}
+}
+
+// ([EventProducer] interface)
+func (self *JSONEventProducer) NextEvent() (Event, error) {
+	if self.filterErr != nil {
+		return Event{}, self.filterErr
+	}
+
+	for {
+		if self.done {
+			return Event{}, io.EOF
+		}
+
+		if self.pending == nil {
+			if path, ok := self.nextValuePath(); ok {
+				// Map values are filtered together with their keys
+				if (self.filter != nil) && !self.isMapNext() {
+					if skipped, err := self.filterValue(path); err != nil {
+						return Event{}, err
+					} else if skipped {
+						continue
+					}
+				}
+
+				if (self.pending == nil) && (self.options.Defer != nil) && self.options.Defer(path) {
+					return self.raw()
+				}
+			}
+		}
+
+		self.started = true
+		var token json.Token
+		if self.pending != nil {
+			token = self.pending
+			self.pending = nil
+		} else {
+			var err error
+			if token, err = self.decoder.Token(); err != nil {
+				return Event{}, err
+			}
+		}
+
+		var path Path
+		if last := len(self.stack) - 1; last >= 0 {
+			frame := self.stack[last]
+
+			if token == json.Delim('}') || token == json.Delim(']') {
+				return self.end(), nil
+			}
+
+			if frame.isMap {
+				if frame.keyNext {
+					if key, ok := token.(string); ok {
+						frame.keyNext = false
+						frame.keyPath = frame.path.AppendKey(key)
+
+						if self.filter != nil {
+							if skipped, err := self.filterValue(frame.keyPath); err != nil {
+								return Event{}, err
+							} else if skipped {
+								continue
+							}
+						}
+
+						return Event{Kind: KeyEvent, Value: key, Path: frame.keyPath}, nil
+					} else {
+						return Event{}, fmt.Errorf("%s: malformed JSON map key: %v", frame.path.String(), token)
+					}
+				}
+
+				path = frame.keyPath
+				frame.keyNext = true
+			} else {
+				path = frame.path.AppendList(frame.index)
+				frame.index++
+			}
+		}
+
+		switch token {
+		case json.Delim('{'):
+			self.stack = append(self.stack, &jsonEventFrame{path: path, isMap: true, keyNext: true})
+			return Event{Kind: StartMapEvent, Path: path}, nil
+
+		case json.Delim('['):
+			self.stack = append(self.stack, &jsonEventFrame{path: path})
+			return Event{Kind: StartListEvent, Path: path}, nil
+
+		default:
+			if len(self.stack) == 0 {
+				self.done = true
+			}
+			return Event{Kind: ScalarEvent, Value: token, Path: path}, nil
+		}
+	}
+}
+
+// Returns the path of the next token if it is the start of a value.
+func (self *JSONEventProducer) nextValuePath() (Path, bool) {
+	last := len(self.stack) - 1
+	if last == -1 {
+		return nil, !self.started
+	}
+
+	frame := self.stack[last]
+	if frame.isMap {
+		if frame.keyNext {
+			return nil, false
+		}
+		return frame.keyPath, true
+	} else if self.decoder.More() {
+		return frame.path.AppendList(frame.index), true
+	} else {
+		return nil, false
+	}
+}
+
+func (self *JSONEventProducer) isMapNext() bool {
+	last := len(self.stack) - 1
+	return (last >= 0) && self.stack[last].isMap
+}
+
+// Reads the next value without decoding it.
+func (self *JSONEventProducer) raw() (Event, error) {
+	self.started = true
+
+	var raw json.RawMessage
+	if err := self.decoder.Decode(&raw); err != nil {
+		return Event{}, err
+	}
+
+	return Event{Kind: ScalarEvent, Value: &Raw{"json", raw}, Path: self.advance()}, nil
+}
+
+// Applies the filter to the next value. When the value is skipped it is
+// consumed token by token, without decoding it. When it is a map or a list
+// that leads to included paths, its first token is kept as pending.
+func (self *JSONEventProducer) filterValue(path Path) (bool, error) {
+	switch self.filter.filter(path) {
+	case pathFilterSkip:
+		self.started = true
+		depth := 0
+		for {
+			if token, err := self.decoder.Token(); err == nil {
+				switch token {
+				case json.Delim('{'), json.Delim('['):
+					depth++
+				case json.Delim('}'), json.Delim(']'):
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			} else {
+				return false, err
+			}
+		}
+		self.advance()
+		return true, nil
+
+	case pathFilterDescend:
+		self.started = true
+		if token, err := self.decoder.Token(); err == nil {
+			if (token == json.Delim('{')) || (token == json.Delim('[')) {
+				self.pending = token
+				return false, nil
+			} else {
+				self.advance()
+				return true, nil
+			}
+		} else {
+			return false, err
+		}
+
+	default:
+		return false, nil
+	}
+}
+
+// Moves past a value that was consumed without producing events. Returns its
+// path.
+func (self *JSONEventProducer) advance() Path {
+	var path Path
+	if last := len(self.stack) - 1; last >= 0 {
+		frame := self.stack[last]
+		if frame.isMap {
+			path = frame.keyPath
+			frame.keyNext = true
+		} else {
+			path = frame.path.AppendList(frame.index)
+			frame.index++
+		}
+	} else {
+		self.done = true
+	}
+	return path
+}
+
+func (self *JSONEventProducer) end() Event {
+	last := len(self.stack) - 1
+	frame := self.stack[last]
+	self.stack = self.stack[:last]
+	if last == 0 {
+		self.done = true
+	}
+	return Event{Kind: EndEvent, Path: frame.path}
+}
+
+type jsonEventFrame struct {
+	path    Path
+	isMap   bool
+	keyNext bool
+	keyPath Path
+	index   int
+}
+
+//
+// JSONEventProducerOptions
+//
+
+type JSONEventProducerOptions struct {
+	// When not nil is called with the path of every value before it is read.
+	// When it returns true the value is not decoded and is instead produced
+	// as a single [ScalarEvent] with a [*Raw] value, which can be decoded
+	// later via [Raw.Decode]. This allows skipping the decoding of large
+	// subtrees that are not needed.
+	Defer func(path Path) bool
+
+	// Path patterns in [Path.String] format, in which "*" can be used as a
+	// wildcard for a whole path element, e.g. "servers[*].port" or
+	// "services.*.image". When not empty only values at or under these paths
+	// are produced, as well as the maps and lists leading to them (which might
+	// thus be empty). If the root value itself is not produced then there are
+	// no events.
+	IncludePaths []string
+
+	// Path patterns like IncludePaths. Values at or under these paths are not
+	// produced. Takes precedence over IncludePaths.
+	//
+	// Skipped values are read token by token without being decoded, so they
+	// do not occupy memory. Indexes of skipped list elements are not reused,
+	// so paths always refer to the original document.
+	ExcludePaths []string
+}
+
+//
+// FilteredEventProducer
+//
+
+// Returns true to keep the event.
+type EventFilterFunc func(event Event) bool
+
+// Wraps an [EventProducer] and skips values that are rejected by a filter.
+//
+// The filter is called for every [KeyEvent] and for the first event of every
+// list element. When a key is rejected its value is skipped, too. When the start
+// of a map or list is rejected it is skipped entirely, including all of its
+// nested values.
+type FilteredEventProducer struct {
+	producer EventProducer
+	filter   EventFilterFunc
+	afterKey bool
+}
+
+func NewFilteredEventProducer(producer EventProducer, filter EventFilterFunc) *FilteredEventProducer {
+	return &FilteredEventProducer{producer: producer, filter: filter}
+}
+
+// ([EventProducer] interface)
+func (self *FilteredEventProducer) NextEvent() (Event, error) {
+	for {
+		event, err := self.producer.NextEvent()
+		if err != nil {
+			return event, err
+		}
+
+		switch event.Kind {
+		case KeyEvent:
+			if self.filter(event) {
+				self.afterKey = true
+			} else {
+				if err := SkipEventValue(self.producer); err != nil {
+					return Event{}, err
+				}
+				continue
+			}
+
+		case StartMapEvent, StartListEvent, ScalarEvent:
+			if self.afterKey {
+				// The key was already accepted
+				self.afterKey = false
+			} else if !self.filter(event) {
+				if event.Kind != ScalarEvent {
+					if err := skipEvents(self.producer, 1); err != nil {
+						return Event{}, err
+					}
+				}
+				continue
+			}
+		}
+
+		return event, nil
+	}
+}
+
+//
+// MappedEventProducer
+//
+
+type EventMapperFunc func(event Event) (Event, error)
+
+// Wraps an [EventProducer] and calls a mapper on every event. This can be
+// used, for example, to redact or reformat scalar values.
+//
+// The mapper must not change the kind of the event.
+type MappedEventProducer struct {
+	producer EventProducer
+	mapper   EventMapperFunc
+}
+
+func NewMappedEventProducer(producer EventProducer, mapper EventMapperFunc) *MappedEventProducer {
+	return &MappedEventProducer{producer, mapper}
+}
+
+// ([EventProducer] interface)
+func (self *MappedEventProducer) NextEvent() (Event, error) {
+	if event, err := self.producer.NextEvent(); err == nil {
+		return self.mapper(event)
+	} else {
+		return event, err
+	}
+}
+
+//
+// SlicedEventProducer
+//
+
+// Called by [SlicedEventProducer] after every slice of events with the total
+// number of events so far. Returning an error will stop the producer.
+type YieldFunc func(count int) error
+
+// Wraps an [EventProducer] and yields control to a callback after every slice
+// of events, thus allowing long-running decoding of huge documents to be
+// interleaved with other work, e.g. updating a progress indicator or keeping
+// a GUI or TUI responsive. The callback could also send to a channel.
+//
+// The context is checked before every event, such that cancelling it will
+// stop the producer with the context's error.
+type SlicedEventProducer struct {
+	context  contextpkg.Context
+	producer EventProducer
+	every    int
+	yield    YieldFunc
+	count    int
+}
+
+// The context can be nil, in which case there will be no cancellation. The
+// yield argument can be nil, in which case [runtime.Gosched] is called
+// instead. If every is less than 1 then 1 will be used.
+func NewSlicedEventProducer(context contextpkg.Context, producer EventProducer, every int, yield YieldFunc) *SlicedEventProducer {
+	if context == nil {
+		context = contextpkg.Background()
+	}
+	if every < 1 {
+		every = 1
+	}
+	if yield == nil {
+		yield = func(count int) error {
+			runtime.Gosched()
+			return nil
+		}
+	}
+	return &SlicedEventProducer{context: context, producer: producer, every: every, yield: yield}
+}
+
+// ([EventProducer] interface)
+func (self *SlicedEventProducer) NextEvent() (Event, error) {
+	if err := self.context.Err(); err != nil {
+		return Event{}, err
+	}
+
+	if event, err := self.producer.NextEvent(); err == nil {
+		self.count++
+		if self.count%self.every == 0 {
+			if err := self.yield(self.count); err != nil {
+				return Event{}, err
+			}
+		}
+		return event, nil
+	} else {
+		return event, err
+	}
+}
+
+// Convenience function to read an ARD [Value] via a [SlicedEventProducer]
+// wrapping [NewEventProducer]. See [BuildValue].
+//
+// Note that only the "json" format is read in streaming fashion, thus for
+// other formats the yielding will only happen after the whole value was read.
+func ReadSliced(context contextpkg.Context, reader io.Reader, format string, every int, yield YieldFunc, useStringMaps bool) (Value, error) {
+	if producer, err := NewEventProducer(reader, format); err == nil {
+		return BuildValue(NewSlicedEventProducer(context, producer, every, yield), useStringMaps)
+	} else {
+		return nil, err
+	}
+}
+
+//
+// ValueBuilder
+//
+
+// Consumes events to build an ARD [Value].
+type ValueBuilder struct {
+	useStringMaps bool
+	stack         []*valueBuilderFrame
+	value         Value
+	done          bool
+}
+
+// If useStringMaps is true will build maps as [StringMap], otherwise they
+// will be [Map].
+func NewValueBuilder(useStringMaps bool) *ValueBuilder {
+	return &ValueBuilder{useStringMaps: useStringMaps}
+}
+
+// Returns the built value. Will fail if the value is incomplete.
+func (self *ValueBuilder) Value() (Value, error) {
+	if self.done {
+		return self.value, nil
+	} else {
+		return nil, fmt.Errorf("incomplete value: %d unended maps or lists", len(self.stack))
+	}
+}
+
+// ([EventConsumer] interface)
+func (self *ValueBuilder) ConsumeEvent(event Event) error {
+	switch event.Kind {
+	case StartMapEvent:
+		if self.useStringMaps {
+			self.stack = append(self.stack, &valueBuilderFrame{value: make(StringMap)})
+		} else {
+			self.stack = append(self.stack, &valueBuilderFrame{value: make(Map)})
+		}
+
+	case StartListEvent:
+		self.stack = append(self.stack, &valueBuilderFrame{value: List{}})
+
+	case KeyEvent:
+		if last := len(self.stack) - 1; last >= 0 {
+			frame := self.stack[last]
+			if _, isList := frame.value.(List); !isList && !frame.hasKey {
+				frame.key = event.Value
+				frame.hasKey = true
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: unexpected key", event.Path.String())
+
+	case ScalarEvent:
+		return self.add(event.Value, event.Path)
+
+	case EndEvent:
+		last := len(self.stack) - 1
+		if last == -1 {
+			return fmt.Errorf("%s: unexpected end", event.Path.String())
+		}
+
+		frame := self.stack[last]
+		if frame.hasKey {
+			return fmt.Errorf("%s: map key without value", event.Path.String())
+		}
+
+		self.stack = self.stack[:last]
+		return self.add(frame.value, event.Path)
+
+	default:
+		return fmt.Errorf("%s: unsupported event: %s", event.Path.String(), event.Kind.String())
+	}
+
+	return nil
+}
+
+func (self *ValueBuilder) add(value Value, path Path) error {
+	last := len(self.stack) - 1
+
+	if last == -1 {
+		if self.done {
+			return fmt.Errorf("%s: more than one value", path.String())
+		}
+		self.value = value
+		self.done = true
+		return nil
+	}
+
+	frame := self.stack[last]
+	if list, ok := frame.value.(List); ok {
+		frame.value = append(list, value)
+	} else if frame.hasKey {
+		if err := putInMap(frame.value, frame.key, value, path); err != nil {
+			return err
+		}
+		frame.key = nil
+		frame.hasKey = false
+	} else {
+		return fmt.Errorf("%s: map value without key", path.String())
+	}
+
+	return nil
+}
+
+type valueBuilderFrame struct {
+	value  Value
+	key    Value
+	hasKey bool
+}
+
+//
+// JSONEventConsumer
+//
+
+// Consumes events to write compact JSON in streaming fashion.
+//
+// Map keys are converted using [MapKeyToString]. A newline is written after
+// the value is complete.
+type JSONEventConsumer struct {
+	writer io.Writer
+	stack  []*jsonEventConsumerFrame
+}
+
+func NewJSONEventConsumer(writer io.Writer) *JSONEventConsumer {
+	return &JSONEventConsumer{writer: writer}
+}
+
+// ([EventConsumer] interface)
+func (self *JSONEventConsumer) ConsumeEvent(event Event) error {
+	switch event.Kind {
+	case KeyEvent:
+		last := len(self.stack) - 1
+		if (last == -1) || !self.stack[last].isMap {
+			return fmt.Errorf("%s: unexpected key", event.Path.String())
+		}
+
+		frame := self.stack[last]
+		if frame.count > 0 {
+			if err := self.write(","); err != nil {
+				return err
+			}
+		}
+		frame.count++
+		frame.afterKey = true
+
+		if key, err := json.Marshal(MapKeyToString(event.Value)); err == nil {
+			if _, err := self.writer.Write(key); err == nil {
+				return self.write(":")
+			} else {
+				return err
+			}
+		} else {
+			return err
+		}
+
+	case StartMapEvent:
+		if err := self.beforeValue(); err != nil {
+			return err
+		}
+		self.stack = append(self.stack, &jsonEventConsumerFrame{isMap: true})
+		return self.write("{")
+
+	case StartListEvent:
+		if err := self.beforeValue(); err != nil {
+			return err
+		}
+		self.stack = append(self.stack, new(jsonEventConsumerFrame))
+		return self.write("[")
+
+	case ScalarEvent:
+		if err := self.beforeValue(); err != nil {
+			return err
+		}
+		if value, err := json.Marshal(event.Value); err == nil {
+			if _, err := self.writer.Write(value); err == nil {
+				return self.afterValue()
+			} else {
+				return err
+			}
+		} else {
+			return err
+		}
+
+	case EndEvent:
+		last := len(self.stack) - 1
+		if last == -1 {
+			return fmt.Errorf("%s: unexpected end", event.Path.String())
+		}
+
+		frame := self.stack[last]
+		self.stack = self.stack[:last]
+		if frame.isMap {
+			if err := self.write("}"); err != nil {
+				return err
+			}
+		} else {
+			if err := self.write("]"); err != nil {
+				return err
+			}
+		}
+		return self.afterValue()
+
+	default:
+		return fmt.Errorf("%s: unsupported event: %s", event.Path.String(), event.Kind.String())
+	}
+}
+
+func (self *JSONEventConsumer) beforeValue() error {
+	if last := len(self.stack) - 1; last >= 0 {
+		frame := self.stack[last]
+		if frame.isMap {
+			if !frame.afterKey {
+				return fmt.Errorf("map value without key")
+			}
+			frame.afterKey = false
+		} else {
+			if frame.count > 0 {
+				if err := self.write(","); err != nil {
+					return err
+				}
+			}
+			frame.count++
+		}
+	}
+	return nil
+}
+
+func (self *JSONEventConsumer) afterValue() error {
+	if len(self.stack) == 0 {
+		return self.write("\n")
+	}
+	return nil
+}
+
+func (self *JSONEventConsumer) write(s string) error {
+	_, err := io.WriteString(self.writer, s)
+	return err
+}
+
+type jsonEventConsumerFrame struct {
+	isMap    bool
+	count    int
+	afterKey bool
+}
+
+// Utils
+
+func skipEvents(producer EventProducer, depth int) error {
+	for depth > 0 {
+		if event, err := producer.NextEvent(); err == nil {
+			switch event.Kind {
+			case StartMapEvent, StartListEvent:
+				depth++
+			case EndEvent:
+				depth--
+			}
+		} else {
+			return err
+		}
+	}
+	return nil
+}
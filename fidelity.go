@@ -0,0 +1,338 @@
+package ard
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Converts between supported formats, e.g. from "yaml" to "json", and
+// reports what the conversion did to the data in a [FidelityReport], e.g.
+// non-string map keys that were converted to strings, []byte that were
+// encoded as strings, and integers that lost precision as floats.
+//
+// The report is created by decoding the output and comparing it with the
+// decoded input, thus it reflects what a reader of the output would get via
+// [Decode]. An empty report means that the conversion was lossless. Note that
+// the difference between [Map] and [StringMap] is not reported.
+//
+// The options argument can be nil, in which case default options will be used.
+func ConvertFormat(input []byte, fromFormat string, toFormat string, options *ConvertFormatOptions) ([]byte, FidelityReport, error) {
+	if options == nil {
+		options = new(ConvertFormatOptions)
+	}
+
+	value, _, err := Decode(input, fromFormat, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := Encode(value, toFormat, options.Reflector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	converted, _, err := Decode(output, toFormat, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode output: %w", err)
+	}
+
+	return output, CheckFidelity(value, converted), nil
+}
+
+// Compares a value with a conversion of it, e.g. as returned by [Roundtrip],
+// and reports the differences in a [FidelityReport], as [ConvertFormat] does.
+// An empty report means that the conversion was lossless. Note that the
+// difference between [Map] and [StringMap] is not reported.
+func CheckFidelity(value Value, converted Value) FidelityReport {
+	var checker fidelityChecker
+	checker.check(value, converted, nil)
+	return checker.report
+}
+
+//
+// ConvertFormatOptions
+//
+
+type ConvertFormatOptions struct {
+	// Used for encoding. If nil then the default reflector will be used.
+	Reflector *Reflector
+}
+
+//
+// FidelityIssueType
+//
+
+type FidelityIssueType int
+
+const (
+	// A map key was converted to another type, usually a string (see
+	// [MapKeyToString])
+	FidelityKeyConverted FidelityIssueType = 0
+
+	// A value was converted to another type with the same string
+	// representation (see [ValueToString]), e.g. []byte to a base64 string or
+	// an integer to a float
+	FidelityTypeConverted FidelityIssueType = 1
+
+	// A number was converted to one with a different value, e.g. a large
+	// integer to the nearest float
+	FidelityPrecisionLost FidelityIssueType = 2
+
+	// A value was converted to a different value
+	FidelityValueChanged FidelityIssueType = 3
+
+	// A value exists only in the input
+	FidelityValueRemoved FidelityIssueType = 4
+
+	// A value exists only in the output
+	FidelityValueAdded FidelityIssueType = 5
+)
+
+// ([fmt.Stringer] interface)
+func (self FidelityIssueType) String() string {
+	switch self {
+	case FidelityKeyConverted:
+		return "key converted"
+	case FidelityTypeConverted:
+		return "type converted"
+	case FidelityPrecisionLost:
+		return "precision lost"
+	case FidelityValueChanged:
+		return "value changed"
+	case FidelityValueRemoved:
+		return "value removed"
+	case FidelityValueAdded:
+		return "value added"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// FidelityIssue
+//
+
+type FidelityIssue struct {
+	Type FidelityIssueType
+
+	// Location in the input. For [FidelityKeyConverted] it is the location
+	// of the value being keyed. For [FidelityValueAdded] it is the location
+	// in the output.
+	Path Path
+
+	// For [FidelityKeyConverted] these are the keys, otherwise they are the
+	// values. Old is nil for [FidelityValueAdded] and New is nil for
+	// [FidelityValueRemoved].
+	Old Value
+	New Value
+}
+
+// ([fmt.Stringer] interface)
+func (self *FidelityIssue) String() string {
+	switch self.Type {
+	case FidelityKeyConverted, FidelityTypeConverted:
+		return fmt.Sprintf("%s %s: %s to %s", self.Type, self.Path, GetTypeName(self.Old), GetTypeName(self.New))
+	case FidelityPrecisionLost, FidelityValueChanged:
+		return fmt.Sprintf("%s %s: %s to %s", self.Type, self.Path, ValueToStringN(self.Old, 32), ValueToStringN(self.New, 32))
+	default:
+		return fmt.Sprintf("%s %s", self.Type, self.Path)
+	}
+}
+
+//
+// FidelityReport
+//
+
+type FidelityReport []*FidelityIssue
+
+// ([fmt.Stringer] interface)
+func (self FidelityReport) String() string {
+	var builder strings.Builder
+	for index, issue := range self {
+		if index > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(issue.String())
+	}
+	return builder.String()
+}
+
+// Filters the report by issue type.
+func (self FidelityReport) Filter(type_ FidelityIssueType) FidelityReport {
+	var report FidelityReport
+	for _, issue := range self {
+		if issue.Type == type_ {
+			report = append(report, issue)
+		}
+	}
+	return report
+}
+
+// Utils
+
+type fidelityChecker struct {
+	report FidelityReport
+}
+
+func (self *fidelityChecker) record(type_ FidelityIssueType, path Path, old Value, new Value) {
+	self.report = append(self.report, &FidelityIssue{type_, path, old, new})
+}
+
+func (self *fidelityChecker) check(old Value, new Value, path Path) {
+	switch old_ := old.(type) {
+	case Map, StringMap:
+		switch new.(type) {
+		case Map, StringMap:
+			self.checkMaps(old_, new, path)
+			return
+		}
+
+	case List:
+		if new_, ok := new.(List); ok {
+			for index, element := range old_ {
+				if index < len(new_) {
+					self.check(element, new_[index], path.AppendList(index))
+				} else {
+					self.record(FidelityValueRemoved, path.AppendList(index), element, nil)
+				}
+			}
+			for index := len(old_); index < len(new_); index++ {
+				self.record(FidelityValueAdded, path.AppendList(index), nil, new_[index])
+			}
+			return
+		}
+	}
+
+	if (getTypeRank(old) == numberRank) && (getTypeRank(new) == numberRank) {
+		oldNumber := toNumber(old)
+		newNumber := toNumber(new)
+		if !numbersEqualExactly(oldNumber, newNumber) {
+			self.record(FidelityPrecisionLost, path, old, new)
+		} else if (oldNumber.kind == floatKind) != (newNumber.kind == floatKind) {
+			self.record(FidelityTypeConverted, path, old, new)
+		}
+		return
+	}
+
+	if oldTime, ok := old.(time.Time); ok {
+		if newTime, ok := new.(time.Time); ok {
+			if !oldTime.Equal(newTime) {
+				self.record(FidelityValueChanged, path, old, new)
+			}
+			return
+		}
+	}
+
+	if !Equals(old, new) {
+		if (GetTypeName(old) != GetTypeName(new)) && (ValueToString(old) == ValueToString(new)) {
+			self.record(FidelityTypeConverted, path, old, new)
+		} else {
+			self.record(FidelityValueChanged, path, old, new)
+		}
+	}
+}
+
+func (self *fidelityChecker) checkMaps(old Value, new Value, path Path) {
+	newEntries := sortedCompareEntries(new)
+	matched := make([]bool, len(newEntries))
+
+	for _, oldEntry := range sortedCompareEntries(old) {
+		path_ := path.AppendKey(oldEntry.key)
+
+		index, converted := findFidelityEntry(newEntries, matched, oldEntry.key)
+		if index == -1 {
+			self.record(FidelityValueRemoved, path_, oldEntry.value, nil)
+			continue
+		}
+
+		matched[index] = true
+		newEntry := newEntries[index]
+		if converted {
+			self.record(FidelityKeyConverted, path_, oldEntry.key, newEntry.key)
+		}
+		self.check(oldEntry.value, newEntry.value, path_)
+	}
+
+	for index, newEntry := range newEntries {
+		if !matched[index] {
+			self.record(FidelityValueAdded, path.AppendKey(newEntry.key), nil, newEntry.value)
+		}
+	}
+}
+
+// Returns the index of the equal key or, failing that, of the key with the
+// same string representation, in which case converted is true
+func findFidelityEntry(entries []compareEntry, matched []bool, key Value) (int, bool) {
+	for index, entry := range entries {
+		if !matched[index] && fidelityKeysEqual(entry.key, key) {
+			return index, false
+		}
+	}
+
+	// Complex keys must be wrapped in order to be stringified as YAML
+	if key_, err := MakeKey(key); err == nil {
+		key = key_
+	}
+
+	string_ := MapKeyToString(key)
+	for index, entry := range entries {
+		if !matched[index] && (MapKeyToString(entry.key) == string_) {
+			return index, true
+		}
+	}
+
+	return -1, false
+}
+
+// Numbers of different types are equal if they have exactly the same value
+func fidelityKeysEqual(a Value, b Value) bool {
+	if (getTypeRank(a) == numberRank) && (getTypeRank(b) == numberRank) {
+		return numbersEqualExactly(toNumber(a), toNumber(b))
+	}
+	return Equals(a, b)
+}
+
+// Unlike [compareNumbers], integers are not converted to floats when
+// compared with floats, so that precision loss is detected
+func numbersEqualExactly(a number, b number) bool {
+	switch {
+	case (a.kind == floatKind) && (b.kind == floatKind):
+		return (a.float == b.float) || (math.IsNaN(a.float) && math.IsNaN(b.float))
+
+	case a.kind == floatKind:
+		return floatEqualsInteger(a.float, b)
+
+	case b.kind == floatKind:
+		return floatEqualsInteger(b.float, a)
+
+	default:
+		return compareNumbers(integerNumberValue(a), integerNumberValue(b)) == 0
+	}
+}
+
+func floatEqualsInteger(float float64, integer number) bool {
+	if float != math.Trunc(float) {
+		return false
+	}
+
+	if integer.kind == signedKind {
+		return (float >= math.MinInt64) && (float < math.MaxInt64) && (int64(float) == integer.signed)
+	} else {
+		return (float >= 0) && (float < math.MaxUint64) && (uint64(float) == integer.unsigned)
+	}
+}
+
+// Normalizes non-negative integers to uint64, so that signed and unsigned
+// integers of the same value are compared as equal
+func integerNumberValue(number number) Value {
+	if number.kind == signedKind {
+		if number.signed >= 0 {
+			return uint64(number.signed)
+		}
+		return number.signed
+	}
+	return number.unsigned
+}
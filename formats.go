@@ -0,0 +1,97 @@
+package ard
+
+import (
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Returns true if value is a string with an IPv4 or IPv6 address.
+//
+// ([TypeValidator] signature)
+func IsIP(value Value) bool {
+	if string_, ok := value.(string); ok {
+		_, err := netip.ParseAddr(string_)
+		return err == nil
+	}
+	return false
+}
+
+// Returns true if value is a string with an IPv4 or IPv6 address prefix in
+// CIDR notation, e.g. "192.168.0.0/16".
+//
+// ([TypeValidator] signature)
+func IsCIDR(value Value) bool {
+	if string_, ok := value.(string); ok {
+		_, err := netip.ParsePrefix(string_)
+		return err == nil
+	}
+	return false
+}
+
+// Returns true if value is a string with an absolute URL, i.e. one with a
+// scheme, e.g. "https://example.com/path" or "mailto:user@example.com".
+//
+// ([TypeValidator] signature)
+func IsURL(value Value) bool {
+	if string_, ok := value.(string); ok {
+		if url_, err := url.Parse(string_); err == nil {
+			return (url_.Scheme != "") && ((url_.Host != "") || (url_.Opaque != "") || (url_.Path != ""))
+		}
+	}
+	return false
+}
+
+// Returns true if value is a string with a bare email address, e.g.
+// "user@example.com" (but not "User <user@example.com>").
+//
+// ([TypeValidator] signature)
+func IsEmail(value Value) bool {
+	if string_, ok := value.(string); ok {
+		if address, err := mail.ParseAddress(string_); err == nil {
+			return address.Address == string_
+		}
+	}
+	return false
+}
+
+// Returns true if value is a string with a hostname according to RFC 1123,
+// e.g. "example.com". A trailing dot is allowed.
+//
+// ([TypeValidator] signature)
+func IsHostname(value Value) bool {
+	if string_, ok := value.(string); ok {
+		string_ = strings.TrimSuffix(string_, ".")
+		if (len(string_) == 0) || (len(string_) > 253) {
+			return false
+		}
+
+		for _, label := range strings.Split(string_, ".") {
+			if !hostnameLabelRe.MatchString(label) {
+				return false
+			}
+		}
+
+		return true
+	}
+	return false
+}
+
+// Returns true if value is a string with a semantic version according to
+// https://semver.org, e.g. "1.2.3" or "1.0.0-rc.1+build.5". A "v" prefix is
+// not allowed.
+//
+// ([TypeValidator] signature)
+func IsSemVer(value Value) bool {
+	if string_, ok := value.(string); ok {
+		return semVerRe.MatchString(string_)
+	}
+	return false
+}
+
+var hostnameLabelRe = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// See: https://semver.org/#is-there-a-suggested-regular-expression-regex-to-check-a-semver-string
+var semVerRe = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
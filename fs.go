@@ -0,0 +1,110 @@
+package ard
+
+import (
+	"fmt"
+	"io/fs"
+	pathpkg "path"
+	"strings"
+)
+
+// Reads and decodes a file from an [fs.FS] to ARD. Calls [Read].
+//
+// If format is empty then it will be determined from the file extension. See
+// [GetFormatForPath].
+//
+// Useful for embedded files via go:embed.
+func ReadFS(fsys fs.FS, path string, format string, locate bool) (Value, Locator, error) {
+	if format == "" {
+		if format = GetFormatForPath(path); format == "" {
+			return nil, nil, fmt.Errorf("cannot determine format for file: %s", path)
+		}
+	}
+
+	if file, err := fsys.Open(path); err == nil {
+		defer file.Close()
+		return Read(file, format, locate)
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Reads and decodes all matching files in a directory tree of an [fs.FS] to
+// ARD. Returns a [Map] in which the keys are the file paths (as used by the
+// [fs.FS]) and the values are the decoded contents.
+//
+// The pattern is matched against the file's base name via [path.Match]. If
+// empty then all files with a recognized format will be matched. See
+// [GetFormatForPath].
+//
+// If format is empty then it will be determined per file from its extension.
+// Otherwise it will be used for all matched files.
+//
+// Useful for embedded directory trees via go:embed.
+func ReadDirFS(fsys fs.FS, root string, pattern string, format string) (Map, error) {
+	map_ := make(Map)
+
+	if err := fs.WalkDir(fsys, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		if pattern != "" {
+			if matched, err := pathpkg.Match(pattern, entry.Name()); err == nil {
+				if !matched {
+					return nil
+				}
+			} else {
+				return err
+			}
+		}
+
+		format_ := format
+		if format_ == "" {
+			if format_ = GetFormatForPath(path); format_ == "" {
+				if pattern != "" {
+					return fmt.Errorf("cannot determine format for file: %s", path)
+				}
+				return nil
+			}
+		}
+
+		if value, _, err := ReadFS(fsys, path, format_, false); err == nil {
+			map_[path] = value
+			return nil
+		} else {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}); err == nil {
+		return map_, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the ARD format for a file path according to its extension, or an
+// empty string if not recognized.
+//
+// Recognized extensions are ".yaml", ".yml", ".json", ".xjson", ".xml",
+// ".cbor", ".msgpack", and ".mpk".
+func GetFormatForPath(path string) string {
+	switch strings.ToLower(pathpkg.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	case ".xjson":
+		return "xjson"
+	case ".xml":
+		return "xml"
+	case ".cbor":
+		return "cbor"
+	case ".msgpack", ".mpk":
+		return "messagepack"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,192 @@
+package ard
+
+import (
+	"fmt"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tliron/yamlkeys"
+)
+
+// Converts an ARD [Value] to Go source code for an equivalent literal, e.g.
+// for embedding fixtures in tests or for code generation. The result is
+// gofmt-compatible and uses the "ard" package qualifier, e.g.:
+//
+//	ard.Map{
+//		"name":  "server",
+//		"ports": ard.List{int64(80), int64(443)},
+//	}
+//
+// Primitive types are preserved via conversions, except for int, float64,
+// string, and bool, which are the default types of untyped constants. Floats
+// always have a decimal point or exponent, and NaN and infinities use the
+// "math" package. []byte are converted from quoted strings, and [time.Time]
+// values use [time.Date] with [time.UTC] or [time.FixedZone]. Map entries are
+// sorted via [Compare] and complex keys are wrapped with [MustMakeKey].
+//
+// Non-empty maps, as well as lists that contain maps or lists, are written
+// over multiple lines. Other non-ARD values are written via
+// [fmt.Sprintf]("%#v"), which is not guaranteed to be valid Go.
+func ToGoLiteral(value Value) string {
+	var builder strings.Builder
+	builder.WriteString(goLiteralPrefix)
+	writeGoLiteral(&builder, value, 0)
+
+	// Aligns map entries
+	if source, err := format.Source([]byte(builder.String())); err == nil {
+		return strings.TrimSpace(strings.TrimPrefix(string(source), goLiteralPrefix))
+	} else {
+		return strings.TrimPrefix(builder.String(), goLiteralPrefix)
+	}
+}
+
+// Utils
+
+const goLiteralPrefix = "package ard\n\nvar _ = "
+
+func writeGoLiteral(builder *strings.Builder, value Value, indent int) {
+	switch value_ := value.(type) {
+	case nil:
+		builder.WriteString("nil")
+
+	case string:
+		builder.WriteString(strconv.Quote(value_))
+
+	case bool:
+		builder.WriteString(strconv.FormatBool(value_))
+
+	case int:
+		builder.WriteString(strconv.FormatInt(int64(value_), 10))
+	case int64:
+		writeGoConversion(builder, "int64", strconv.FormatInt(value_, 10))
+	case int32:
+		writeGoConversion(builder, "int32", strconv.FormatInt(int64(value_), 10))
+	case int16:
+		writeGoConversion(builder, "int16", strconv.FormatInt(int64(value_), 10))
+	case int8:
+		writeGoConversion(builder, "int8", strconv.FormatInt(int64(value_), 10))
+
+	case uint:
+		writeGoConversion(builder, "uint", strconv.FormatUint(uint64(value_), 10))
+	case uint64:
+		writeGoConversion(builder, "uint64", strconv.FormatUint(value_, 10))
+	case uint32:
+		writeGoConversion(builder, "uint32", strconv.FormatUint(uint64(value_), 10))
+	case uint16:
+		writeGoConversion(builder, "uint16", strconv.FormatUint(uint64(value_), 10))
+	case uint8:
+		writeGoConversion(builder, "uint8", strconv.FormatUint(uint64(value_), 10))
+
+	case float64:
+		builder.WriteString(goFloatLiteral(value_, 64))
+	case float32:
+		writeGoConversion(builder, "float32", goFloatLiteral(float64(value_), 32))
+
+	case []byte:
+		writeGoConversion(builder, "[]byte", strconv.Quote(string(value_)))
+
+	case time.Time:
+		builder.WriteString(goTimeLiteral(value_))
+
+	case List:
+		multiline := false
+		for _, element := range value_ {
+			switch element.(type) {
+			case Map, StringMap, List:
+				multiline = true
+			}
+		}
+
+		builder.WriteString("ard.List{")
+		for index, element := range value_ {
+			if multiline {
+				writeGoLiteralNewline(builder, indent+1)
+			} else if index > 0 {
+				builder.WriteString(", ")
+			}
+			writeGoLiteral(builder, element, indent+1)
+			if multiline {
+				builder.WriteRune(',')
+			}
+		}
+		if multiline {
+			writeGoLiteralNewline(builder, indent)
+		}
+		builder.WriteRune('}')
+
+	case Map, StringMap:
+		if _, ok := value_.(Map); ok {
+			builder.WriteString("ard.Map{")
+		} else {
+			builder.WriteString("ard.StringMap{")
+		}
+		entries := sortedCompareEntries(value_)
+		for _, entry := range entries {
+			writeGoLiteralNewline(builder, indent+1)
+			if IsSimpleKey(entry.key) {
+				writeGoLiteral(builder, entry.key, indent+1)
+			} else {
+				builder.WriteString("ard.MustMakeKey(")
+				writeGoLiteral(builder, entry.key, indent+1)
+				builder.WriteRune(')')
+			}
+			builder.WriteString(": ")
+			writeGoLiteral(builder, entry.value, indent+1)
+			builder.WriteRune(',')
+		}
+		if len(entries) > 0 {
+			writeGoLiteralNewline(builder, indent)
+		}
+		builder.WriteRune('}')
+
+	case yamlkeys.Key:
+		writeGoLiteral(builder, value_.GetKeyData(), indent)
+
+	default:
+		builder.WriteString(fmt.Sprintf("%#v", value_))
+	}
+}
+
+func writeGoConversion(builder *strings.Builder, type_ string, literal string) {
+	builder.WriteString(type_)
+	builder.WriteRune('(')
+	builder.WriteString(literal)
+	builder.WriteRune(')')
+}
+
+func writeGoLiteralNewline(builder *strings.Builder, indent int) {
+	builder.WriteRune('\n')
+	builder.WriteString(strings.Repeat("\t", indent))
+}
+
+func goFloatLiteral(value float64, bitSize int) string {
+	switch {
+	case math.IsNaN(value):
+		return "math.NaN()"
+	case math.IsInf(value, 1):
+		return "math.Inf(1)"
+	case math.IsInf(value, -1):
+		return "math.Inf(-1)"
+	}
+
+	literal := strconv.FormatFloat(value, 'g', -1, bitSize)
+	if !strings.ContainsAny(literal, ".e") {
+		literal += ".0"
+	}
+	return literal
+}
+
+func goTimeLiteral(value time.Time) string {
+	var location string
+	if value.Location() == time.UTC {
+		location = "time.UTC"
+	} else {
+		name, offset := value.Zone()
+		location = fmt.Sprintf("time.FixedZone(%s, %d)", strconv.Quote(name), offset)
+	}
+
+	return fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, %s)", value.Year(), value.Month().String(), value.Day(), value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), location)
+}
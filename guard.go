@@ -0,0 +1,95 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTooManyValues = errors.New("too many values")
+	ErrTooDeep       = errors.New("too deep")
+)
+
+//
+// Guard
+//
+
+// Guards recursive operations on very large values, e.g. to protect
+// servers from untrusted input. See [CopyWithGuard], [ValidCopyWithGuard],
+// and [MergeWithGuard].
+type Guard struct {
+	// Maximum number of values visited, including all nested values.
+	// 0 means no limit. Exceeding it results in an [ErrTooManyValues] error.
+	MaxValues int
+
+	// Maximum nesting depth of [Map], [StringMap], and [List]. 0 means no
+	// limit. Exceeding it results in an [ErrTooDeep] error.
+	MaxDepth int
+
+	// If set, will be called with the number of values visited so far every
+	// ProgressInterval values. Returning an error will abort the operation.
+	Progress func(values int) error
+
+	// How often to call Progress. If 0 then 1000 will be used.
+	ProgressInterval int
+}
+
+func (self *Guard) newState() *guardState {
+	if self == nil {
+		return nil
+	}
+
+	state := guardState{guard: self, progressInterval: self.ProgressInterval}
+	if state.progressInterval <= 0 {
+		state.progressInterval = 1000
+	}
+	return &state
+}
+
+// Utils
+
+// A nil state is valid and does nothing.
+type guardState struct {
+	guard            *Guard
+	progressInterval int
+	values           int
+	depth            int
+}
+
+func (self *guardState) visit() error {
+	if self == nil {
+		return nil
+	}
+
+	self.values++
+
+	if (self.guard.MaxValues > 0) && (self.values > self.guard.MaxValues) {
+		return fmt.Errorf("%w: more than %d", ErrTooManyValues, self.guard.MaxValues)
+	}
+
+	if (self.guard.Progress != nil) && (self.values%self.progressInterval == 0) {
+		return self.guard.Progress(self.values)
+	}
+
+	return nil
+}
+
+func (self *guardState) push() error {
+	if self == nil {
+		return nil
+	}
+
+	self.depth++
+
+	if (self.guard.MaxDepth > 0) && (self.depth > self.guard.MaxDepth) {
+		return fmt.Errorf("%w: more than %d", ErrTooDeep, self.guard.MaxDepth)
+	}
+
+	return nil
+}
+
+func (self *guardState) pop() {
+	if self != nil {
+		self.depth--
+	}
+}
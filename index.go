@@ -0,0 +1,162 @@
+package ard
+
+import (
+	"strconv"
+)
+
+//
+// Index
+//
+
+// A trie of the paths in a value, for many repeated lookups against a static
+// document, e.g. in a server. Lookups take time proportional to the length
+// of the path rather than walking the maps and lists along the way.
+//
+// Map keys are compared via [MapKeyToString], thus integer map keys can be
+// looked up as list indexes, as with [Node.GetDotted].
+//
+// The index refers to the values in the document rather than copying them,
+// thus the document must not be modified after the index is built. Likewise,
+// returned values should not be modified.
+type Index struct {
+	root *indexNode
+}
+
+// Builds an [Index] for a value.
+func BuildIndex(value Value) *Index {
+	return &Index{newIndexNode(value, PathElement{})}
+}
+
+// Returns the value at the path, or false if the path does not exist. An
+// empty path returns the root value.
+func (self *Index) Get(path Path) (Value, bool) {
+	node := self.root
+	for _, element := range path {
+		if node = node.get(element); node == nil {
+			return nil, false
+		}
+	}
+	return node.value, true
+}
+
+// Returns the value at a path in [Path.String] format, or false if the path
+// is malformed (see [ParsePath]) or does not exist.
+func (self *Index) GetDotted(path string) (Value, bool) {
+	if path_, err := ParsePath(path); err == nil {
+		return self.Get(path_)
+	} else {
+		return nil, false
+	}
+}
+
+// Returns the paths and values matching a path pattern, as well as all the
+// values nested in them. The pattern is in [Path.String] format, in which "*"
+// can be used as a wildcard for a whole path element, e.g. "spec.*" or
+// "servers[*].ports". An empty pattern matches the root.
+//
+// The results are in depth-first order, with map entries sorted by key
+// according to [Compare], as with [Search].
+func (self *Index) Under(pattern string) ([]SearchResult, error) {
+	pattern_, err := parseSchemaPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	self.root.match(pattern_, nil, &results)
+	return results, nil
+}
+
+// Utils
+
+type indexNode struct {
+	value   Value
+	element PathElement // leading to this node from its parent
+	list    []*indexNode
+	map_    map[string]*indexNode
+	entries []*indexNode // sorted by key according to Compare
+	isList  bool
+	isMap   bool
+}
+
+func newIndexNode(value Value, element PathElement) *indexNode {
+	node := indexNode{value: value, element: element}
+
+	switch value_ := value.(type) {
+	case Map, StringMap:
+		node.isMap = true
+		entries := sortedCompareEntries(value_)
+		node.map_ = make(map[string]*indexNode, len(entries))
+		node.entries = make([]*indexNode, len(entries))
+		for index, entry := range entries {
+			child := newIndexNode(entry.value, NewKeyPathElement(entry.key))
+			node.map_[MapKeyToString(entry.key)] = child
+			node.entries[index] = child
+		}
+
+	case List:
+		node.isList = true
+		node.list = make([]*indexNode, len(value_))
+		for index, element := range value_ {
+			node.list[index] = newIndexNode(element, NewListPathElement(index))
+		}
+	}
+
+	return &node
+}
+
+// Returns nil if not found
+func (self *indexNode) get(element PathElement) *indexNode {
+	switch element.Type {
+	case ListPathType, SequencedListPathType:
+		index := element.Value.(int)
+		if self.isList {
+			if (index >= 0) && (index < len(self.list)) {
+				return self.list[index]
+			}
+			return nil
+		} else if self.isMap && (element.Type == ListPathType) {
+			// Integer list indexes are also used as map keys
+			return self.map_[strconv.Itoa(index)]
+		}
+		return nil
+
+	default:
+		if self.isMap {
+			return self.map_[MapKeyToString(element.Value)]
+		}
+		return nil
+	}
+}
+
+func (self *indexNode) match(pattern Path, path Path, results *[]SearchResult) {
+	if len(pattern) == 0 {
+		self.collect(path, results)
+		return
+	}
+
+	element := pattern[0]
+	if isPathWildcard(element) {
+		children := self.entries
+		if element.Type == ListPathType {
+			children = self.list
+		}
+		for _, child := range children {
+			child.match(pattern[1:], path.Append(child.element), results)
+		}
+	} else if child := self.get(element); child != nil {
+		child.match(pattern[1:], path.Append(child.element), results)
+	}
+}
+
+func (self *indexNode) collect(path Path, results *[]SearchResult) {
+	*results = append(*results, SearchResult{path, self.value})
+
+	children := self.entries
+	if self.isList {
+		children = self.list
+	}
+	for _, child := range children {
+		child.collect(path.Append(child.element), results)
+	}
+}
@@ -0,0 +1,66 @@
+package ard
+
+// Replaces string map keys with shared instances, so that equal keys are
+// stored only once. This can greatly reduce memory use for large lists of
+// similar maps, e.g. with "name", "value", and "type" keys repeated millions
+// of times. Returns the value, in which all [Map] and [StringMap] have been
+// replaced with new instances.
+//
+// The string table is used only for this call. To intern keys while decoding
+// see [JSONDecodeOptions].InternKeys and [MessagePackDecodeOptions].InternKeys.
+func InternKeys(value Value) Value {
+	return make(keyInterner).internKeys(value)
+}
+
+//
+// keyInterner
+//
+
+// Methods are nil-safe
+type keyInterner map[string]string
+
+func (self keyInterner) intern(key string) string {
+	if self == nil {
+		return key
+	}
+
+	if key_, ok := self[key]; ok {
+		return key_
+	} else {
+		self[key] = key
+		return key
+	}
+}
+
+func (self keyInterner) internKey(key Value) Value {
+	if key_, ok := key.(string); ok {
+		return self.intern(key_)
+	} else {
+		return key
+	}
+}
+
+func (self keyInterner) internKeys(value Value) Value {
+	switch value_ := value.(type) {
+	case Map:
+		map_ := make(Map, len(value_))
+		for key, element := range value_ {
+			map_[self.internKey(key)] = self.internKeys(element)
+		}
+		return map_
+
+	case StringMap:
+		map_ := make(StringMap, len(value_))
+		for key, element := range value_ {
+			map_[self.intern(key)] = self.internKeys(element)
+		}
+		return map_
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = self.internKeys(element)
+		}
+	}
+
+	return value
+}
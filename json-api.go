@@ -0,0 +1,92 @@
+package ard
+
+import (
+	"encoding/json"
+	"io"
+	"sync/atomic"
+)
+
+//
+// JSONEncoder
+//
+
+// Implemented by [json.Encoder] and compatible encoders of 3rd-party JSON
+// libraries.
+type JSONEncoder interface {
+	Encode(value any) error
+	SetIndent(prefix string, indent string)
+	SetEscapeHTML(on bool)
+}
+
+//
+// JSONDecoder
+//
+
+// Implemented by [json.Decoder] and compatible decoders of 3rd-party JSON
+// libraries.
+type JSONDecoder interface {
+	Decode(value any) error
+	UseNumber()
+	More() bool
+}
+
+//
+// JSONAPI
+//
+
+// The JSON implementation used for reading and writing JSON and XJSON. See
+// [SetJSONAPI].
+type JSONAPI struct {
+	Marshal    func(value any) ([]byte, error)
+	NewEncoder func(writer io.Writer) JSONEncoder
+	NewDecoder func(reader io.Reader) JSONDecoder
+}
+
+// Go's built-in [encoding/json] implementation. This is the default.
+var StdJSONAPI = JSONAPI{
+	Marshal: json.Marshal,
+	NewEncoder: func(writer io.Writer) JSONEncoder {
+		return json.NewEncoder(writer)
+	},
+	NewDecoder: func(reader io.Reader) JSONDecoder {
+		return json.NewDecoder(reader)
+	},
+}
+
+var globalJSONAPI atomic.Pointer[JSONAPI]
+
+// Sets the package-wide [JSONAPI], allowing for a faster 3rd-party JSON
+// library to be used instead of Go's built-in one, e.g. jsoniter, go-json, or
+// sonic. The library must be compatible with [encoding/json], notably in
+// supporting [json.Marshaler] (used by the XJSON types) and in decoding
+// objects as map[string]any.
+//
+// Example:
+//
+//	ard.SetJSONAPI(&ard.JSONAPI{
+//		Marshal: jsoniter.Marshal,
+//		NewEncoder: func(writer io.Writer) ard.JSONEncoder {
+//			return jsoniter.NewEncoder(writer)
+//		},
+//		NewDecoder: func(reader io.Reader) ard.JSONDecoder {
+//			return jsoniter.NewDecoder(reader)
+//		},
+//	})
+//
+// Can be nil, which restores [StdJSONAPI].
+//
+// Note that [JSONEventProducer] and [ValidateJSON] always use [encoding/json]
+// because they rely on its tokenizer.
+//
+// This function is safe to call concurrently.
+func SetJSONAPI(api *JSONAPI) {
+	globalJSONAPI.Store(api)
+}
+
+func getJSONAPI() *JSONAPI {
+	if api := globalJSONAPI.Load(); api != nil {
+		return api
+	} else {
+		return &StdJSONAPI
+	}
+}
@@ -0,0 +1,195 @@
+package ard
+
+import (
+	"strconv"
+	"time"
+)
+
+//
+// JSONIncompatibleMode
+//
+
+// How to handle values that [encoding/json]-shaped data cannot represent. See
+// [ToJSONCompatible].
+type JSONIncompatibleMode int
+
+const (
+	// Results in an error.
+	JSONIncompatibleError JSONIncompatibleMode = 0
+
+	// Converted to a string: bytes via [EncodeBytes], integers in decimal,
+	// timestamps in [time.RFC3339Nano], and special floats as "NaN",
+	// "Infinity", or "-Infinity".
+	JSONIncompatibleString JSONIncompatibleMode = 1
+
+	// Tagged using the XJSON codes, e.g. {"$ard.bytes": "..."}, so that the
+	// result can be restored via [UnpackXJSON]. Map keys are escaped
+	// accordingly. Timestamps, which have no XJSON code, are converted to
+	// strings as in JSONIncompatibleString.
+	JSONIncompatibleXJSON JSONIncompatibleMode = 2
+)
+
+//
+// JSONCompatibleOptions
+//
+
+type JSONCompatibleOptions struct {
+	// For []byte.
+	Bytes JSONIncompatibleMode
+
+	// Representation for []byte when converted to strings. Defaults to
+	// base64.
+	BytesEncoding BytesEncoding
+
+	// For integers with a magnitude larger than 2^53, which cannot be
+	// represented exactly by float64.
+	BigIntegers JSONIncompatibleMode
+
+	// For [time.Time].
+	Timestamps JSONIncompatibleMode
+
+	// For NaN and infinite floats.
+	SpecialFloats JSONIncompatibleMode
+
+	// Used for non-ARD values. If nil then the default reflector for "json"
+	// will be used (see [DefaultReflector]).
+	Reflector *Reflector
+}
+
+func (self *JSONCompatibleOptions) usesXJSON() bool {
+	return (self.Bytes == JSONIncompatibleXJSON) || (self.BigIntegers == JSONIncompatibleXJSON) || (self.Timestamps == JSONIncompatibleXJSON) || (self.SpecialFloats == JSONIncompatibleXJSON)
+}
+
+// Converts a value to a new tree of only map[string]any, []any, float64,
+// string, bool, and nil, for handing to libraries that expect
+// [encoding/json]-shaped data.
+//
+// Non-ARD values are first converted via [ValidCopyMapsToStringMaps], thus
+// map keys are converted via [MapKeyToString]. All numbers become float64.
+// Values that cannot be represented are handled according to the options.
+//
+// The options argument can be nil, in which case all such values will result
+// in an error.
+func ToJSONCompatible(value Value, options *JSONCompatibleOptions) (any, error) {
+	if options == nil {
+		options = new(JSONCompatibleOptions)
+	}
+
+	reflector := options.Reflector
+	if reflector == nil {
+		reflector = DefaultReflector("json")
+	}
+
+	if value, err := ValidCopyMapsToStringMaps(value, reflector); err == nil {
+		return toJSONCompatible(value, nil, options, options.usesXJSON())
+	} else {
+		return nil, err
+	}
+}
+
+// Utils
+
+// Converts in place when possible
+func toJSONCompatible(value Value, path Path, options *JSONCompatibleOptions, escapeKeys bool) (any, error) {
+	switch value_ := value.(type) {
+	case nil, string, bool:
+		return value_, nil
+
+	case int64, int32, int16, int8, int:
+		integer := toNumber(value_).signed
+		if (integer >= -maxExactFloatInteger) && (integer <= maxExactFloatInteger) {
+			return float64(integer), nil
+		}
+
+		switch options.BigIntegers {
+		case JSONIncompatibleString:
+			return strconv.FormatInt(integer, 10), nil
+		case JSONIncompatibleXJSON:
+			return StringMap{XJSONIntegerCode: strconv.FormatInt(integer, 10)}, nil
+		default:
+			return nil, newPathErrorf(path, "is too large for JSON: %d", integer)
+		}
+
+	case uint64, uint32, uint16, uint8, uint:
+		uinteger := toNumber(value_).unsigned
+		if uinteger <= maxExactFloatInteger {
+			return float64(uinteger), nil
+		}
+
+		switch options.BigIntegers {
+		case JSONIncompatibleString:
+			return strconv.FormatUint(uinteger, 10), nil
+		case JSONIncompatibleXJSON:
+			return StringMap{XJSONUIntegerCode: strconv.FormatUint(uinteger, 10)}, nil
+		default:
+			return nil, newPathErrorf(path, "is too large for JSON: %d", uinteger)
+		}
+
+	case float64:
+		return toJSONCompatibleFloat(value_, path, options)
+
+	case float32:
+		return toJSONCompatibleFloat(float64Shortest(value_), path, options)
+
+	case []byte:
+		switch options.Bytes {
+		case JSONIncompatibleString:
+			return EncodeBytes(value_, options.BytesEncoding), nil
+		case JSONIncompatibleXJSON:
+			return StringMap{XJSONBytesCode: encodeTextBytes(value_)}, nil
+		default:
+			return nil, newPathErrorf(path, "is not supported by JSON: bytes")
+		}
+
+	case time.Time:
+		switch options.Timestamps {
+		case JSONIncompatibleString, JSONIncompatibleXJSON:
+			return value_.Format(time.RFC3339Nano), nil
+		default:
+			return nil, newPathErrorf(path, "is not supported by JSON: timestamp")
+		}
+
+	case StringMap:
+		map_ := make(map[string]any, len(value_))
+		for key, element := range value_ {
+			key_ := key
+			if escapeKeys {
+				key_ = escapeXjsonKey(key)
+			}
+
+			var err error
+			if map_[key_], err = toJSONCompatible(element, path.AppendField(key), options, escapeKeys); err != nil {
+				return nil, err
+			}
+		}
+		return map_, nil
+
+	case List:
+		list := make([]any, len(value_))
+		for index, element := range value_ {
+			var err error
+			if list[index], err = toJSONCompatible(element, path.AppendList(index), options, escapeKeys); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+
+	default:
+		return nil, newPathErrorf(path, "is not supported by JSON: %T", value)
+	}
+}
+
+func toJSONCompatibleFloat(float float64, path Path, options *JSONCompatibleOptions) (any, error) {
+	if token, ok := formatSpecialFloat(float); ok {
+		switch options.SpecialFloats {
+		case JSONIncompatibleString:
+			return token, nil
+		case JSONIncompatibleXJSON:
+			return StringMap{XJSONFloatCode: token}, nil
+		default:
+			return nil, newPathErrorf(path, "is not supported by JSON: %s", token)
+		}
+	} else {
+		return float, nil
+	}
+}
@@ -0,0 +1,451 @@
+package ard
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tliron/yamlkeys"
+)
+
+//
+// JSONDecodeOptions
+//
+
+type JSONDecodeOptions struct {
+	// When true returns maps as [StringMap], otherwise they will be [Map].
+	UseStringMaps bool
+
+	// When true numbers will be decoded as [json.Number] instead of float64,
+	// preserving their integer-ness and precision. Use [ResolveJSONNumbers] or
+	// [ParseJSONNumber] to convert them to ARD numbers.
+	//
+	// Note that [json.Number] is not ARD. However, it is supported by [Node]
+	// number functions, [Reflector.Pack], and [ValidCopy].
+	UseNumber bool
+
+	// When true whole numbers will be decoded as int64 (or uint64 if too large
+	// for int64) instead of float64, e.g. both 1 and 1.0 will be decoded as
+	// int64. Integers are parsed exactly, so large integers do not lose
+	// precision. Whole numbers with a fractional part or exponent in the JSON
+	// (e.g. 1.0 or 1e3) are converted only if their magnitude is at most 2^53,
+	// beyond which float64 cannot represent all integers exactly. Negative zero
+	// (e.g. -0 or -0.0) is decoded as float64 in order to keep its sign.
+	//
+	// Other numbers will be float64 or [json.Number] according to UseNumber.
+	// Numbers out of the range of float64 (e.g. 1e400) are an error unless
+	// UseNumber is true.
+	PreserveIntegers bool
+
+	// When true the strings "NaN", "Infinity", and "-Infinity" will be decoded
+	// as the special float64 values. This is the decoding counterpart of
+	// [JSONEncodeOptions].SpecialFloatStrings.
+	SpecialFloatStrings bool
+
+	// When true repeated map keys will share memory. See [InternKeys].
+	InternKeys bool
+
+	// Path patterns in [Path.String] format, in which "*" can be used as a
+	// wildcard for a whole path element, e.g. "servers[*].port" or
+	// "services.*.image". When not empty only values at or under these paths
+	// are decoded, as well as the maps and lists leading to them (which might
+	// thus be empty). If the root value itself is not decoded then the result
+	// is nil.
+	IncludePaths []string
+
+	// Path patterns like IncludePaths. Values at or under these paths are not
+	// decoded. Takes precedence over IncludePaths.
+	//
+	// When either IncludePaths or ExcludePaths is not empty the JSON is
+	// decoded via a [JSONEventProducer], which skips unwanted values without
+	// decoding them, thus significantly reducing memory use when only a
+	// fraction of a huge document is needed. Note that the standard library's
+	// decoder is used in this case, not the [JSONAPI].
+	ExcludePaths []string
+
+	// For [UTF8Strict] the input is validated while it is read, and invalid
+	// UTF-8 is an error with its line and column. Otherwise invalid UTF-8
+	// sequences in strings are replaced with U+FFFD, which is what JSON
+	// decoders do, thus [UTF8Replace] is the same as [UTF8Unchecked].
+	UTF8 UTF8Mode
+}
+
+//
+// JSONEncodeOptions
+//
+
+type JSONEncodeOptions struct {
+	// When Indent is not empty, each line will begin with Prefix.
+	Prefix string
+
+	// When not empty output will be multi-line, with each nesting level
+	// indented by this string.
+	Indent string
+
+	// When true "<", ">", and "&" in strings will be escaped (e.g. as
+	// "\u003c") for safe embedding in HTML.
+	EscapeHTML bool
+
+	// When true the newline normally written after the value is omitted.
+	OmitTrailingNewline bool
+
+	// JSON cannot represent NaN and infinite floats. When true they will be
+	// encoded as the strings "NaN", "Infinity", and "-Infinity" (as used by
+	// JavaScript and other JSON implementations). Otherwise, they will result
+	// in an encoding error.
+	SpecialFloatStrings bool
+
+	// When true, map keys will be sorted according to [Compare] before they
+	// are converted to strings. Thus numeric keys will be in numeric order
+	// rather than lexical order, as with [YAMLNodeOptions].SortKeys. For
+	// XJSON this also sorts the entries of maps with non-string keys.
+	SortKeys bool
+}
+
+// Converts a [json.Number] to an int64 if it is an integer, to a uint64 if
+// it is an integer too large for int64, and otherwise to a float64.
+func ParseJSONNumber(number json.Number) (Value, error) {
+	s := string(number)
+	if integer, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return integer, nil
+	} else if uinteger, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return uinteger, nil
+	} else {
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
+// Converts all [json.Number] to ARD numbers via [ParseJSONNumber], in place
+// when possible. Returns the converted value.
+//
+// Malformed numbers are left as is.
+func ResolveJSONNumbers(value Value) Value {
+	switch value_ := value.(type) {
+	case json.Number:
+		if number, err := ParseJSONNumber(value_); err == nil {
+			return number
+		}
+
+	case Map:
+		for key, element := range value_ {
+			value_[key] = ResolveJSONNumbers(element)
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = ResolveJSONNumbers(element)
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = ResolveJSONNumbers(element)
+		}
+	}
+
+	return value
+}
+
+// Converts NaN and infinite floats to strings when asStrings is true,
+// otherwise returns an error for them. Converts in place when possible.
+func encodeJSONSpecialFloats(value Value, path Path, asStrings bool) (Value, error) {
+	var err error
+	switch value_ := value.(type) {
+	case float64:
+		return encodeJSONSpecialFloat(value_, path, asStrings)
+
+	case float32:
+		return encodeJSONSpecialFloat(float64(value_), path, asStrings)
+
+	case Map:
+		for key, element := range value_ {
+			if value_[key], err = encodeJSONSpecialFloats(element, path.AppendKey(key), asStrings); err != nil {
+				return nil, err
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			if value_[key], err = encodeJSONSpecialFloats(element, path.AppendField(key), asStrings); err != nil {
+				return nil, err
+			}
+		}
+
+	case List:
+		for index, element := range value_ {
+			if value_[index], err = encodeJSONSpecialFloats(element, path.AppendList(index), asStrings); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+func encodeJSONSpecialFloat(float float64, path Path, asStrings bool) (Value, error) {
+	if token, ok := formatSpecialFloat(float); ok {
+		if asStrings {
+			return token, nil
+		} else {
+			return nil, newPathErrorf(path, "is not supported by JSON: %s", token)
+		}
+	} else {
+		return float, nil
+	}
+}
+
+// Converts "NaN", "Infinity", and "-Infinity" strings to floats, in place when
+// possible
+func decodeJSONSpecialFloats(value Value) Value {
+	switch value_ := value.(type) {
+	case string:
+		if float, ok := parseSpecialFloat(value_); ok {
+			return float
+		}
+
+	case Map:
+		for key, element := range value_ {
+			value_[key] = decodeJSONSpecialFloats(element)
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = decodeJSONSpecialFloats(element)
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = decodeJSONSpecialFloats(element)
+		}
+	}
+
+	return value
+}
+
+// Returns "NaN", "Infinity", or "-Infinity"
+func formatSpecialFloat(float float64) (string, bool) {
+	switch {
+	case math.IsNaN(float):
+		return "NaN", true
+	case math.IsInf(float, 1):
+		return "Infinity", true
+	case math.IsInf(float, -1):
+		return "-Infinity", true
+	default:
+		return "", false
+	}
+}
+
+// Accepts only "NaN", "Infinity", or "-Infinity"
+func parseSpecialFloat(s string) (float64, bool) {
+	switch s {
+	case "NaN":
+		return math.NaN(), true
+	case "Infinity":
+		return math.Inf(1), true
+	case "-Infinity":
+		return math.Inf(-1), true
+	default:
+		return 0, false
+	}
+}
+
+// Max integer magnitude that float64 can represent exactly
+const maxExactFloatInteger = 1 << 53
+
+func preserveJSONIntegers(value Value, useNumber bool, path Path) (Value, error) {
+	switch value_ := value.(type) {
+	case json.Number:
+		s := string(value_)
+		float, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			if useNumber {
+				return value_, nil
+			}
+			return nil, newPathErrorf(path, "is out of range for float64: %s", s)
+		}
+
+		if (float == 0) && strings.HasPrefix(s, "-") {
+			// Keep the sign of negative zero
+			return float, nil
+		} else if integer, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return integer, nil
+		} else if uinteger, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return uinteger, nil
+		} else if (float == math.Trunc(float)) && (math.Abs(float) <= maxExactFloatInteger) {
+			return int64(float), nil
+		} else if !useNumber {
+			return float, nil
+		}
+
+	case Map:
+		for key, element := range value_ {
+			if element_, err := preserveJSONIntegers(element, useNumber, path.AppendKey(key)); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			if element_, err := preserveJSONIntegers(element, useNumber, path.AppendField(key)); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+		}
+
+	case List:
+		for index, element := range value_ {
+			if element_, err := preserveJSONIntegers(element, useNumber, path.AppendList(index)); err == nil {
+				value_[index] = element_
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+var jsonNumberType = reflect.TypeFor[json.Number]()
+
+func encodeJSON(value Value, writer io.Writer, options *JSONEncodeOptions) error {
+	if options == nil {
+		options = new(JSONEncodeOptions)
+	}
+
+	if options.OmitTrailingNewline {
+		var buffer bytes.Buffer
+		if err := encodeJSON(value, &buffer, &JSONEncodeOptions{
+			Prefix:     options.Prefix,
+			Indent:     options.Indent,
+			EscapeHTML: options.EscapeHTML,
+		}); err == nil {
+			_, err = writer.Write(bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}))
+			return err
+		} else {
+			return err
+		}
+	}
+
+	encoder := getJSONAPI().NewEncoder(writer)
+	encoder.SetEscapeHTML(options.EscapeHTML)
+	if options.Indent != "" {
+		encoder.SetIndent(options.Prefix, options.Indent)
+	}
+	return encoder.Encode(value)
+}
+
+// Converts all maps to [sortedJSONMap], in place when possible
+func sortJSONMaps(value Value) Value {
+	switch value_ := value.(type) {
+	case Map:
+		entries := make([]KeyValue, 0, len(value_))
+		for key, element := range value_ {
+			entries = append(entries, KeyValue{key, element})
+		}
+		return newSortedJSONMap(entries)
+
+	case StringMap:
+		entries := make([]KeyValue, 0, len(value_))
+		for key, element := range value_ {
+			entries = append(entries, KeyValue{key, element})
+		}
+		return newSortedJSONMap(entries)
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = sortJSONMaps(element)
+		}
+	}
+
+	return value
+}
+
+// Converts all [XJSONMap] to their encoded form with sorted entries, in place
+// when possible
+func sortXJSONMaps(value any) any {
+	switch value_ := value.(type) {
+	case XJSONMap:
+		entries := make([]XJSONMapEntry, 0, len(value_))
+		for key, element := range value_ {
+			entries = append(entries, XJSONMapEntry{key, element})
+		}
+
+		sort.Slice(entries, func(i int, j int) bool {
+			return Compare(unpackXjsonKey(entries[i].Key), unpackXjsonKey(entries[j].Key)) < 0
+		})
+
+		for index, entry := range entries {
+			entries[index] = XJSONMapEntry{sortXJSONMaps(entry.Key), sortXJSONMaps(entry.Value)}
+		}
+
+		return StringMap{XJSONMapCode: entries}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = sortXJSONMaps(element)
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = sortXJSONMaps(element)
+		}
+	}
+
+	return value
+}
+
+//
+// sortedJSONMap
+//
+
+// A JSON object with its entries in order
+type sortedJSONMap []KeyValue
+
+// Keys are sorted according to [Compare] and converted via [MapKeyToString].
+// Distinct keys could be converted to the same string, in which case only the
+// first of their entries is kept.
+func newSortedJSONMap(entries []KeyValue) sortedJSONMap {
+	sort.Slice(entries, func(i int, j int) bool {
+		return Compare(yamlkeys.KeyData(entries[i].Key), yamlkeys.KeyData(entries[j].Key)) < 0
+	})
+
+	map_ := make(sortedJSONMap, 0, len(entries))
+	keys := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		key := MapKeyToString(entry.Key)
+		if _, ok := keys[key]; !ok {
+			keys[key] = struct{}{}
+			map_ = append(map_, KeyValue{key, sortJSONMaps(entry.Value)})
+		}
+	}
+
+	return map_
+}
+
+// ([json.Marshaler] interface)
+func (self sortedJSONMap) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for index, entry := range self {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := encodeJSON(entry.Key, &buffer, &JSONEncodeOptions{OmitTrailingNewline: true}); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(':')
+		if err := encodeJSON(entry.Value, &buffer, &JSONEncodeOptions{OmitTrailingNewline: true}); err != nil {
+			return nil, err
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
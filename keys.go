@@ -0,0 +1,148 @@
+package ard
+
+import (
+	"reflect"
+
+	"github.com/tliron/yamlkeys"
+)
+
+// Rules for map keys:
+//
+//   - Simple keys are hashable values, such as strings, numbers, booleans, and
+//     [time.Time]. They are used directly in Go maps.
+//   - Complex keys are [Map], [StringMap], [List], and []byte. Because they are
+//     not hashable they cannot be used directly in Go maps, so they are wrapped
+//     in a [yamlkeys.Key], specifically a [*yamlkeys.YAMLKey], which is also
+//     what the YAML decoder produces for complex keys.
+//   - Complex keys are compared by their unwrapped data via [Equals]. See
+//     [KeyEquals].
+//   - Complex keys are stringified as compact YAML. See [MapKeyToString].
+
+// Returns a key that can be used in a [Map].
+//
+// Simple keys are returned as is, as are existing [yamlkeys.Key] values.
+// Complex keys are wrapped in a [*yamlkeys.YAMLKey].
+//
+// Note that wrapped keys are compared by identity in Go maps, so indexing a
+// [Map] with a newly made complex key would not find an existing entry. Use
+// [KeyEquals] to compare keys, or [Node.Get], which supports complex keys.
+func MakeKey(value Value) (Value, error) {
+	if IsSimpleKey(value) {
+		return value, nil
+	} else {
+		return yamlkeys.NewYAMLKey(value)
+	}
+}
+
+// Like [MakeKey] but panics on error. Useful for literals, e.g. as generated
+// by [ToGoLiteral].
+func MustMakeKey(value Value) Value {
+	if key, err := MakeKey(value); err == nil {
+		return key
+	} else {
+		panic(err)
+	}
+}
+
+// Returns true if the keys are equal. Wrapped complex keys are unwrapped
+// and then compared via [Equals].
+func KeyEquals(a Value, b Value) bool {
+	return Equals(yamlkeys.KeyData(a), yamlkeys.KeyData(b))
+}
+
+// Returns true if the key is hashable and can thus be used directly in a Go
+// map. Note that [yamlkeys.Key] values are considered simple.
+//
+// See [CheckHashable].
+func IsSimpleKey(key Value) bool {
+	return CheckHashable(key) == nil
+}
+
+// Checks whether a value is hashable and can thus be used directly in a Go
+// map, including as a [Map] key, without causing a runtime panic. Returns nil
+// if it is.
+//
+// Unlike checking whether the value's type is comparable, this also checks
+// values nested in interfaces, e.g. a struct field of type any that contains
+// a [List]. The returned [*PathError] locates the first unhashable value
+// within the value, with struct fields as field path elements and array
+// elements as list path elements.
+//
+// Unhashable values can still be used as [Map] keys by wrapping them via
+// [MakeKey].
+func CheckHashable(value Value) error {
+	switch value.(type) {
+	case nil, string, bool, int, int64, uint64, float64, yamlkeys.Key:
+		// Fast path for common keys
+		return nil
+	}
+
+	return checkHashable(reflect.ValueOf(value), nil)
+}
+
+// Utils
+
+func checkHashable(value reflect.Value, path Path) error {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Func:
+		return newPathErrorf(path, "is not hashable: %s", value.Type().String())
+
+	case reflect.Interface:
+		if !value.IsNil() {
+			return checkHashable(value.Elem(), path)
+		}
+
+	case reflect.Array:
+		for index := 0; index < value.Len(); index++ {
+			if err := checkHashable(value.Index(index), path.AppendList(index)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		type_ := value.Type()
+		for index := 0; index < type_.NumField(); index++ {
+			if err := checkHashable(value.Field(index), path.AppendField(type_.Field(index).Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Finds an existing key in a map, supporting complex keys. Returns the actual
+// key used in the map.
+//
+// When compareKeys is true will compare all keys via [KeyEquals] if the key
+// is not found directly.
+func findMapKey(map_ Map, key Value, compareKeys bool) (Value, bool) {
+	_, isKey := key.(yamlkeys.Key)
+
+	if IsSimpleKey(key) {
+		if _, ok := map_[key]; ok {
+			return key, true
+		} else if !compareKeys && !isKey {
+			return nil, false
+		}
+	}
+
+	for key_ := range map_ {
+		if KeyEquals(key, key_) {
+			return key_, true
+		}
+	}
+
+	return nil, false
+}
+
+// Numbers are compared by value regardless of their Go type
+func findMapKeyNumerically(map_ Map, key Value) (Value, bool) {
+	for key_ := range map_ {
+		if equalsNumerically(key, key_) {
+			return key_, true
+		}
+	}
+
+	return nil, false
+}
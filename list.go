@@ -0,0 +1,53 @@
+package ard
+
+import (
+	"sort"
+)
+
+// Returns true if the list contains the value. Elements are compared
+// via [Equals].
+func ListContains(list List, value Value) bool {
+	return ListIndexOf(list, value) != -1
+}
+
+// Returns the index of the first element in the list that is equal to
+// the value, or -1 if not found. Elements are compared via [Equals].
+func ListIndexOf(list List, value Value) int {
+	for index, element := range list {
+		if Equals(element, value) {
+			return index
+		}
+	}
+	return -1
+}
+
+// Returns a new list without duplicate elements, keeping the first
+// occurrence of each. Elements are compared via [Equals].
+func ListUnique(list List) List {
+	unique := make(List, 0, len(list))
+	simple := make(map[Value]struct{})
+
+	for _, element := range list {
+		if IsSimpleKey(element) {
+			// Fast path for hashable elements
+			if _, ok := simple[element]; ok {
+				continue
+			}
+			simple[element] = struct{}{}
+		} else if ListContains(unique, element) {
+			continue
+		}
+
+		unique = append(unique, element)
+	}
+
+	return unique
+}
+
+// Sorts the list in place with a deterministic order that works across
+// types. The sort is stable. See [Compare] for the order.
+func SortList(list List) {
+	sort.SliceStable(list, func(i int, j int) bool {
+		return Compare(list[i], list[j]) < 0
+	})
+}
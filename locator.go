@@ -1,6 +1,8 @@
 package ard
 
 import (
+	"fmt"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -12,6 +14,21 @@ type Locator interface {
 	Locate(path ...PathElement) (int, int, bool)
 }
 
+//
+// Position
+//
+
+// A location in the source code of a document.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// ([fmt.Stringer] interface)
+func (self Position) String() string {
+	return fmt.Sprintf("%d:%d", self.Line, self.Column)
+}
+
 //
 // YAMLLocator
 //
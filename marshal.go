@@ -0,0 +1,30 @@
+package ard
+
+// Decodes supported formats and packs the result into a Go value. Combines
+// [Decode] and [Reflector.Pack] using the format's default [Reflector] (see
+// [DefaultReflector]).
+//
+// The target must be a pointer.
+//
+// Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
+func Unmarshal(data []byte, target any, format string) error {
+	if value, _, err := Decode(data, format, false); err == nil {
+		return DefaultReflector(format).Pack(value, target)
+	} else {
+		return err
+	}
+}
+
+// Unpacks a Go value and encodes the result to supported formats. Combines
+// [Reflector.Unpack] and [Encode] using the format's default [Reflector] (see
+// [DefaultReflector]).
+//
+// Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
+func Marshal(value any, format string) ([]byte, error) {
+	reflector := DefaultReflector(format)
+	if value_, err := reflector.Unpack(value); err == nil {
+		return Encode(value_, format, reflector)
+	} else {
+		return nil, err
+	}
+}
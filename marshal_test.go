@@ -0,0 +1,37 @@
+package ard
+
+import (
+	"testing"
+)
+
+func TestMarshalYAMLDeterministic(t *testing.T) {
+	value := map[string]string{"d": "x", "c": "true", "b": "1", "a": "multi\nline"}
+
+	first, err := Marshal(value, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "a: |-\n    multi\n    line\nb: \"1\"\nc: \"true\"\nd: x\n"
+	if string(first) != expected {
+		t.Errorf("expected %q, got %q", expected, first)
+	}
+
+	for range 50 {
+		if next, err := Marshal(value, "yaml"); err != nil {
+			t.Fatal(err)
+		} else if string(next) != string(first) {
+			t.Fatalf("non-deterministic output: %q and %q", first, next)
+		}
+	}
+
+	var unmarshaled map[string]string
+	if err := Unmarshal(first, &unmarshaled, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range value {
+		if unmarshaled[key] != v {
+			t.Errorf("%s: expected %#v, got %#v", key, v, unmarshaled[key])
+		}
+	}
+}
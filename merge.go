@@ -1,5 +1,11 @@
 package ard
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Deep merge of source value into target value. [Map] and [StringMap]
 // are merged key by key, recursively.
 //
@@ -16,48 +22,335 @@ package ard
 //
 // target = Merge(target, source, true)
 func Merge(target Value, source Value, appendLists bool) Value {
+	target, _ = merge(target, source, appendLists, false, nil, nil)
+	return target
+}
+
+// Like [Merge] but with a [Guard] for very large values. The guard applies
+// to the values copied from the source.
+//
+// Will return an error if the guard's limits are exceeded or if its
+// progress function aborts. In that case the target may have been
+// partially merged.
+func MergeWithGuard(target Value, source Value, appendLists bool, guard *Guard) (Value, error) {
+	return merge(target, source, appendLists, false, guard.newState(), nil)
+}
+
+//
+// MergeOptions
+//
+
+type MergeOptions struct {
+	// See [Merge]
+	AppendLists bool
+
+	// See [MergeWithGuard]
+	Guard *Guard
+
+	// When true a nil value in a source map deletes the key from the target,
+	// as in JSON Merge Patch (RFC 7386), and nil values in maps copied from
+	// the source are omitted. Otherwise a nil value overrides the target value
+	// like any other value, such that the key is present with a nil value.
+	// See also [Node.Presence].
+	NilDeletes bool
+}
+
+// Like [Merge] but also returns a [MergeReport] of all the changes made to
+// the target, which can be useful for logging the effective overrides when
+// layering configurations.
+//
+// The options argument can be nil, in which case defaults will be used.
+//
+// Will return an error only if the guard's limits are exceeded or if its
+// progress function aborts. In that case the target may have been partially
+// merged.
+func MergeWithReport(target Value, source Value, options *MergeOptions) (Value, MergeReport, error) {
+	if options == nil {
+		options = new(MergeOptions)
+	}
+
+	var report MergeReport
+	if target, err := merge(target, source, options.AppendLists, options.NilDeletes, options.Guard.newState(), &mergeReporter{report: &report}); err == nil {
+		return target, report, nil
+	} else {
+		return nil, report, err
+	}
+}
+
+// When guard is nil will never return an error.
+//
+// When report is not nil will record changes.
+func merge(target Value, source Value, appendLists bool, nilDeletes bool, guard *guardState, report *mergeReporter) (Value, error) {
 	if targetMap, ok := target.(Map); ok {
 		if sourceMap, ok := source.(Map); ok {
+			if err := guard.push(); err != nil {
+				return nil, err
+			}
+			defer guard.pop()
+
 			for key, sourceValue := range sourceMap {
-				if targetValue, ok := targetMap[key]; ok {
+				var err error
+				if nilDeletes && (sourceValue == nil) {
+					if targetValue, ok := targetMap[key]; ok {
+						delete(targetMap, key)
+						report.deleted(key, targetValue)
+					}
+				} else if targetValue, ok := targetMap[key]; ok {
 					// Target key already exists, so merge
-					targetMap[key] = Merge(targetValue, sourceValue, appendLists)
+					// On error the target value is kept as is
+					report.push(key)
+					merged, err := merge(targetValue, sourceValue, appendLists, nilDeletes, guard, report)
+					report.pop()
+					if err != nil {
+						return nil, err
+					}
+					targetMap[key] = merged
 				} else {
 					// Target key doesn't exist, so copy
-					targetMap[Copy(key)] = Copy(sourceValue)
+					if sourceValue, err = copy_(sourceValue, nil, noConversion, guard, nil); err == nil {
+						if nilDeletes {
+							sourceValue = removeNilMapEntries(sourceValue)
+						}
+						targetMap[Copy(key)] = sourceValue
+						report.added(key, sourceValue)
+					} else {
+						return nil, err
+					}
 				}
 			}
 
-			return targetMap
+			return targetMap, nil
 		}
 	}
 
 	if targetMap, ok := target.(StringMap); ok {
 		if sourceMap, ok := source.(StringMap); ok {
+			if err := guard.push(); err != nil {
+				return nil, err
+			}
+			defer guard.pop()
+
 			for key, sourceValue := range sourceMap {
-				if targetValue, ok := targetMap[key]; ok {
+				var err error
+				if nilDeletes && (sourceValue == nil) {
+					if targetValue, ok := targetMap[key]; ok {
+						delete(targetMap, key)
+						report.deleted(key, targetValue)
+					}
+				} else if targetValue, ok := targetMap[key]; ok {
 					// Target key already exists, so merge
-					targetMap[key] = Merge(targetValue, sourceValue, appendLists)
+					// On error the target value is kept as is
+					report.push(key)
+					merged, err := merge(targetValue, sourceValue, appendLists, nilDeletes, guard, report)
+					report.pop()
+					if err != nil {
+						return nil, err
+					}
+					targetMap[key] = merged
 				} else {
 					// Target key doesn't exist, so copy
-					targetMap[key] = Copy(sourceValue)
+					if sourceValue, err = copy_(sourceValue, nil, noConversion, guard, nil); err == nil {
+						if nilDeletes {
+							sourceValue = removeNilMapEntries(sourceValue)
+						}
+						targetMap[key] = sourceValue
+						report.added(key, sourceValue)
+					} else {
+						return nil, err
+					}
 				}
 			}
 
-			return targetMap
+			return targetMap, nil
 		}
 	}
 
 	if appendLists {
 		if targetList, ok := target.(List); ok {
 			if sourceList, ok := source.(List); ok {
+				if err := guard.push(); err != nil {
+					return nil, err
+				}
+				defer guard.pop()
+
 				for _, sourceValue := range sourceList {
-					targetList = append(targetList, Copy(sourceValue))
+					if sourceValue_, err := copy_(sourceValue, nil, noConversion, guard, nil); err == nil {
+						report.appended(len(targetList), sourceValue_)
+						targetList = append(targetList, sourceValue_)
+					} else {
+						return nil, err
+					}
 				}
-				return targetList
+				return targetList, nil
+			}
+		}
+	}
+
+	if source_, err := copy_(source, nil, noConversion, guard, nil); err == nil {
+		if nilDeletes {
+			source_ = removeNilMapEntries(source_)
+		}
+		report.overridden(target, source_)
+		return source_, nil
+	} else {
+		return nil, err
+	}
+}
+
+//
+// MergeChangeType
+//
+
+type MergeChangeType int
+
+const (
+	// Key did not exist in the target
+	MergeAdded MergeChangeType = 0
+
+	// Value in the target was replaced
+	MergeOverridden MergeChangeType = 1
+
+	// Element was appended to a list in the target
+	MergeAppended MergeChangeType = 2
+
+	// Key was deleted from the target (see [MergeOptions].NilDeletes)
+	MergeDeleted MergeChangeType = 3
+)
+
+// ([fmt.Stringer] interface)
+func (self MergeChangeType) String() string {
+	switch self {
+	case MergeAdded:
+		return "added"
+	case MergeOverridden:
+		return "overridden"
+	case MergeAppended:
+		return "appended"
+	case MergeDeleted:
+		return "deleted"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// MergeChange
+//
+
+type MergeChange struct {
+	Type MergeChangeType
+	Path Path
+	Old  Value // only for MergeOverridden and MergeDeleted
+	New  Value
+}
+
+// ([fmt.Stringer] interface)
+func (self *MergeChange) String() string {
+	return fmt.Sprintf("%s %s", self.Type, self.Path)
+}
+
+//
+// MergeReport
+//
+
+type MergeReport []*MergeChange
+
+// ([fmt.Stringer] interface)
+func (self MergeReport) String() string {
+	var builder strings.Builder
+	for index, change := range self {
+		if index > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(change.String())
+	}
+	return builder.String()
+}
+
+// Filters the report by change type.
+func (self MergeReport) Filter(type_ MergeChangeType) MergeReport {
+	var report MergeReport
+	for _, change := range self {
+		if change.Type == type_ {
+			report = append(report, change)
+		}
+	}
+	return report
+}
+
+//
+// mergeReporter
+//
+
+// Methods are nil-safe
+type mergeReporter struct {
+	path   Path
+	report *MergeReport
+}
+
+func (self *mergeReporter) push(key Value) {
+	if self != nil {
+		self.path = self.path.AppendKey(key)
+	}
+}
+
+func (self *mergeReporter) pop() {
+	if self != nil {
+		self.path = self.path[:len(self.path)-1]
+	}
+}
+
+func (self *mergeReporter) added(key Value, value Value) {
+	if self != nil {
+		self.record(MergeAdded, self.path.AppendKey(key), nil, value)
+	}
+}
+
+func (self *mergeReporter) appended(index int, value Value) {
+	if self != nil {
+		self.record(MergeAppended, self.path.AppendList(index), nil, value)
+	}
+}
+
+func (self *mergeReporter) deleted(key Value, old Value) {
+	if self != nil {
+		self.record(MergeDeleted, self.path.AppendKey(key), old, nil)
+	}
+}
+
+func (self *mergeReporter) overridden(old Value, new Value) {
+	if self != nil {
+		self.record(MergeOverridden, self.path, old, new)
+	}
+}
+
+func (self *mergeReporter) record(type_ MergeChangeType, path Path, old Value, new Value) {
+	*self.report = append(*self.report, &MergeChange{type_, path, old, new})
+}
+
+// Utils
+
+// Recurses into maps but not into lists, as in JSON Merge Patch. In place.
+func removeNilMapEntries(value Value) Value {
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			if element == nil {
+				delete(value_, key)
+			} else {
+				value_[key] = removeNilMapEntries(element)
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			if element == nil {
+				delete(value_, key)
+			} else {
+				value_[key] = removeNilMapEntries(element)
 			}
 		}
 	}
 
-	return Copy(source)
+	return value
 }
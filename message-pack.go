@@ -2,9 +2,15 @@ package ard
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
 // MessagePack decoder that supports "json" field tags.
@@ -23,6 +29,17 @@ func NewMessagePackEncoder(writer io.Writer) *msgpack.Encoder {
 
 // Marshals MessagePack with support for "json" field tags.
 func MarshalMessagePack(value any) ([]byte, error) {
+	if metrics := getMetrics(); metrics != nil {
+		start := now()
+		bytes_, err := marshalMessagePack(value)
+		metrics.Encoded("messagepack", int64(len(bytes_)), since(start), err)
+		return bytes_, err
+	} else {
+		return marshalMessagePack(value)
+	}
+}
+
+func marshalMessagePack(value any) ([]byte, error) {
 	var bytes_ bytes.Buffer
 	encoder := NewMessagePackEncoder(&bytes_)
 	if err := encoder.Encode(value); err == nil {
@@ -37,3 +54,237 @@ func UnmarshalMessagePack(data []byte, value any) error {
 	decoder := NewMessagePackDecoder(bytes.NewReader(data))
 	return decoder.Decode(value)
 }
+
+//
+// MessagePackIntegerMode
+//
+
+type MessagePackIntegerMode int
+
+const (
+	// Integers are decoded into the Go type matching their wire type, i.e.
+	// int8, int16, int32, int64, uint8, uint16, uint32, or uint64. Note that
+	// MessagePack's positive fixnums are decoded as int8.
+	MessagePackIntegersAsWire MessagePackIntegerMode = 0
+
+	// Signed integers are decoded as int64 and unsigned integers as uint64.
+	MessagePackIntegersWidened MessagePackIntegerMode = 1
+
+	// All integers are decoded as int64, except for unsigned integers that
+	// do not fit, which are decoded as uint64.
+	MessagePackIntegersSigned MessagePackIntegerMode = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self MessagePackIntegerMode) String() string {
+	switch self {
+	case MessagePackIntegersAsWire:
+		return "wire"
+	case MessagePackIntegersWidened:
+		return "widened"
+	case MessagePackIntegersSigned:
+		return "signed"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// MessagePackDecodeOptions
+//
+
+type MessagePackDecodeOptions struct {
+	// When true returns maps as [StringMap], otherwise they will be [Map].
+	// Non-string keys are converted using [MapKeyToString].
+	UseStringMaps bool
+
+	// How to map integer wire types to Go types.
+	Integers MessagePackIntegerMode
+
+	// Called for extension types other than the timestamp extension (-1),
+	// which is always decoded as [time.Time] (in UTC). If not set, extensions
+	// registered via msgpack.RegisterExt are decoded as registered, and other
+	// extensions will result in a decoding error.
+	Extension func(type_ int8, data []byte) (Value, error)
+
+	// When true repeated map keys will share memory. See [InternKeys].
+	InternKeys bool
+
+	// MessagePack does not validate that strings are UTF-8. See [CheckUTF8].
+	UTF8 UTF8Mode
+}
+
+//
+// MessagePackEncodeOptions
+//
+
+type MessagePackEncodeOptions struct {
+	// When true the output is encoded to Base64, for embedding in text
+	// mediums, e.g. environment variables or JSON strings. It can be read via
+	// the base64 argument of [ReadMessagePack].
+	Base64 bool
+}
+
+// Utils
+
+// We walk the structure ourselves (rather than decoding into an any) in
+// order to support non-string map keys and unregistered extensions
+// The interner can be nil
+func decodeMessagePack(decoder *msgpack.Decoder, options *MessagePackDecodeOptions, interner keyInterner) (Value, error) {
+	code, err := decoder.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedMap(code) || (code == msgpcode.Map16) || (code == msgpcode.Map32):
+		length, err := decoder.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+
+		if options.UseStringMaps {
+			map_ := make(StringMap, length)
+			for index := 0; index < length; index++ {
+				if key, err := decodeMessagePack(decoder, options, interner); err == nil {
+					if map_[interner.intern(MapKeyToString(key))], err = decodeMessagePack(decoder, options, interner); err != nil {
+						return nil, err
+					}
+				} else {
+					return nil, err
+				}
+			}
+			return map_, nil
+		} else {
+			map_ := make(Map, length)
+			for index := 0; index < length; index++ {
+				if key, err := decodeMessagePack(decoder, options, interner); err == nil {
+					if key, err = MakeKey(interner.internKey(key)); err != nil {
+						return nil, err
+					}
+					if map_[key], err = decodeMessagePack(decoder, options, interner); err != nil {
+						return nil, err
+					}
+				} else {
+					return nil, err
+				}
+			}
+			return map_, nil
+		}
+
+	case msgpcode.IsFixedArray(code) || (code == msgpcode.Array16) || (code == msgpcode.Array32):
+		length, err := decoder.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+
+		list := make(List, length)
+		for index := range list {
+			if list[index], err = decodeMessagePack(decoder, options, interner); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+
+	case msgpcode.IsFixedExt(code) || msgpcode.IsExt(code):
+		// We need the raw bytes in case the extension was registered
+		raw, err := decoder.DecodeRaw()
+		if err != nil {
+			return nil, err
+		}
+
+		rawDecoder := NewMessagePackDecoder(bytes.NewReader(raw))
+		type_, length, err := rawDecoder.DecodeExtHeader()
+		if err != nil {
+			return nil, err
+		}
+
+		if (type_ == -1) || (options.Extension != nil) {
+			data := make([]byte, length)
+			if err := rawDecoder.ReadFull(data); err != nil {
+				return nil, err
+			}
+
+			if type_ == -1 {
+				return decodeMessagePackTimestamp(data)
+			} else {
+				return options.Extension(type_, data)
+			}
+		}
+
+		// Extensions registered via msgpack.RegisterExt
+		if value, err := NewMessagePackDecoder(bytes.NewReader(raw)).DecodeInterface(); err == nil {
+			return value, nil
+		} else {
+			return nil, fmt.Errorf("unsupported MessagePack extension type %d: %w", type_, err)
+		}
+
+	default:
+		// Scalars, including bin as []byte
+		if value, err := decoder.DecodeInterface(); err == nil {
+			return convertMessagePackInteger(value, options.Integers), nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// See: https://github.com/msgpack/msgpack/blob/master/spec.md#timestamp-extension-type
+func decodeMessagePackTimestamp(data []byte) (time.Time, error) {
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+
+	case 8:
+		value := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(value&0x3ffffffff), int64(value>>34)).UTC(), nil
+
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+
+	default:
+		return time.Time{}, fmt.Errorf("malformed MessagePack timestamp length: %d", len(data))
+	}
+}
+
+func convertMessagePackInteger(value Value, mode MessagePackIntegerMode) Value {
+	switch mode {
+	case MessagePackIntegersWidened:
+		switch value_ := value.(type) {
+		case int8:
+			return int64(value_)
+		case int16:
+			return int64(value_)
+		case int32:
+			return int64(value_)
+		case uint8:
+			return uint64(value_)
+		case uint16:
+			return uint64(value_)
+		case uint32:
+			return uint64(value_)
+		}
+
+	case MessagePackIntegersSigned:
+		switch value_ := value.(type) {
+		case int8:
+			return int64(value_)
+		case int16:
+			return int64(value_)
+		case int32:
+			return int64(value_)
+		case uint8:
+			return int64(value_)
+		case uint16:
+			return int64(value_)
+		case uint32:
+			return int64(value_)
+		case uint64:
+			if value_ <= math.MaxInt64 {
+				return int64(value_)
+			}
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,87 @@
+package ard
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Metrics
+//
+
+// Instrumentation hooks for ARD operations. Implementations can forward these
+// to a metrics system, e.g. Prometheus counters and histograms.
+//
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Called after decoding a document via [Read] or [Decode]. Size is the number
+	// of bytes consumed from the reader.
+	Decoded(format string, size int64, duration time.Duration, err error)
+
+	// Called after encoding a value via [Write], [Encode], or [MarshalMessagePack].
+	// Size is the number of bytes produced.
+	Encoded(format string, size int64, duration time.Duration, err error)
+
+	// Called after [Reflector.Pack].
+	Packed(duration time.Duration, err error)
+
+	// Called after [Reflector.Unpack] and [Reflector.UnpackStringMaps].
+	Unpacked(duration time.Duration, err error)
+}
+
+var globalMetrics atomic.Pointer[Metrics]
+
+// Sets the package-wide [Metrics].
+//
+// Can be nil, which disables metrics (the default).
+//
+// This function is safe to call concurrently.
+func SetMetrics(metrics Metrics) {
+	if metrics != nil {
+		globalMetrics.Store(&metrics)
+	} else {
+		globalMetrics.Store(nil)
+	}
+}
+
+// Returns nil if not set
+func getMetrics() Metrics {
+	if metrics := globalMetrics.Load(); metrics != nil {
+		return *metrics
+	} else {
+		return nil
+	}
+}
+
+//
+// countingReader
+//
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+// ([io.Reader] interface)
+func (self *countingReader) Read(p []byte) (int, error) {
+	n, err := self.reader.Read(p)
+	self.count += int64(n)
+	return n, err
+}
+
+//
+// countingWriter
+//
+
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+// ([io.Writer] interface)
+func (self *countingWriter) Write(p []byte) (int, error) {
+	n, err := self.writer.Write(p)
+	self.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,235 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// RenameRule
+//
+
+// Moves a value from a deprecated path to its new path. See [MigrateKeys].
+//
+// Paths are in [Path.String] format, in which "*" can be used as a wildcard
+// for a whole path element, e.g. "services.*.cmd" or "servers[*].addr". From
+// and To must have the same wildcards in the same order, and the elements
+// matched by From's wildcards are used for To's wildcards, e.g.
+// "services.*.cmd" to "services.*.command".
+//
+// The final elements of both paths must be map keys (not list indexes).
+type RenameRule struct {
+	From string
+	To   string
+}
+
+//
+// RenameRules
+//
+
+// Rules are applied in order.
+type RenameRules []RenameRule
+
+// Applies the rules to the value, in place when possible, so that
+// applications can accept deprecated keys. Returns the migrated value.
+//
+// Every migration emits a "deprecated key" warning with "path" and
+// "replacement" keys via the package-wide [WarnFunc] (see [SetWarnFunc]). Use
+// [RenameRules.Migrate] to provide a different [WarnFunc].
+//
+// If a value already exists at the new path then it is kept, the deprecated
+// key is deleted, and a "deprecated key conflicts with replacement" warning
+// is emitted instead.
+//
+// Maps created along the new path will be of the same type as their
+// containing map, either [Map] or [StringMap].
+func MigrateKeys(value Value, rules RenameRules) (Value, error) {
+	return rules.Migrate(value, getWarnFunc())
+}
+
+// See [MigrateKeys]. The warn argument can be nil.
+func (self RenameRules) Migrate(value Value, warn WarnFunc) (Value, error) {
+	for _, rule := range self {
+		from, err := parseSchemaPattern(rule.From)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := parseSchemaPattern(rule.To)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := validateRenameRule(from, to); err != nil {
+			return nil, fmt.Errorf("malformed rename rule %q to %q: %w", rule.From, rule.To, err)
+		}
+
+		for _, match := range matchPathPattern(value, from, nil, nil) {
+			node := With(value).getPath(match.path)
+			if node == NoNode {
+				// Moved by a previous match
+				continue
+			}
+
+			target := fillPathPattern(to, match.captures)
+
+			if existing := With(value).getPath(target); existing != NoNode {
+				if warn != nil {
+					warn("deprecated key conflicts with replacement", "path", match.path.String(), "replacement", target.String())
+				}
+			} else if !setAtPath(value, target, node.Value) {
+				return nil, fmt.Errorf("%s: cannot set replacement %s", match.path.String(), target.String())
+			} else if warn != nil {
+				warn("deprecated key", "path", match.path.String(), "replacement", target.String())
+			}
+
+			node.Delete()
+		}
+	}
+
+	return value, nil
+}
+
+// Utils
+
+func isPathWildcard(element PathElement) bool {
+	switch element.Type {
+	case FieldPathType:
+		return element.Value == "*"
+	case ListPathType:
+		return element.Value == -1
+	default:
+		return false
+	}
+}
+
+func validateRenameRule(from Path, to Path) error {
+	if (len(from) == 0) || (len(to) == 0) {
+		return errors.New("empty path")
+	}
+
+	for _, path := range []Path{from, to} {
+		last := path[len(path)-1]
+		if isPathWildcard(last) || (last.Type == ListPathType) || (last.Type == SequencedListPathType) {
+			return errors.New("final element is not a map key")
+		}
+	}
+
+	var fromWildcards, toWildcards []PathElementType
+	for _, element := range from {
+		if isPathWildcard(element) {
+			fromWildcards = append(fromWildcards, element.Type)
+		}
+	}
+	for _, element := range to {
+		if isPathWildcard(element) {
+			toWildcards = append(toWildcards, element.Type)
+		}
+	}
+
+	if len(fromWildcards) != len(toWildcards) {
+		return errors.New("wildcards do not match")
+	}
+	for index, type_ := range fromWildcards {
+		if toWildcards[index] != type_ {
+			return errors.New("wildcards do not match")
+		}
+	}
+
+	return nil
+}
+
+type pathPatternMatch struct {
+	path     Path
+	captures []PathElement
+}
+
+func matchPathPattern(value Value, pattern Path, path Path, captures []PathElement) []pathPatternMatch {
+	if len(pattern) == 0 {
+		return []pathPatternMatch{{path, captures}}
+	}
+
+	element := pattern[0]
+	var matches []pathPatternMatch
+
+	if isPathWildcard(element) {
+		switch value_ := value.(type) {
+		case Map:
+			if element.Type == FieldPathType {
+				for key, value__ := range value_ {
+					element_ := NewKeyPathElement(key)
+					matches = append(matches, matchPathPattern(value__, pattern[1:], path.Append(element_), append(captures[:len(captures):len(captures)], element_))...)
+				}
+			}
+
+		case StringMap:
+			if element.Type == FieldPathType {
+				for key, value__ := range value_ {
+					element_ := NewFieldPathElement(key)
+					matches = append(matches, matchPathPattern(value__, pattern[1:], path.Append(element_), append(captures[:len(captures):len(captures)], element_))...)
+				}
+			}
+
+		case List:
+			if element.Type == ListPathType {
+				for index, value__ := range value_ {
+					element_ := NewListPathElement(index)
+					matches = append(matches, matchPathPattern(value__, pattern[1:], path.Append(element_), append(captures[:len(captures):len(captures)], element_))...)
+				}
+			}
+		}
+	} else if node := With(value).getPath(Path{element}); node != NoNode {
+		matches = matchPathPattern(node.Value, pattern[1:], path.Append(element), captures)
+	}
+
+	return matches
+}
+
+func fillPathPattern(pattern Path, captures []PathElement) Path {
+	path := make(Path, len(pattern))
+	for index, element := range pattern {
+		if isPathWildcard(element) {
+			path[index] = captures[0]
+			captures = captures[1:]
+		} else {
+			path[index] = element
+		}
+	}
+	return path
+}
+
+// The final element must be a map key. Maps are created along the way.
+func setAtPath(value Value, path Path, newValue Value) bool {
+	current := With(value)
+	last := len(path) - 1
+
+	for _, element := range path[:last] {
+		if next := current.getPath(Path{element}); next != NoNode {
+			current = next
+			continue
+		}
+
+		// Create a new map (same type as current)
+		var childMap Value
+		switch current.Value.(type) {
+		case Map:
+			childMap = make(Map)
+		case StringMap:
+			childMap = make(StringMap)
+		default:
+			return false
+		}
+
+		if err := putInMap(current.Value, element.Value, childMap, nil); err != nil {
+			return false
+		}
+		current = current.child(childMap, element.Value)
+	}
+
+	switch current.Value.(type) {
+	case Map, StringMap:
+		return putInMap(current.Value, path[last].Value, newValue, nil) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package ard
+
+import (
+	"os"
+)
+
+// Utils
+
+// Memory mapping is not supported, so the file is read into memory instead
+func mapFile(path string) ([]byte, func() error, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		return data, func() error { return nil }, nil
+	} else {
+		return nil, nil, err
+	}
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package ard
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Utils
+
+// The returned unmap function must be called when done with the data
+func mapFile(path string) ([]byte, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		// Cannot map an empty file
+		return nil, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("file too large to map: %s", path)
+	}
+
+	if data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED); err == nil {
+		return data, func() error {
+			return syscall.Munmap(data)
+		}, nil
+	} else {
+		return nil, nil, fmt.Errorf("cannot map file %s: %w", path, err)
+	}
+}
@@ -0,0 +1,155 @@
+package ard
+
+import (
+	"strconv"
+)
+
+//
+// Precedence
+//
+
+// See [MultiNode].
+type Precedence int
+
+const (
+	// The value is that of the first node in which it is found
+	FirstFoundPrecedence Precedence = 0
+
+	// Like [FirstFoundPrecedence], except that if the first value found is a
+	// [Map] or [StringMap] then it is deep merged with all the values found in
+	// the following nodes via [Merge] (with lists overridden), such that
+	// earlier nodes override later ones
+	MergedPrecedence Precedence = 1
+)
+
+// ([fmt.Stringer] interface)
+func (self Precedence) String() string {
+	switch self {
+	case FirstFoundPrecedence:
+		return "first-found"
+	case MergedPrecedence:
+		return "merged"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// MultiNode
+//
+
+// A group of [Node] for layered lookups across several documents, e.g.
+// overrides, then environment-specific values, then defaults, without having
+// to merge the documents first.
+//
+// The idiomatic way to get a layered value is like so:
+//
+// if s, ok := ard.WithAll(ard.FirstFoundPrecedence, overrides, defaults).Get("key1", "key2").Node().String(); ok {
+// ...
+// }
+type MultiNode struct {
+	// In order of precedence, highest first. Nodes that were not found are
+	// [NoNode].
+	Nodes []*Node
+
+	Precedence Precedence
+}
+
+// Creates a [MultiNode] for root values in order of precedence, highest first.
+func WithAll(precedence Precedence, data ...any) *MultiNode {
+	nodes := make([]*Node, len(data))
+	for index, data_ := range data {
+		nodes[index] = With(data_)
+	}
+	return &MultiNode{nodes, precedence}
+}
+
+// Calls [Node.Get] on all nodes.
+func (self *MultiNode) Get(keys ...Value) *MultiNode {
+	return self.each(func(node *Node) *Node {
+		return node.Get(keys...)
+	})
+}
+
+// Calls [Node.GetPath] on all nodes.
+func (self *MultiNode) GetPath(path string, separator string) *MultiNode {
+	return self.Get(PathToKeys(path, separator)...)
+}
+
+// Calls [Node.GetDotted] on all nodes.
+func (self *MultiNode) GetDotted(path string) *MultiNode {
+	if path_, err := ParsePath(path); err == nil {
+		return self.each(func(node *Node) *Node {
+			return node.getPath(path_)
+		})
+	} else {
+		return self.each(func(node *Node) *Node {
+			return NoNode
+		})
+	}
+}
+
+// Returns true if the value was found in any of the nodes.
+func (self *MultiNode) Exists() bool {
+	for _, node := range self.Nodes {
+		if node != NoNode {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the node with the value according to the precedence. Returns
+// [NoNode] if the value was not found in any of the nodes.
+//
+// For [FirstFoundPrecedence], and for [MergedPrecedence] when there is nothing
+// to merge, the returned node is the one found, and thus can be used to modify
+// the document it is in, e.g. via [Node.Set]. Otherwise it is a new node for
+// a merged copy, which can be freely modified but is not contained in any
+// document.
+func (self *MultiNode) Node() *Node {
+	var found []*Node
+	for _, node := range self.Nodes {
+		if node != NoNode {
+			found = append(found, node)
+		}
+	}
+
+	if len(found) == 0 {
+		return NoNode
+	}
+
+	first := found[0]
+	if (self.Precedence != MergedPrecedence) || (len(found) == 1) {
+		return first
+	}
+
+	switch first.Value.(type) {
+	case Map, StringMap:
+	default:
+		return first
+	}
+
+	// Merge from lowest precedence to highest
+	last := len(found) - 1
+	merged := Copy(found[last].Value)
+	for index := last - 1; index >= 0; index-- {
+		merged = Merge(merged, found[index].Value, false)
+	}
+
+	node := *first
+	node.Value = merged
+	node.container = nil
+	node.key = ""
+	return &node
+}
+
+// Utils
+
+func (self *MultiNode) each(f func(node *Node) *Node) *MultiNode {
+	nodes := make([]*Node, len(self.Nodes))
+	for index, node := range self.Nodes {
+		nodes[index] = f(node)
+	}
+	return &MultiNode{nodes, self.Precedence}
+}
@@ -1,7 +1,9 @@
 package ard
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -20,17 +22,19 @@ type Node struct {
 	key            Value
 	nilMeansZero   bool
 	convertSimilar bool
+	bytesDecoding  BytesDecoding
+	compareKeys    bool
 }
 
 // Creates an extractable, convertible, traversable, and modifiable wrapper
 // (a [Node]) for an ARD [Value].
 func With(data any) *Node {
-	return &Node{data, nil, "", false, false}
+	return &Node{Value: data, key: ""}
 }
 
 // This singleton is returned from all node functions when
 // no node is found.
-var NoNode = &Node{nil, nil, "", false, false}
+var NoNode = &Node{key: ""}
 
 // Returns a copy of this node for which nil values are allowed and interpreted as
 // the zero value. For example, [Node.String] on nil would return an empty string.
@@ -39,7 +43,9 @@ func (self *Node) NilMeansZero() *Node {
 		return NoNode
 	}
 
-	return &Node{self.Value, self.container, self.key, true, self.convertSimilar}
+	node := *self
+	node.nilMeansZero = true
+	return &node
 }
 
 // Returns a copy of this node for which similarly-typed values are allowed and
@@ -50,7 +56,37 @@ func (self *Node) ConvertSimilar() *Node {
 		return NoNode
 	}
 
-	return &Node{self.Value, self.container, self.key, self.nilMeansZero, true}
+	node := *self
+	node.convertSimilar = true
+	return &node
+}
+
+// Returns a copy of this node that uses the specified string representations
+// when converting strings to bytes. See [Node.Bytes] and [DecodeBytes].
+func (self *Node) BytesDecoding(decoding BytesDecoding) *Node {
+	if self == NoNode {
+		return NoNode
+	}
+
+	node := *self
+	node.bytesDecoding = decoding
+	return &node
+}
+
+// Returns a copy of this node for which [Map] keys that are not found directly
+// are looked up by comparing them to all existing keys via [KeyEquals]. This is
+// slower, but allows for finding keys that are equal but not identical, e.g.
+// complex keys from different sources.
+//
+// Note that complex keys are always looked up this way. See [MakeKey].
+func (self *Node) CompareKeys() *Node {
+	if self == NoNode {
+		return NoNode
+	}
+
+	node := *self
+	node.compareKeys = true
+	return &node
 }
 
 // Returns (string, true) if the node is a string.
@@ -87,8 +123,10 @@ func (self *Node) String() (string, bool) {
 // Returns ([]byte, true) if the node is a []byte.
 //
 // If [Node.ConvertSimilar] was called and the node is a string
-// then will attempt to decode it as base64, with failures returning
-// (false, false).
+// then will attempt to decode it using [DecodeBytes], with failures
+// returning (nil, false). By default base64 and hex are supported.
+// Call [Node.BytesDecoding] to change the supported representations,
+// e.g. to also support raw UTF-8.
 //
 // By default will fail on nil values. Call [Node.NilMeansZero]
 // to interpret nil as an empty []byte.
@@ -109,7 +147,7 @@ func (self *Node) Bytes() ([]byte, bool) {
 	default:
 		if self.convertSimilar {
 			if string_, ok := self.Value.(string); ok {
-				if value_, err := util.FromBase64(string_); err == nil {
+				if value_, err := DecodeBytes(string_, self.bytesDecoding); err == nil {
 					return value_, true
 				}
 			}
@@ -134,6 +172,10 @@ func (self *Node) Integer() (int64, bool) {
 		return 0, false
 	}
 
+	if number, ok := self.Value.(json.Number); ok {
+		return self.withJSONNumber(number).Integer()
+	}
+
 	switch value := self.Value.(type) {
 	case int64:
 		return value, true
@@ -175,6 +217,10 @@ func (self *Node) UnsignedInteger() (uint64, bool) {
 		return 0, false
 	}
 
+	if number, ok := self.Value.(json.Number); ok {
+		return self.withJSONNumber(number).UnsignedInteger()
+	}
+
 	switch value := self.Value.(type) {
 	case uint64:
 		return value, true
@@ -201,6 +247,83 @@ func (self *Node) UnsignedInteger() (uint64, bool) {
 	return 0, false
 }
 
+// Returns (int, true) if the node is an int64, int32, int16, int8, or int
+// and its value is within the range of int. Values are never truncated:
+// values out of range fail.
+//
+// If [Node.ConvertSimilar] was called then will also convert unsigned
+// integers and whole floats, but only if their values are within range.
+//
+// By default will fail on nil values. Call [Node.NilMeansZero]
+// to interpret nil as 0.
+func (self *Node) Int() (int, bool) {
+	integer, ok := self.rangedInteger(math.MinInt, math.MaxInt)
+	return int(integer), ok
+}
+
+// Like [Node.Int] but for int8.
+func (self *Node) Int8() (int8, bool) {
+	integer, ok := self.rangedInteger(math.MinInt8, math.MaxInt8)
+	return int8(integer), ok
+}
+
+// Like [Node.Int] but for int16.
+func (self *Node) Int16() (int16, bool) {
+	integer, ok := self.rangedInteger(math.MinInt16, math.MaxInt16)
+	return int16(integer), ok
+}
+
+// Like [Node.Int] but for int32.
+func (self *Node) Int32() (int32, bool) {
+	integer, ok := self.rangedInteger(math.MinInt32, math.MaxInt32)
+	return int32(integer), ok
+}
+
+// Like [Node.Int] but for int64.
+func (self *Node) Int64() (int64, bool) {
+	integer, ok := self.rangedInteger(math.MinInt64, math.MaxInt64)
+	return int64(integer), ok
+}
+
+// Returns (uint, true) if the node is an uint64, uint32, uint16, uint8, or
+// uint and its value is within the range of uint. Values are never truncated:
+// values out of range fail.
+//
+// If [Node.ConvertSimilar] was called then will also convert non-negative
+// signed integers and whole floats, but only if their values are within
+// range.
+//
+// By default will fail on nil values. Call [Node.NilMeansZero]
+// to interpret nil as 0.
+func (self *Node) Uint() (uint, bool) {
+	uinteger, ok := self.rangedUnsignedInteger(math.MaxUint)
+	return uint(uinteger), ok
+}
+
+// Like [Node.Uint] but for uint8.
+func (self *Node) Uint8() (uint8, bool) {
+	uinteger, ok := self.rangedUnsignedInteger(math.MaxUint8)
+	return uint8(uinteger), ok
+}
+
+// Like [Node.Uint] but for uint16.
+func (self *Node) Uint16() (uint16, bool) {
+	uinteger, ok := self.rangedUnsignedInteger(math.MaxUint16)
+	return uint16(uinteger), ok
+}
+
+// Like [Node.Uint] but for uint32.
+func (self *Node) Uint32() (uint32, bool) {
+	uinteger, ok := self.rangedUnsignedInteger(math.MaxUint32)
+	return uint32(uinteger), ok
+}
+
+// Like [Node.Uint] but for uint64.
+func (self *Node) Uint64() (uint64, bool) {
+	uinteger, ok := self.rangedUnsignedInteger(math.MaxUint64)
+	return uint64(uinteger), ok
+}
+
 // Returns (float64, true) if the node is a float64 or a float32.
 //
 // If [Node.ConvertSimilar] was called then will convert all other number types
@@ -216,6 +339,10 @@ func (self *Node) Float() (float64, bool) {
 		return 0.0, false
 	}
 
+	if number, ok := self.Value.(json.Number); ok {
+		return self.withJSONNumber(number).Float()
+	}
+
 	switch value := self.Value.(type) {
 	case float64:
 		return value, true
@@ -236,6 +363,72 @@ func (self *Node) Float() (float64, bool) {
 	return 0.0, false
 }
 
+// Returns ([Number], true) if the node is any Go integer or float type, a
+// [json.Number], or a [*big.Int]. See [NewNumber].
+//
+// Unlike [Node.Integer], [Node.UnsignedInteger], and [Node.Float], values are
+// never converted with loss of precision: integers out of range of int64 and
+// uint64 are [BigIntegerNumber].
+//
+// If [Node.ConvertSimilar] was called then will also parse strings as
+// [json.Number] does, e.g. "123456789012345678901234567890".
+//
+// By default will fail on nil values. Call [Node.NilMeansZero]
+// to interpret nil as 0.
+func (self *Node) Number() (Number, bool) {
+	if self == NoNode {
+		return Number{}, false
+	}
+
+	switch value := self.Value.(type) {
+	case nil:
+		if self.nilMeansZero {
+			return Number{Kind: IntegerNumber}, true
+		}
+
+	case string:
+		if self.convertSimilar {
+			return parseNumber(value)
+		}
+
+	default:
+		return NewNumber(value)
+	}
+
+	return Number{}, false
+}
+
+// Returns ([Quantity], true) if the node is a string that can be parsed via
+// [ParseQuantity], or if it is a number (in which case it will be a
+// [ScalarQuantity]).
+//
+// By default will fail on nil values. Call [Node.NilMeansZero]
+// to interpret nil as a zero [ScalarQuantity].
+func (self *Node) Quantity() (Quantity, bool) {
+	if self == NoNode {
+		return Quantity{}, false
+	}
+
+	switch value := self.Value.(type) {
+	case string:
+		if quantity, err := ParseQuantity(value); err == nil {
+			return quantity, true
+		}
+
+	case nil:
+		if self.nilMeansZero {
+			return Quantity{}, true
+		}
+
+	default:
+		if float, ok := self.ConvertSimilar().Float(); ok {
+			return Quantity{Value: float}, true
+		}
+	}
+
+	return Quantity{}, false
+}
+
 // Returns (bool, true) if the node is a bool.
 //
 // If [Node.ConvertSimilar] was called then will call [Node.String]
@@ -446,6 +639,115 @@ func (self *Node) StringList() ([]string, bool) {
 	return nil, false
 }
 
+// Returns true if the node is not [NoNode] (or a nil pointer).
+//
+// Prefer this to comparing with [NoNode] directly.
+func (self *Node) Exists() bool {
+	return (self != nil) && (self != NoNode)
+}
+
+// Returns true if the node exists and its value is nil.
+//
+// Note that [Node.NilMeansZero] does not affect this function.
+func (self *Node) IsNil() bool {
+	return self.Exists() && (self.Value == nil)
+}
+
+// Distinguishes a key that is absent from a key that is present with a nil
+// value (JSON null), which JSON Merge Patch and many configuration systems
+// treat differently. See also [MergeOptions].NilDeletes and
+// [DiffOptions].NilMeansAbsent.
+//
+// Note that [Node.NilMeansZero] does not affect this function.
+func (self *Node) Presence() Presence {
+	if !self.Exists() {
+		return Absent
+	} else if self.Value == nil {
+		return PresentNil
+	} else {
+		return Present
+	}
+}
+
+// Returns true if the node exists and its value is nil, an empty
+// string, or an empty []byte, [Map], [StringMap], or [List].
+func (self *Node) IsEmpty() bool {
+	if !self.Exists() {
+		return false
+	}
+
+	switch value := self.Value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(value) == 0
+	case []byte:
+		return len(value) == 0
+	case Map:
+		return len(value) == 0
+	case StringMap:
+		return len(value) == 0
+	case List:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
+
+// Checks for deep equality between the values of two nodes via [Equals].
+//
+// Nodes that do not exist (see [Node.Exists]) are only equal to each other.
+func (self *Node) EqualsNode(other *Node) bool {
+	if exists := self.Exists(); exists != other.Exists() {
+		return false
+	} else if !exists {
+		return true
+	}
+
+	return Equals(self.Value, other.Value)
+}
+
+// Checks for deep equality between the value of this node and a value via
+// [Equals].
+//
+// Will return false if the node does not exist (see [Node.Exists]).
+func (self *Node) EqualsValue(value Value) bool {
+	return self.Exists() && Equals(self.Value, value)
+}
+
+// Returns (tag, true) if the node is a [TaggedValue]. See
+// [YAMLDecodeOptions].KeepTags.
+func (self *Node) Tag() (string, bool) {
+	if self == NoNode {
+		return "", false
+	}
+
+	if tagged, ok := self.Value.(*TaggedValue); ok {
+		return tagged.Tag, true
+	} else {
+		return "", false
+	}
+}
+
+// If the node is a [TaggedValue] returns a copy of this node with the
+// tagged value's value. Otherwise returns this node.
+//
+// The returned node has the same container, thus [Node.Set] on it would
+// replace the tagged value.
+func (self *Node) Untagged() *Node {
+	if self == NoNode {
+		return NoNode
+	}
+
+	if tagged, ok := self.Value.(*TaggedValue); ok {
+		node := *self
+		node.Value = tagged.Value
+		return &node
+	} else {
+		return self
+	}
+}
+
 // Sets the value of this node and its key in the containing map.
 //
 // Will fail and return false if there's no containing node or it's
@@ -458,7 +760,9 @@ func (self *Node) Set(value Value) bool {
 	if self.container != nil {
 		switch self.container.Value.(type) {
 		case Map, StringMap:
-			putInMap(self.container.Value, self.key, value)
+			if err := putInMap(self.container.Value, self.key, value, nil); err != nil {
+				return false
+			}
 			self.Value = value
 			return true
 		}
@@ -506,6 +810,71 @@ func (self *Node) Delete() bool {
 	return false
 }
 
+// Moves this node's value to a new key in the containing node's map, deleting
+// the old key.
+//
+// Will fail and return false if there's no containing node or it's not [Map]
+// or [StringMap], if the new key is already in use (renaming to the same key
+// succeeds and does nothing), or if the new key is not a valid key (see
+// [MakeKey]).
+func (self *Node) Rename(newKey Value) bool {
+	if self == NoNode {
+		return false
+	}
+
+	if self.container != nil {
+		switch self.container.Value.(type) {
+		case Map, StringMap:
+			if _, existingKey, ok, _ := getFromMap(self.container.Value, newKey, self.container.compareKeys); ok {
+				return KeyEquals(existingKey, self.key)
+			}
+
+			// Put before deleting so that the map is unchanged on failure
+			if err := putInMap(self.container.Value, newKey, self.Value, nil); err != nil {
+				return false
+			}
+			deleteFromMap(self.container.Value, self.key)
+			if _, newKey_, ok, _ := getFromMap(self.container.Value, newKey, false); ok {
+				self.key = newKey_
+			} else {
+				self.key = newKey
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a standalone copy of this node with a deep copy of its value
+// (via [Copy]) and the same configuration. The returned node has no
+// containing node, so changes to it will not affect the original.
+func (self *Node) Detach() *Node {
+	if self == NoNode {
+		return NoNode
+	}
+
+	node := *self
+	node.Value = Copy(self.Value)
+	node.container = nil
+	node.key = nil
+	return &node
+}
+
+// Inserts a deep copy of this node's value (via [Copy]) into the target node
+// at the keys, which are followed via [Node.ForceGet]. Can be used together
+// with [Node.Delete] to move subtrees.
+//
+// Will fail and return false if this node or the target is [NoNode], if no
+// keys are provided, or if [Node.ForceGet] or [Node.Set] fail.
+func (self *Node) Graft(target *Node, keys ...Value) bool {
+	if (self == NoNode) || (target == NoNode) {
+		return false
+	}
+
+	return target.ForceGet(keys...).Set(Copy(self.Value))
+}
+
 // Gets a nested node by recursively following keys. Thus all keys
 // except the final one refer to nodes that must be [Map] or [StringMap].
 // Returns [NoNode] if any of the keys is not found along the way.
@@ -516,7 +885,9 @@ func (self *Node) Delete() bool {
 // ...
 // }
 //
-// For [StringMap] keys are converted using [MapKeyToString].
+// For [StringMap] keys are converted using [MapKeyToString]. For [Map]
+// complex keys are supported, see [MakeKey]. Call [Node.CompareKeys] to
+// also support keys that are equal but not identical.
 func (self *Node) Get(keys ...Value) *Node {
 	return self.get(keys, false)
 }
@@ -553,6 +924,21 @@ func (self *Node) ForceGetPath(path string, separator string) *Node {
 	return self.ForceGet(PathToKeys(path, separator)...)
 }
 
+// Gets a nested node by following a path in [Path.String] format, e.g.
+// `servers[0].name`. Field names are separated by "." and backslash can be
+// used to escape special characters in them. List indexes are enclosed in
+// "[]" and can also be used for integer map keys.
+//
+// Returns [NoNode] if the path is malformed (see [ParsePath]) or if any of its
+// elements is not found along the way.
+func (self *Node) GetDotted(path string) *Node {
+	if path_, err := ParsePath(path); err == nil {
+		return self.getPath(path_)
+	} else {
+		return NoNode
+	}
+}
+
 // Convenience function to convert a string path to keys
 // usable for [Node.Get] and [Node.ForceGet].
 //
@@ -566,6 +952,38 @@ func PathToKeys(path string, separator string) []Value {
 	return keys_
 }
 
+//
+// Presence
+//
+
+// See [Node.Presence].
+type Presence int
+
+const (
+	// The key does not exist
+	Absent Presence = 0
+
+	// The key exists and its value is nil
+	PresentNil Presence = 1
+
+	// The key exists and its value is not nil
+	Present Presence = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self Presence) String() string {
+	switch self {
+	case Absent:
+		return "absent"
+	case PresentNil:
+		return "nil"
+	case Present:
+		return "present"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
 // Utils
 
 func (self *Node) get(keys []Value, force bool) *Node {
@@ -587,10 +1005,10 @@ func (self *Node) get(keys []Value, force bool) *Node {
 		if last > 0 {
 			for _, key := range keys[:last] {
 				// Try to use existing map
-				if map_, ok, isMap := getFromMap(current.Value, key); ok {
+				if map_, key_, ok, isMap := getFromMap(current.Value, key, current.compareKeys); ok {
 					if isMap {
 						// Key exists and is a map
-						current = &Node{map_, current, key, current.nilMeansZero, current.convertSimilar}
+						current = current.child(map_, key_)
 					} else {
 						// Key exists but is not a map
 						return NoNode
@@ -601,6 +1019,10 @@ func (self *Node) get(keys []Value, force bool) *Node {
 
 					switch currentMap := current.Value.(type) {
 					case Map:
+						var err error
+						if key, err = MakeKey(key); err != nil {
+							return NoNode
+						}
 						childMap = make(Map)
 						currentMap[key] = childMap
 
@@ -612,7 +1034,7 @@ func (self *Node) get(keys []Value, force bool) *Node {
 						panic(fmt.Sprintf("not a map: %T", current))
 					}
 
-					current = &Node{childMap, current, key, current.nilMeansZero, current.convertSimilar}
+					current = current.child(childMap, key)
 				} else {
 					return NoNode
 				}
@@ -621,46 +1043,213 @@ func (self *Node) get(keys []Value, force bool) *Node {
 
 		// Last key
 		lastKey := keys[last]
-		if value, ok, _ := getFromMap(current.Value, lastKey); ok {
-			return &Node{value, current, lastKey, current.nilMeansZero, current.convertSimilar}
+		if value, lastKey_, ok, _ := getFromMap(current.Value, lastKey, current.compareKeys); ok {
+			return current.child(value, lastKey_)
 		} else if force {
-			return &Node{nil, current, lastKey, current.nilMeansZero, current.convertSimilar}
+			if _, isMap := current.Value.(Map); isMap {
+				var err error
+				if lastKey, err = MakeKey(lastKey); err != nil {
+					return NoNode
+				}
+			}
+			return current.child(nil, lastKey)
 		}
 	}
 
 	return NoNode
 }
 
-// value, exists, isMap
-func getFromMap(value any, key Value) (any, bool, bool) {
+func (self *Node) rangedInteger(min int64, max int64) (int64, bool) {
+	if self == NoNode {
+		return 0, false
+	}
+
+	if number, ok := self.Value.(json.Number); ok {
+		return self.withJSONNumber(number).rangedInteger(min, max)
+	}
+
+	var integer int64
+	number := toNumber(self.Value)
+	switch self.Value.(type) {
+	case int64, int32, int16, int8, int:
+		integer = number.signed
+
+	case uint64, uint32, uint16, uint8, uint:
+		if !self.convertSimilar || (number.unsigned > math.MaxInt64) {
+			return 0, false
+		}
+		integer = int64(number.unsigned)
+
+	case float64, float32:
+		float := number.float
+		if !self.convertSimilar || (float != math.Trunc(float)) || (float < math.MinInt64) || (float >= math.MaxInt64) {
+			return 0, false
+		}
+		integer = int64(float)
+
+	case nil:
+		return 0, self.nilMeansZero
+
+	default:
+		return 0, false
+	}
+
+	if (integer >= min) && (integer <= max) {
+		return integer, true
+	} else {
+		return 0, false
+	}
+}
+
+func (self *Node) rangedUnsignedInteger(max uint64) (uint64, bool) {
+	if self == NoNode {
+		return 0, false
+	}
+
+	if number, ok := self.Value.(json.Number); ok {
+		return self.withJSONNumber(number).rangedUnsignedInteger(max)
+	}
+
+	var uinteger uint64
+	number := toNumber(self.Value)
+	switch self.Value.(type) {
+	case uint64, uint32, uint16, uint8, uint:
+		uinteger = number.unsigned
+
+	case int64, int32, int16, int8, int:
+		if !self.convertSimilar || (number.signed < 0) {
+			return 0, false
+		}
+		uinteger = uint64(number.signed)
+
+	case float64, float32:
+		float := number.float
+		if !self.convertSimilar || (float != math.Trunc(float)) || (float < 0) || (float >= math.MaxUint64) {
+			return 0, false
+		}
+		uinteger = uint64(float)
+
+	case nil:
+		return 0, self.nilMeansZero
+
+	default:
+		return 0, false
+	}
+
+	if uinteger <= max {
+		return uinteger, true
+	} else {
+		return 0, false
+	}
+}
+
+// Returns a copy of this node with the [json.Number] parsed via [ParseJSONNumber],
+// or [NoNode] if it's malformed
+func (self *Node) withJSONNumber(number json.Number) *Node {
+	if number_, err := ParseJSONNumber(number); err == nil {
+		node := *self
+		node.Value = number_
+		return &node
+	} else {
+		return NoNode
+	}
+}
+
+func (self *Node) getPath(path Path) *Node {
+	if (self == NoNode) || (len(path) == 0) {
+		return NoNode
+	}
+
+	current := self
+	for _, element := range path {
+		switch element.Type {
+		case ListPathType, SequencedListPathType:
+			if list, ok := current.Value.(List); ok {
+				index := element.Value.(int)
+				if (index >= 0) && (index < len(list)) {
+					current = current.child(list[index], index)
+					continue
+				} else {
+					return NoNode
+				}
+			} else if element.Type == SequencedListPathType {
+				return NoNode
+			}
+		}
+
+		// Integer list indexes are also used as map keys
+		if value, key, ok, _ := getFromMap(current.Value, element.Value, current.compareKeys); ok {
+			current = current.child(value, key)
+		} else if map_, ok := current.Value.(Map); ok && (element.Type == ListPathType) {
+			// The map key could be of another integer type, as rendered by
+			// [Path.String]
+			if key, ok := findMapKeyNumerically(map_, element.Value); ok {
+				current = current.child(map_[key], key)
+			} else {
+				return NoNode
+			}
+		} else {
+			return NoNode
+		}
+	}
+
+	return current
+}
+
+// Creates a contained node with the same configuration
+func (self *Node) child(value Value, key Value) *Node {
+	node := *self
+	node.Value = value
+	node.container = self
+	node.key = key
+	return &node
+}
+
+// value, actual key, exists, isMap
+func getFromMap(value any, key Value, compareKeys bool) (any, Value, bool, bool) {
 	switch map_ := value.(type) {
 	case Map:
-		if value_, ok := map_[key]; ok {
+		if key_, ok := findMapKey(map_, key, compareKeys); ok {
+			value_ := map_[key_]
 			switch value_.(type) {
 			case Map, StringMap:
-				return value_, true, true
+				return value_, key_, true, true
 			default:
-				return value_, true, false
+				return value_, key_, true, false
 			}
 		}
 
 	case StringMap:
-		if value_, ok := map_[MapKeyToString(key)]; ok {
+		key_ := MapKeyToString(key)
+		if value_, ok := map_[key_]; ok {
 			switch value_.(type) {
 			case Map, StringMap:
-				return value_, true, true
+				return value_, key_, true, true
 			default:
-				return value_, true, false
+				return value_, key_, true, false
 			}
 		}
 	}
 
-	return nil, false, false
+	return nil, nil, false, false
 }
 
-func putInMap(map_ any, key Value, value Value) {
+// Unhashable keys are wrapped via [MakeKey] (or replaced with an equal
+// existing key) so that they cannot cause a runtime panic. The path is that
+// of the entry and is used for errors.
+func putInMap(map_ any, key Value, value Value, path Path) error {
 	switch map__ := map_.(type) {
 	case Map:
+		if !IsSimpleKey(key) {
+			if key_, ok := findMapKey(map__, key, false); ok {
+				key = key_
+			} else {
+				var err error
+				if key, err = MakeKey(key); err != nil {
+					return newPathErrorf(path, "is not a valid key: %s", err.Error())
+				}
+			}
+		}
 		map__[key] = value
 
 	case StringMap:
@@ -669,12 +1258,16 @@ func putInMap(map_ any, key Value, value Value) {
 	default:
 		panic(fmt.Sprintf("not a map: %T", map_))
 	}
+
+	return nil
 }
 
 func deleteFromMap(map_ any, key Value) {
 	switch map__ := map_.(type) {
 	case Map:
-		delete(map__, key)
+		if key_, ok := findMapKey(map__, key, false); ok {
+			delete(map__, key_)
+		}
 
 	case StringMap:
 		delete(map__, MapKeyToString(key))
@@ -0,0 +1,58 @@
+package ard
+
+import (
+	"testing"
+)
+
+func TestNodeGetDottedIntegerKeys(t *testing.T) {
+	value := Map{
+		int64(8080):  Map{"name": "http"},
+		uint64(9090): "metrics",
+		"list":       List{"a", "b"},
+	}
+
+	for _, test := range []struct {
+		path     Path
+		expected Value
+	}{
+		{Path{NewKeyPathElement(int64(8080)), NewFieldPathElement("name")}, "http"},
+		{Path{NewKeyPathElement(uint64(9090))}, "metrics"},
+		{Path{NewFieldPathElement("list"), NewListPathElement(1)}, "b"},
+	} {
+		path := test.path.String()
+
+		if node := With(value).GetDotted(path); node == NoNode {
+			t.Errorf("%s: not found", path)
+		} else if node.Value != test.expected {
+			t.Errorf("%s: expected %#v, got %#v", path, test.expected, node.Value)
+		}
+
+		if node := WithAll(FirstFoundPrecedence, Map{}, value).GetDotted(path).Node(); node == NoNode {
+			t.Errorf("%s: not found in multi-node", path)
+		} else if node.Value != test.expected {
+			t.Errorf("%s: expected %#v in multi-node, got %#v", path, test.expected, node.Value)
+		}
+	}
+
+	if node := With(value).GetDotted("[8081]"); node != NoNode {
+		t.Errorf("[8081]: expected no node, got %#v", node.Value)
+	}
+}
+
+func TestYAMLLocatorIntegerKeys(t *testing.T) {
+	_, locator, err := DecodeYAML([]byte("ports:\n  8080: http\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := ParsePath("ports[8080]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line, column, ok := locator.Locate(path...); !ok {
+		t.Error("ports[8080]: not located")
+	} else if (line != 2) || (column != 3) {
+		t.Errorf("ports[8080]: expected 2,3, got %d,%d", line, column)
+	}
+}
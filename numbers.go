@@ -0,0 +1,282 @@
+package ard
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+//
+// NormalizeNumbersOptions
+//
+
+// By default all numeric fidelity is preserved. Each option relaxes it in
+// order to make values comparable across formats that represent numbers
+// differently. See "Numeric Fidelity" in ARD.md.
+type NormalizeNumbersOptions struct {
+	// When true negative zero (-0.0) is converted to positive zero.
+	NegativeZero bool
+
+	// When true float32 values are converted to float64, using their shortest
+	// decimal representation (e.g. float32(0.1) becomes 0.1 rather than
+	// 0.10000000149011612).
+	Floats32 bool
+
+	// When true integer-valued floats (e.g. 1.0) are converted to int64,
+	// as long as their magnitude is at most 2^53. Negative zero is converted,
+	// too, thus losing its sign.
+	IntegerFloats bool
+
+	// When true signed integers are converted to int64 and unsigned integers
+	// to uint64.
+	WidenIntegers bool
+}
+
+// Normalizes numbers recursively, in place when possible. Returns the
+// normalized value.
+//
+// This is useful for comparing values that went through different formats,
+// e.g. JSON does not distinguish 1.0 from 1 and MessagePack preserves
+// float32, while YAML does not.
+//
+// The options argument can be nil, in which case nothing will be normalized.
+func NormalizeNumbers(value Value, options *NormalizeNumbersOptions) Value {
+	if options == nil {
+		return value
+	}
+
+	switch value_ := value.(type) {
+	case float64:
+		return options.normalizeFloat(value_)
+
+	case float32:
+		if options.Floats32 {
+			return options.normalizeFloat(float64Shortest(value_))
+		} else if integer, ok := options.integerFloat(float64(value_)); ok {
+			return integer
+		} else if options.NegativeZero && (value_ == 0) {
+			return float32(0)
+		}
+
+	case int, int32, int16, int8:
+		if options.WidenIntegers {
+			return toNumber(value_).signed
+		}
+
+	case uint, uint32, uint16, uint8:
+		if options.WidenIntegers {
+			return toNumber(value_).unsigned
+		}
+
+	case Map:
+		for key, element := range value_ {
+			value_[key] = NormalizeNumbers(element, options)
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = NormalizeNumbers(element, options)
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = NormalizeNumbers(element, options)
+		}
+	}
+
+	return value
+}
+
+func (self *NormalizeNumbersOptions) normalizeFloat(float float64) Value {
+	if integer, ok := self.integerFloat(float); ok {
+		return integer
+	} else if self.NegativeZero && (float == 0) {
+		return 0.0
+	} else {
+		return float
+	}
+}
+
+func (self *NormalizeNumbersOptions) integerFloat(float float64) (int64, bool) {
+	if self.IntegerFloats && (float == math.Trunc(float)) && (math.Abs(float) <= maxExactFloatInteger) {
+		return int64(float), true
+	} else {
+		return 0, false
+	}
+}
+
+//
+// NumberKind
+//
+
+type NumberKind int
+
+const (
+	// int64
+	IntegerNumber NumberKind = 0
+
+	// uint64, only for values above the range of int64
+	UnsignedIntegerNumber NumberKind = 1
+
+	// float64
+	FloatNumber NumberKind = 2
+
+	// [*big.Int], only for values out of the range of both int64 and uint64
+	BigIntegerNumber NumberKind = 3
+)
+
+// ([fmt.Stringer] interface)
+func (self NumberKind) String() string {
+	switch self {
+	case IntegerNumber:
+		return "integer"
+	case UnsignedIntegerNumber:
+		return "unsigned integer"
+	case FloatNumber:
+		return "float"
+	case BigIntegerNumber:
+		return "big integer"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// Number
+//
+
+// A normalized number. Only the field for the Kind is set.
+//
+// Integers are always normalized to the narrowest kind that can hold them,
+// int64 first, thus int8(1), uint64(1), and big.NewInt(1) are all
+// [IntegerNumber]. Integers out of the range of both int64 and uint64, e.g.
+// from a [json.Number], are [BigIntegerNumber] rather than a lossy float64,
+// such that callers have to handle extreme values deliberately.
+type Number struct {
+	Kind            NumberKind
+	Integer         int64
+	UnsignedInteger uint64
+	Float           float64
+	BigInteger      *big.Int
+}
+
+// Creates a [Number] from any Go integer or float type, a [json.Number], or a
+// [*big.Int] or [big.Int]. Returns false for other types and malformed
+// [json.Number].
+//
+// A [json.Number] that is an integer literal (with no fraction or exponent)
+// is an integer, otherwise it is a float.
+func NewNumber(value Value) (Number, bool) {
+	switch value_ := value.(type) {
+	case int64, int32, int16, int8, int:
+		return Number{Kind: IntegerNumber, Integer: toNumber(value_).signed}, true
+
+	case uint64, uint32, uint16, uint8, uint:
+		return newUnsignedIntegerNumber(toNumber(value_).unsigned), true
+
+	case float64:
+		return Number{Kind: FloatNumber, Float: value_}, true
+
+	case float32:
+		return Number{Kind: FloatNumber, Float: float64Shortest(value_)}, true
+
+	case *big.Int:
+		if value_ != nil {
+			return newBigIntegerNumber(value_), true
+		}
+
+	case big.Int:
+		return newBigIntegerNumber(&value_), true
+
+	case json.Number:
+		return parseNumber(string(value_))
+	}
+
+	return Number{}, false
+}
+
+// Returns the number as an int64, uint64, float64, or [*big.Int] according to
+// its kind.
+func (self Number) Value() Value {
+	switch self.Kind {
+	case UnsignedIntegerNumber:
+		return self.UnsignedInteger
+	case FloatNumber:
+		return self.Float
+	case BigIntegerNumber:
+		return self.BigInteger
+	default:
+		return self.Integer
+	}
+}
+
+// Returns a new [big.Int] for integer kinds. Returns nil for
+// [FloatNumber].
+func (self Number) BigInt() *big.Int {
+	switch self.Kind {
+	case IntegerNumber:
+		return big.NewInt(self.Integer)
+	case UnsignedIntegerNumber:
+		return new(big.Int).SetUint64(self.UnsignedInteger)
+	case BigIntegerNumber:
+		return new(big.Int).Set(self.BigInteger)
+	default:
+		return nil
+	}
+}
+
+// ([fmt.Stringer] interface)
+func (self Number) String() string {
+	switch self.Kind {
+	case UnsignedIntegerNumber:
+		return strconv.FormatUint(self.UnsignedInteger, 10)
+	case FloatNumber:
+		return strconv.FormatFloat(self.Float, 'g', -1, 64)
+	case BigIntegerNumber:
+		return self.BigInteger.String()
+	default:
+		return strconv.FormatInt(self.Integer, 10)
+	}
+}
+
+// Utils
+
+func newUnsignedIntegerNumber(uinteger uint64) Number {
+	if uinteger <= math.MaxInt64 {
+		return Number{Kind: IntegerNumber, Integer: int64(uinteger)}
+	} else {
+		return Number{Kind: UnsignedIntegerNumber, UnsignedInteger: uinteger}
+	}
+}
+
+// The big.Int is copied if it is not normalized to a narrower kind
+func newBigIntegerNumber(integer *big.Int) Number {
+	if integer.IsInt64() {
+		return Number{Kind: IntegerNumber, Integer: integer.Int64()}
+	} else if integer.IsUint64() {
+		return Number{Kind: UnsignedIntegerNumber, UnsignedInteger: integer.Uint64()}
+	} else {
+		return Number{Kind: BigIntegerNumber, BigInteger: new(big.Int).Set(integer)}
+	}
+}
+
+// Decimal integer literals are integers, anything else is parsed as a float
+func parseNumber(s string) (Number, bool) {
+	if integer, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Number{Kind: IntegerNumber, Integer: integer}, true
+	} else if uinteger, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return Number{Kind: UnsignedIntegerNumber, UnsignedInteger: uinteger}, true
+	} else if integer, ok := new(big.Int).SetString(s, 10); ok {
+		return Number{Kind: BigIntegerNumber, BigInteger: integer}, true
+	} else if float, err := strconv.ParseFloat(s, 64); err == nil {
+		return Number{Kind: FloatNumber, Float: float}, true
+	} else {
+		return Number{}, false
+	}
+}
+
+func float64Shortest(float float32) float64 {
+	float_, _ := strconv.ParseFloat(strconv.FormatFloat(float64(float), 'g', -1, 32), 64)
+	return float_
+}
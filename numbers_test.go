@@ -0,0 +1,79 @@
+package ard
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestNegativeZeroRoundtrip(t *testing.T) {
+	negativeZero := math.Copysign(0, -1)
+
+	for _, format := range []string{"yaml", "json", "xjson", "cbor", "messagepack", "xml"} {
+		value, err := Roundtrip(Map{"a": negativeZero, "b": List{negativeZero}}, format, nil)
+		if err != nil {
+			t.Errorf("%s: %s", format, err.Error())
+			continue
+		}
+
+		map_ := value.(Map)
+		for name, element := range map[string]Value{"a": map_["a"], "b[0]": map_["b"].(List)[0]} {
+			if float, ok := element.(float64); !ok {
+				t.Errorf("%s: %s: expected float64, got %T", format, name, element)
+			} else if (float != 0) || !math.Signbit(float) {
+				t.Errorf("%s: %s: expected -0.0, got %v", format, name, float)
+			}
+		}
+	}
+}
+
+func TestNegativeZeroMinimalXJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteMinimalXJSON(math.Copysign(0, -1), &buffer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := DecodeMinimalXJSON(buffer.Bytes(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if float, ok := value.(float64); !ok || (float != 0) || !math.Signbit(float) {
+		t.Errorf("expected -0.0, got %#v", value)
+	}
+}
+
+func TestNormalizeNumbersNegativeZero(t *testing.T) {
+	for _, test := range []struct {
+		options  *NormalizeNumbersOptions
+		value    Value
+		expected Value
+		signbit  bool
+	}{
+		{nil, math.Copysign(0, -1), 0.0, true},
+		{&NormalizeNumbersOptions{}, math.Copysign(0, -1), 0.0, true},
+		{&NormalizeNumbersOptions{NegativeZero: true}, math.Copysign(0, -1), 0.0, false},
+		{&NormalizeNumbersOptions{NegativeZero: true}, float32(math.Copysign(0, -1)), float32(0), false},
+		{&NormalizeNumbersOptions{IntegerFloats: true}, math.Copysign(0, -1), int64(0), false},
+		{&NormalizeNumbersOptions{IntegerFloats: true}, 1.0, int64(1), false},
+		{&NormalizeNumbersOptions{IntegerFloats: true}, 1.5, 1.5, false},
+	} {
+		value := NormalizeNumbers(test.value, test.options)
+		if value != test.expected {
+			t.Errorf("%#v with %+v: expected %#v, got %#v", test.value, test.options, test.expected, value)
+			continue
+		}
+
+		switch value_ := value.(type) {
+		case float64:
+			if math.Signbit(value_) != test.signbit {
+				t.Errorf("%#v with %+v: expected sign bit %t", test.value, test.options, test.signbit)
+			}
+
+		case float32:
+			if math.Signbit(float64(value_)) != test.signbit {
+				t.Errorf("%#v with %+v: expected sign bit %t", test.value, test.options, test.signbit)
+			}
+		}
+	}
+}
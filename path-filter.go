@@ -0,0 +1,102 @@
+package ard
+
+// Utils
+
+// Decides which values to decode according to include and exclude path
+// patterns. Patterns are paths in [Path.String] format in which "*" can be
+// used as a wildcard for a whole path element, e.g. "servers[*].port" or
+// "services.*.port".
+type pathFilter struct {
+	include []Path
+	exclude []Path
+}
+
+// Returns nil if there are no patterns
+func newPathFilter(include []string, exclude []string) (*pathFilter, error) {
+	if (len(include) == 0) && (len(exclude) == 0) {
+		return nil, nil
+	}
+
+	var filter pathFilter
+	for _, pattern := range include {
+		if path, err := parseSchemaPattern(pattern); err == nil {
+			filter.include = append(filter.include, path)
+		} else {
+			return nil, err
+		}
+	}
+	for _, pattern := range exclude {
+		if path, err := parseSchemaPattern(pattern); err == nil {
+			filter.exclude = append(filter.exclude, path)
+		} else {
+			return nil, err
+		}
+	}
+	return &filter, nil
+}
+
+type pathFilterResult int
+
+const (
+	// Skip the value, including all of its nested values
+	pathFilterSkip pathFilterResult = 0
+
+	// Keep the value (nested values must still be filtered for exclusion)
+	pathFilterKeep pathFilterResult = 1
+
+	// Keep the value only if it is a map or a list, because it leads to
+	// included paths (nested values must still be filtered)
+	pathFilterDescend pathFilterResult = 2
+)
+
+// Paths at or under an excluded pattern are skipped. If there are include
+// patterns then only paths at or under them are kept, as well as the maps and
+// lists leading to them.
+func (self *pathFilter) filter(path Path) pathFilterResult {
+	for _, pattern := range self.exclude {
+		if (len(pattern) <= len(path)) && pathPatternMatches(pattern, path[:len(pattern)]) {
+			return pathFilterSkip
+		}
+	}
+
+	if len(self.include) == 0 {
+		return pathFilterKeep
+	}
+
+	result := pathFilterSkip
+	for _, pattern := range self.include {
+		if len(pattern) <= len(path) {
+			if pathPatternMatches(pattern, path[:len(pattern)]) {
+				return pathFilterKeep
+			}
+		} else if pathPatternMatches(pattern[:len(path)], path) {
+			result = pathFilterDescend
+		}
+	}
+	return result
+}
+
+// Pattern and path must be of the same length
+func pathPatternMatches(pattern Path, path Path) bool {
+	for index, element := range pattern {
+		if !pathPatternElementMatches(element, path[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func pathPatternElementMatches(pattern PathElement, element PathElement) bool {
+	isList := (element.Type == ListPathType) || (element.Type == SequencedListPathType)
+
+	if isPathWildcard(pattern) {
+		return isList == (pattern.Type == ListPathType)
+	}
+
+	switch pattern.Type {
+	case ListPathType, SequencedListPathType:
+		return isList && (pattern.Value == element.Value)
+	default:
+		return !isList && Equals(pattern.Value, element.Value)
+	}
+}
@@ -1,8 +1,12 @@
 package ard
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 //
@@ -11,18 +15,70 @@ import (
 
 type PathElement struct {
 	Type  PathElementType
-	Value any // string for FieldPathType and MapPathType; int for ListPathType and SequencedListPathType
+	Value any // string for FieldPathType and MapPathType; int for ListPathType and SequencedListPathType; bool or number for KeyPathType
 }
 
 type PathElementType int
 
+// ([fmt.Stringer] interface)
+func (self PathElementType) String() string {
+	switch self {
+	case FieldPathType:
+		return "field"
+	case MapPathType:
+		return "map"
+	case ListPathType:
+		return "list"
+	case SequencedListPathType:
+		return "sequencedList"
+	case KeyPathType:
+		return "key"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
 const (
 	FieldPathType         PathElementType = 0
 	MapPathType           PathElementType = 1
 	ListPathType          PathElementType = 2
 	SequencedListPathType PathElementType = 3
+	KeyPathType           PathElementType = 4
 )
 
+func newPathElement(type_ string, value Value) (PathElement, error) {
+	switch type_ {
+	case "field", "map":
+		if value_, ok := value.(string); ok {
+			if type_ == "field" {
+				return NewFieldPathElement(value_), nil
+			} else {
+				return NewMapPathElement(value_), nil
+			}
+		}
+
+	case "list", "sequencedList":
+		if index, ok := toIndex(value); ok {
+			if type_ == "list" {
+				return NewListPathElement(index), nil
+			} else {
+				return NewSequencedListPathElement(index), nil
+			}
+		}
+
+	case "key":
+		switch value.(type) {
+		case bool, int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32:
+			return PathElement{KeyPathType, value}, nil
+		}
+
+	default:
+		return PathElement{}, fmt.Errorf("unsupported type: %q", type_)
+	}
+
+	return PathElement{}, fmt.Errorf("unsupported value for %s: %s", type_, GetTypeName(value))
+}
+
 func NewFieldPathElement(name string) PathElement {
 	return PathElement{FieldPathType, name}
 }
@@ -31,6 +87,20 @@ func NewMapPathElement(name string) PathElement {
 	return PathElement{MapPathType, name}
 }
 
+// Creates a path element for an arbitrary map key. String keys become [FieldPathType]
+// elements, boolean and number keys become [KeyPathType] elements, and other keys
+// become [MapPathType] elements converted using [MapKeyToString].
+func NewKeyPathElement(key Value) PathElement {
+	switch key_ := key.(type) {
+	case string:
+		return NewFieldPathElement(key_)
+	case bool, int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32:
+		return PathElement{KeyPathType, key_}
+	default:
+		return NewMapPathElement(MapKeyToString(key))
+	}
+}
+
 func NewListPathElement(index int) PathElement {
 	return PathElement{ListPathType, index}
 }
@@ -61,6 +131,10 @@ func (self Path) AppendMap(name string) Path {
 	return self.Append(NewMapPathElement(name))
 }
 
+func (self Path) AppendKey(key Value) Path {
+	return self.Append(NewKeyPathElement(key))
+}
+
 func (self Path) AppendList(index int) Path {
 	return self.Append(NewListPathElement(index))
 }
@@ -69,7 +143,80 @@ func (self Path) AppendSequencedList(index int) Path {
 	return self.Append(NewSequencedListPathElement(index))
 }
 
-var fieldPathElementEscapeRe = regexp.MustCompile(`([\".\[\]{}])`)
+// Returns a lossless structured representation of the path as a [List] in which
+// each element is a single-entry [StringMap] mapping the element type to its
+// value, e.g. {"field": "servers"}, {"list": 0}, {"key": 8080}.
+//
+// The element types are "field", "map", "list", "sequencedList", and "key".
+//
+// See [PathFromList].
+func (self Path) ToList() List {
+	list := make(List, len(self))
+	for index, element := range self {
+		list[index] = StringMap{element.Type.String(): element.Value}
+	}
+	return list
+}
+
+// Parses the representation created by [Path.ToList]. [Map] and [StringMap]
+// elements are supported, as are any numeric types for indexes.
+func PathFromList(list List) (Path, error) {
+	path := make(Path, len(list))
+	for index, element := range list {
+		var type_ string
+		var value Value
+		switch element_ := element.(type) {
+		case StringMap:
+			if len(element_) == 1 {
+				for type_, value = range element_ {
+				}
+			}
+		case Map:
+			if len(element_) == 1 {
+				for key, value_ := range element_ {
+					type_, _ = key.(string)
+					value = value_
+				}
+			}
+		}
+
+		if pathElement, err := newPathElement(type_, value); err == nil {
+			path[index] = pathElement
+		} else {
+			return nil, fmt.Errorf("malformed path element %d: %w", index, err)
+		}
+	}
+	return path, nil
+}
+
+// Encodes [Path.ToList] as JSON.
+//
+// ([encoding.TextMarshaler] interface)
+func (self Path) MarshalText() ([]byte, error) {
+	return json.Marshal(self.ToList())
+}
+
+// Decodes JSON via [PathFromList].
+//
+// ([encoding.TextUnmarshaler] interface)
+func (self *Path) UnmarshalText(text []byte) error {
+	if value, err := DecodeJSONWithOptions(text, &JSONDecodeOptions{UseStringMaps: true, PreserveIntegers: true}); err == nil {
+		if list, ok := value.(List); ok {
+			if path, err := PathFromList(list); err == nil {
+				*self = path
+				return nil
+			} else {
+				return err
+			}
+		} else {
+			return errors.New("path is not a list")
+		}
+	} else {
+		return err
+	}
+}
+
+var fieldPathElementEscapeRe = regexp.MustCompile(`([\\".\[\]{}])`)
 
 // ([fmt.Stringer] interface)
 func (self Path) String() string {
@@ -96,8 +243,129 @@ func (self Path) String() string {
 		case SequencedListPathType:
 			value := element.Value.(int)
 			path = fmt.Sprintf("%s{%d}", path, value)
+
+		case KeyPathType:
+			path = fmt.Sprintf("%s[%s]", path, ValueToString(element.Value))
 		}
 	}
 
 	return path
 }
+
+// Parses a path in [Path.String] format.
+//
+// Because [Path.String] renders integer [KeyPathType] elements the same as
+// list indexes, e.g. "[8080]", these will be parsed as [ListPathType]
+// elements. Other bracketed values that are not quoted are parsed as
+// [KeyPathType] elements (booleans and floats).
+func ParsePath(path string) (Path, error) {
+	var path_ Path
+	runes := []rune(path)
+	length := len(runes)
+
+	for index := 0; index < length; {
+		switch rune_ := runes[index]; rune_ {
+		case '[', '{':
+			end := ']'
+			if rune_ == '{' {
+				end = '}'
+			}
+
+			start := index + 1
+			index = start
+			if (rune_ == '[') && (index < length) && (runes[index] == '"') {
+				// Skip over quoted string
+				for index++; (index < length) && (runes[index] != '"'); index++ {
+					if runes[index] == '\\' {
+						index++
+					}
+				}
+				index++
+			}
+
+			for (index < length) && (runes[index] != end) {
+				index++
+			}
+			if index >= length {
+				return nil, fmt.Errorf("unterminated %q at position %d in path: %s", rune_, start-1, path)
+			}
+
+			if element, err := parseBracketedPathElement(string(runes[start:index]), rune_ == '{'); err == nil {
+				path_ = append(path_, element)
+			} else {
+				return nil, fmt.Errorf("%s at position %d in path: %s", err.Error(), start-1, path)
+			}
+			index++
+
+		default:
+			if rune_ == '.' {
+				if len(path_) == 0 {
+					return nil, fmt.Errorf("empty field at position 0 in path: %s", path)
+				}
+				index++
+			}
+
+			var name strings.Builder
+			start := index
+		Field:
+			for ; index < length; index++ {
+				switch runes[index] {
+				case '\\':
+					if index++; index < length {
+						name.WriteRune(runes[index])
+					}
+				case '.', '[', '{':
+					break Field
+				default:
+					name.WriteRune(runes[index])
+				}
+			}
+
+			if name.Len() == 0 {
+				return nil, fmt.Errorf("empty field at position %d in path: %s", start, path)
+			}
+			path_ = append(path_, NewFieldPathElement(name.String()))
+		}
+	}
+
+	return path_, nil
+}
+
+// Path patterns are paths in [Path.String] format in which "*" can be used as
+// a wildcard for a whole path element, e.g. "servers[*].port" or "services.*.port".
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	pattern = regexp.QuoteMeta(pattern)
+	pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[\d+\]`)
+	pattern = strings.ReplaceAll(pattern, `\*`, `(?:[^.\[\]\\]|\\.)+`)
+	return regexp.Compile("^" + pattern + "$")
+}
+
+// Utils
+
+func parseBracketedPathElement(value string, sequenced bool) (PathElement, error) {
+	if sequenced {
+		if index, err := strconv.Atoi(value); err == nil {
+			return NewSequencedListPathElement(index), nil
+		} else {
+			return PathElement{}, fmt.Errorf("malformed sequenced list index %q", value)
+		}
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		if name, err := strconv.Unquote(value); err == nil {
+			return NewMapPathElement(name), nil
+		} else {
+			return PathElement{}, fmt.Errorf("malformed map key %s", value)
+		}
+	}
+
+	if index, err := strconv.Atoi(value); err == nil {
+		return NewListPathElement(index), nil
+	} else if boolean, err := strconv.ParseBool(value); err == nil {
+		return PathElement{KeyPathType, boolean}, nil
+	} else if float, err := strconv.ParseFloat(value, 64); err == nil {
+		return PathElement{KeyPathType, float}, nil
+	} else {
+		return PathElement{}, fmt.Errorf("malformed index or key %q", value)
+	}
+}
@@ -0,0 +1,239 @@
+package ard
+
+import (
+	"sort"
+)
+
+// Returns a new value containing only the provided paths, preserving the
+// structure leading to them. The selected values are deep copied (via
+// [Copy]). Paths that are not found are ignored.
+//
+// [List] elements that are not selected are removed, so indexes in the
+// returned lists may differ from those in the original value. A path with
+// no elements selects the whole value.
+//
+// If the value is a [Map], [StringMap], or [List] then an empty instance of
+// it will be returned when none of the paths are found, otherwise nil will
+// be returned.
+//
+// Paths can be parsed from strings via [ParsePath], which is useful for
+// supporting field-filtering APIs.
+func Project(value Value, paths []Path) Value {
+	if value_, ok := project(value, newPathTree(paths)); ok {
+		return value_
+	}
+
+	switch value.(type) {
+	case Map:
+		return make(Map)
+	case StringMap:
+		return make(StringMap)
+	case List:
+		return make(List, 0)
+	default:
+		return nil
+	}
+}
+
+// Returns a deep copy of the value (via [Copy]) without the provided paths.
+// Paths that are not found are ignored.
+//
+// Removed [List] elements shift the indexes of the elements after them. A
+// path with no elements removes the whole value, in which case nil will be
+// returned.
+func Exclude(value Value, paths []Path) Value {
+	value, _ = exclude(value, newPathTree(paths))
+	return value
+}
+
+// Utils
+
+type pathTree struct {
+	all      bool
+	children []pathTreeChild
+}
+
+type pathTreeChild struct {
+	element PathElement
+	tree    *pathTree
+}
+
+func newPathTree(paths []Path) *pathTree {
+	var root pathTree
+	for _, path := range paths {
+		tree := &root
+		for _, element := range path {
+			tree = tree.child(element)
+		}
+		tree.all = true
+	}
+	return &root
+}
+
+func (self *pathTree) child(element PathElement) *pathTree {
+	for _, child := range self.children {
+		if child.element == element {
+			return child.tree
+		}
+	}
+
+	tree := new(pathTree)
+	self.children = append(self.children, pathTreeChild{element, tree})
+	return tree
+}
+
+// Returns the subtrees mapped to actual keys (for [Map] and [StringMap]) or
+// indexes (for [List])
+func (self *pathTree) resolve(value Value) map[any]*pathTree {
+	resolved := make(map[any]*pathTree)
+	for _, child := range self.children {
+		var key any
+		switch value_ := value.(type) {
+		case Map, StringMap:
+			if _, key_, ok, _ := getFromMap(value_, child.element.Value, false); ok {
+				key = key_
+			} else {
+				continue
+			}
+
+		case List:
+			switch child.element.Type {
+			case ListPathType, SequencedListPathType:
+				if index := child.element.Value.(int); (index >= 0) && (index < len(value_)) {
+					key = index
+				} else {
+					continue
+				}
+			default:
+				continue
+			}
+
+		default:
+			continue
+		}
+
+		if tree, ok := resolved[key]; ok {
+			// Different elements resolved to the same key
+			resolved[key] = tree.union(child.tree)
+		} else {
+			resolved[key] = child.tree
+		}
+	}
+	return resolved
+}
+
+func (self *pathTree) union(tree *pathTree) *pathTree {
+	var union pathTree
+	union.all = self.all || tree.all
+	union.children = append(union.children, self.children...)
+	union.children = append(union.children, tree.children...)
+	return &union
+}
+
+// Returns false if nothing was selected
+func project(value Value, tree *pathTree) (Value, bool) {
+	if tree.all {
+		return Copy(value), true
+	}
+
+	resolved := tree.resolve(value)
+	if len(resolved) == 0 {
+		return nil, false
+	}
+
+	switch value_ := value.(type) {
+	case Map:
+		map_ := make(Map)
+		for key, tree_ := range resolved {
+			if value__, ok := project(value_[key], tree_); ok {
+				map_[Copy(key)] = value__
+			}
+		}
+		return map_, len(map_) > 0
+
+	case StringMap:
+		map_ := make(StringMap)
+		for key, tree_ := range resolved {
+			key_ := key.(string)
+			if value__, ok := project(value_[key_], tree_); ok {
+				map_[key_] = value__
+			}
+		}
+		return map_, len(map_) > 0
+
+	case List:
+		indexes := make([]int, 0, len(resolved))
+		for index := range resolved {
+			indexes = append(indexes, index.(int))
+		}
+		sort.Ints(indexes)
+
+		list := make(List, 0, len(indexes))
+		for _, index := range indexes {
+			if element, ok := project(value_[index], resolved[index]); ok {
+				list = append(list, element)
+			}
+		}
+		return list, len(list) > 0
+	}
+
+	return nil, false
+}
+
+// Returns false if the value should be removed
+func exclude(value Value, tree *pathTree) (Value, bool) {
+	if tree.all {
+		return nil, false
+	}
+
+	resolved := tree.resolve(value)
+	if len(resolved) == 0 {
+		return Copy(value), true
+	}
+
+	switch value_ := value.(type) {
+	case Map:
+		map_ := make(Map)
+		for key, value__ := range value_ {
+			if tree_, ok := resolved[key]; ok {
+				if value__, ok = exclude(value__, tree_); !ok {
+					continue
+				}
+			} else {
+				value__ = Copy(value__)
+			}
+			map_[Copy(key)] = value__
+		}
+		return map_, true
+
+	case StringMap:
+		map_ := make(StringMap)
+		for key, value__ := range value_ {
+			if tree_, ok := resolved[key]; ok {
+				if value__, ok = exclude(value__, tree_); !ok {
+					continue
+				}
+			} else {
+				value__ = Copy(value__)
+			}
+			map_[key] = value__
+		}
+		return map_, true
+
+	case List:
+		list := make(List, 0, len(value_))
+		for index, element := range value_ {
+			if tree_, ok := resolved[index]; ok {
+				if element, ok = exclude(element, tree_); !ok {
+					continue
+				}
+			} else {
+				element = Copy(element)
+			}
+			list = append(list, element)
+		}
+		return list, true
+	}
+
+	return Copy(value), true
+}
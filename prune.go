@@ -0,0 +1,83 @@
+package ard
+
+//
+// PruneOptions
+//
+
+type PruneOptions struct {
+	// Remove nil values from [Map], [StringMap], and [List]
+	Nils bool
+
+	// Remove empty [Map], [StringMap], and [List] values. This is applied
+	// after their contents are pruned, so a map that contains only nils
+	// would be removed if Nils is also true.
+	EmptyCollections bool
+
+	// If set, will be called for every [Map] and [StringMap] entry. Returning
+	// true will remove the entry. The path is that of the entry.
+	KeyPredicate func(path Path, key Value, value Value) bool
+}
+
+// Returns a deep copy of the value with unwanted entries removed, e.g. in
+// order to encode "sparse" documents.
+//
+// The options argument can be nil, in which case nothing is pruned.
+//
+// The root value itself is never pruned, even if it's nil or an empty
+// collection. Non-ARD values are left as is.
+func Prune(value Value, options *PruneOptions) Value {
+	if options == nil {
+		options = new(PruneOptions)
+	}
+
+	value, _ = prune(value, nil, options)
+	return value
+}
+
+// Utils
+
+// Returns false if the value should be removed
+func prune(value Value, path Path, options *PruneOptions) (Value, bool) {
+	switch value_ := value.(type) {
+	case nil:
+		return nil, !options.Nils
+
+	case Map:
+		map_ := make(Map)
+		for key, value__ := range value_ {
+			path_ := path.AppendKey(key)
+			if (options.KeyPredicate != nil) && options.KeyPredicate(path_, key, value__) {
+				continue
+			}
+			if value__, ok := prune(value__, path_, options); ok {
+				map_[Copy(key)] = value__
+			}
+		}
+		return map_, !options.EmptyCollections || (len(map_) > 0)
+
+	case StringMap:
+		map_ := make(StringMap)
+		for key, value__ := range value_ {
+			path_ := path.AppendKey(key)
+			if (options.KeyPredicate != nil) && options.KeyPredicate(path_, key, value__) {
+				continue
+			}
+			if value__, ok := prune(value__, path_, options); ok {
+				map_[key] = value__
+			}
+		}
+		return map_, !options.EmptyCollections || (len(map_) > 0)
+
+	case List:
+		list := make(List, 0, len(value_))
+		for index, element := range value_ {
+			if element, ok := prune(element, path.AppendList(index), options); ok {
+				list = append(list, element)
+			}
+		}
+		return list, !options.EmptyCollections || (len(list) > 0)
+
+	default:
+		return value, true
+	}
+}
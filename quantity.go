@@ -0,0 +1,155 @@
+package ard
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// QuantityKind
+//
+
+type QuantityKind int
+
+const (
+	// A plain number, possibly with a multiplier suffix, e.g. "10Gi" or "250m"
+	ScalarQuantity QuantityKind = 0
+
+	// A duration, e.g. "1.5h" or "1h30m", normalized to seconds
+	DurationQuantity QuantityKind = 1
+
+	// A percentage, e.g. "80%", normalized to a fraction
+	PercentQuantity QuantityKind = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self QuantityKind) String() string {
+	switch self {
+	case ScalarQuantity:
+		return "scalar"
+	case DurationQuantity:
+		return "duration"
+	case PercentQuantity:
+		return "percent"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+//
+// Quantity
+//
+
+type Quantity struct {
+	Kind QuantityKind
+
+	// Normalized value: with the multiplier applied for [ScalarQuantity], in
+	// seconds for [DurationQuantity], and as a fraction for [PercentQuantity]
+	// (e.g. "80%" is 0.8).
+	Value float64
+
+	// The unit suffix as written, e.g. "Gi", "h", or "%". Empty for plain
+	// numbers.
+	Unit string
+}
+
+// Binary (IEC) and decimal (SI) multiplier suffixes, as used by Kubernetes
+// resource quantities
+var quantityMultipliers = map[string]float64{
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+	"n":  1e-9,
+	"u":  1e-6,
+	"µ":  1e-6,
+	"m":  1e-3,
+	"k":  1e3,
+	"K":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+}
+
+// Parses quantity strings with units into normalized values.
+//
+// Supported are numbers with binary and decimal multiplier suffixes (e.g.
+// "10Gi", "1.5G", "250m"), durations in [time.ParseDuration] format (e.g.
+// "1.5h", "1h30m", "250ms"), and percentages (e.g. "80%").
+//
+// Note that "m" alone is the milli multiplier (as in Kubernetes CPU
+// quantities), not minutes. For minutes use a compound duration, e.g.
+// "0h30m", or seconds.
+func ParseQuantity(quantity string) (Quantity, error) {
+	quantity = strings.TrimSpace(quantity)
+
+	// Split number from suffix
+	split := 0
+	for index, rune_ := range quantity {
+		if ((rune_ >= '0') && (rune_ <= '9')) || (rune_ == '.') || (((rune_ == '-') || (rune_ == '+')) && (index == 0)) {
+			split = index + 1
+		} else {
+			break
+		}
+	}
+
+	if split == 0 {
+		return Quantity{}, fmt.Errorf("malformed quantity: %q", quantity)
+	}
+
+	// Exponent notation, e.g. "1e3"
+	if value, err := strconv.ParseFloat(quantity, 64); err == nil {
+		return Quantity{ScalarQuantity, value, ""}, nil
+	}
+
+	number, suffix := quantity[:split], quantity[split:]
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return Quantity{}, fmt.Errorf("malformed quantity: %q", quantity)
+	}
+
+	if suffix == "" {
+		return Quantity{ScalarQuantity, value, ""}, nil
+	} else if suffix == "%" {
+		return Quantity{PercentQuantity, value / 100, suffix}, nil
+	} else if multiplier, ok := quantityMultipliers[suffix]; ok {
+		return Quantity{ScalarQuantity, value * multiplier, suffix}, nil
+	} else if duration, err := time.ParseDuration(quantity); err == nil {
+		return Quantity{DurationQuantity, duration.Seconds(), suffix}, nil
+	} else {
+		return Quantity{}, fmt.Errorf("unsupported quantity unit %q: %q", suffix, quantity)
+	}
+}
+
+// Returns the value as a [time.Duration]. Returns false if the quantity is not
+// a [DurationQuantity].
+func (self Quantity) Duration() (time.Duration, bool) {
+	if self.Kind == DurationQuantity {
+		return time.Duration(math.Round(self.Value * float64(time.Second))), true
+	} else {
+		return 0, false
+	}
+}
+
+// Returns the value as an int64. Returns false if it is not a whole number or
+// is out of range.
+func (self Quantity) Int64() (int64, bool) {
+	if (self.Value == math.Trunc(self.Value)) && (self.Value >= math.MinInt64) && (self.Value < math.MaxInt64) {
+		return int64(self.Value), true
+	} else {
+		return 0, false
+	}
+}
+
+// ([fmt.Stringer] interface)
+func (self Quantity) String() string {
+	return fmt.Sprintf("%s (%s)", strconv.FormatFloat(self.Value, 'g', -1, 64), self.Kind)
+}
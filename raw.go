@@ -0,0 +1,52 @@
+package ard
+
+import (
+	"bytes"
+	"fmt"
+)
+
+//
+// Raw
+//
+
+// Undecoded bytes of a single value in a format, similar to
+// [encoding/json.RawMessage]. Raw values are produced by [JSONEventProducer]
+// for deferred paths (see [JSONEventProducerOptions]) so that large subtrees
+// that are not needed can skip decoding, and can be decoded later via
+// [Raw.Decode].
+//
+// Raw is not an ARD value. It is supported by [Copy] (which copies its bytes)
+// and by [ValidCopy] (which decodes it). Encoding it to JSON will emit its bytes as is if its
+// format is "json", otherwise it will be decoded first.
+type Raw struct {
+	Format string
+	Bytes  []byte
+}
+
+// Decodes the bytes to an ARD [Value]. See [Decode].
+func (self *Raw) Decode() (Value, error) {
+	value, _, err := Decode(self.Bytes, self.Format, false)
+	return value, err
+}
+
+// ([json.Marshaler] interface)
+func (self *Raw) MarshalJSON() ([]byte, error) {
+	if self.Format == "json" {
+		return bytes.TrimSpace(self.Bytes), nil
+	}
+
+	if value, err := self.Decode(); err == nil {
+		if code, err := EncodeJSONWithOptions(value, nil, nil); err == nil {
+			return bytes.TrimSpace(code), nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// ([fmt.Stringer] interface)
+func (self *Raw) String() string {
+	return fmt.Sprintf("raw %s (%d bytes)", self.Format, len(self.Bytes))
+}
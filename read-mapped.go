@@ -0,0 +1,80 @@
+package ard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Reads and decodes a local file to ARD via a read-only memory mapping of the
+// file, which reduces peak memory when loading very large JSON and CBOR
+// documents: they are decoded directly from the mapping rather than from a
+// copy of the file's content. Other formats are decoded from the mapping
+// via [Decode]. On platforms that do not support memory mapping the file is
+// read into memory instead.
+//
+// Decoded strings and []byte never refer to the mapping, which is unmapped
+// before this function returns, so the result is safe to keep.
+//
+// If format is empty then it will be determined from the file extension. See
+// [GetFormatForPath].
+//
+// As with [Read], all resulting maps are guaranteed to be [Map] (and not
+// [StringMap]). Note that for JSON, unlike [Read], data after the first value
+// is an error. For JSON the default [JSONAPI] is used only if it was not
+// changed via [SetJSONAPI].
+func ReadFileMapped(path string, format string) (Value, error) {
+	if format == "" {
+		if format = GetFormatForPath(path); format == "" {
+			return nil, fmt.Errorf("cannot determine format for file: %s", path)
+		}
+	}
+
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+
+	if metrics := getMetrics(); metrics != nil {
+		start := now()
+		value, err := decodeMapped(data, format)
+		metrics.Decoded(format, int64(len(data)), since(start), err)
+		return value, err
+	} else {
+		return decodeMapped(data, format)
+	}
+}
+
+// Utils
+
+func decodeMapped(data []byte, format string) (Value, error) {
+	switch format {
+	case "json":
+		if globalJSONAPI.Load() == nil {
+			// Unlike json.Decoder, json.Unmarshal does not buffer a copy of the data
+			var value Value
+			if err := json.Unmarshal(data, &value); err == nil {
+				value, _ = ConvertStringMapsToMaps(value)
+				return value, nil
+			} else {
+				return nil, err
+			}
+		}
+
+	case "cbor":
+		// Unlike cbor.Decoder, cbor.UnmarshalFirst does not buffer a copy of the
+		// data (it does copy decoded strings and []byte)
+		var value Value
+		if _, err := cbor.UnmarshalFirst(data, &value); err == nil {
+			return value, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	value, _, err := read(bytes.NewReader(data), format, false)
+	return value, err
+}
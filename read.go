@@ -1,10 +1,15 @@
 package ard
 
 import (
+	"bytes"
 	contextpkg "context"
-	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 	"github.com/fxamacker/cbor/v2"
@@ -14,42 +19,125 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Returns the formats supported by [Read], [Write], [Decode], and [Encode].
+func SupportedFormats() []string {
+	formats := make([]string, len(supportedFormats))
+	for index, format := range supportedFormats {
+		formats[index] = format.name
+	}
+	return formats
+}
+
+// Returns true if the format is in [SupportedFormats].
+func IsSupportedFormat(format string) bool {
+	_, ok := getSupportedFormat(format)
+	return ok
+}
+
 // Reads and decodes supported formats to ARD.
 //
-// All resulting maps are guaranteed to be [Map] (and not [StringMap]).
+// All resulting maps are guaranteed to be [Map] (and not [StringMap]). Use
+// [Codec.Read] to choose the map type.
 //
 // If locate is true then a [Locator] will be returned if possible.
 // Currently only YAML decoding supports this feature.
 //
 // Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
 func Read(reader io.Reader, format string, locate bool) (Value, Locator, error) {
-	switch format {
-	case "yaml":
-		return ReadYAML(reader, locate)
+	if metrics := getMetrics(); metrics != nil {
+		counter := countingReader{reader: reader}
+		start := now()
+		value, locator, err := read(&counter, format, locate)
+		metrics.Decoded(format, counter.count, since(start), err)
+		return value, locator, err
+	} else {
+		return read(reader, format, locate)
+	}
+}
 
-	case "json":
-		value, err := ReadJSON(reader, false)
-		return value, nil, err
+func read(reader io.Reader, format string, locate bool) (Value, Locator, error) {
+	if format_, ok := getSupportedFormat(format); ok {
+		return format_.read(reader, locate)
+	} else {
+		return nil, nil, fmt.Errorf("unsupported format: %q", format)
+	}
+}
 
-	case "xjson":
-		value, err := ReadXJSON(reader, false)
-		return value, nil, err
+//
+// supportedFormat
+//
 
-	case "xml":
-		value, err := ReadXML(reader)
-		return value, nil, err
+type supportedFormat struct {
+	name  string
+	read  func(reader io.Reader, locate bool) (Value, Locator, error)
+	write func(value Value, writer io.Writer, reflector *Reflector) error
+}
 
-	case "cbor":
-		value, err := ReadCBOR(reader, false)
-		return value, nil, err
+// In the order returned by [SupportedFormats]
+var supportedFormats []supportedFormat
 
-	case "messagepack":
-		value, err := ReadMessagePack(reader, false, false)
-		return value, nil, err
+func init() {
+	// Initialized here because some of the functions refer back to the table
+	supportedFormats = []supportedFormat{
+		{
+			name:  "yaml",
+			read:  ReadYAML,
+			write: WriteYAML,
+		},
+		{
+			name: "json",
+			read: func(reader io.Reader, locate bool) (Value, Locator, error) {
+				value, err := ReadJSON(reader, false)
+				return value, nil, err
+			},
+			write: WriteJSON,
+		},
+		{
+			name: "xjson",
+			read: func(reader io.Reader, locate bool) (Value, Locator, error) {
+				value, err := ReadXJSON(reader, false)
+				return value, nil, err
+			},
+			write: WriteXJSON,
+		},
+		{
+			name: "xml",
+			read: func(reader io.Reader, locate bool) (Value, Locator, error) {
+				value, err := ReadXML(reader)
+				return value, nil, err
+			},
+			write: WriteXML,
+		},
+		{
+			name: "cbor",
+			read: func(reader io.Reader, locate bool) (Value, Locator, error) {
+				value, err := ReadCBOR(reader, false)
+				return value, nil, err
+			},
+			write: func(value Value, writer io.Writer, reflector *Reflector) error {
+				return WriteCBOR(value, writer)
+			},
+		},
+		{
+			name: "messagepack",
+			read: func(reader io.Reader, locate bool) (Value, Locator, error) {
+				value, err := ReadMessagePack(reader, false, false)
+				return value, nil, err
+			},
+			write: func(value Value, writer io.Writer, reflector *Reflector) error {
+				return WriteMessagePack(value, writer)
+			},
+		},
+	}
+}
 
-	default:
-		return nil, nil, fmt.Errorf("unsupported format: %q", format)
+func getSupportedFormat(name string) (*supportedFormat, bool) {
+	for index := range supportedFormats {
+		if supportedFormats[index].name == name {
+			return &supportedFormats[index], true
+		}
 	}
+	return nil, false
 }
 
 // Convenience function to read from a URL. Calls [Read].
@@ -63,19 +151,122 @@ func ReadURL(context contextpkg.Context, url exturl.URL, format string, forceFor
 		reader = util.NewContextualReadCloser(context, reader)
 		defer reader.Close()
 
-		var format_ string
-		if forceFormat {
-			format_ = format
-		} else {
-			format_ = url.Format()
-			if format_ == "" {
-				format_ = format
-			}
+		return Read(reader, getURLFormat(url, format, forceFormat), locate)
+	} else {
+		return nil, nil, err
+	}
+}
+
+//
+// ReadURLOptions
+//
+
+type ReadURLOptions struct {
+	// When not nil will be used to compute [URLInfo].Hash, e.g.
+	// [crypto/sha256.New].
+	Hash func() hash.Hash
+}
+
+//
+// URLInfo
+//
+
+// Information about the content read from a URL, which can be used for
+// caching, change detection, and integrity verification.
+type URLInfo struct {
+	// Hash of the entire content. Nil if [ReadURLOptions].Hash is not set.
+	Hash []byte
+
+	// From the HTTP "ETag" header, including quotes. Empty if not provided or
+	// not an HTTP URL.
+	ETag string
+
+	// From the HTTP "Last-Modified" header. Zero if not provided or not an
+	// HTTP URL.
+	LastModified time.Time
+}
+
+// Like [ReadURL] but also returns [URLInfo].
+//
+// Note that when a hash is computed the content will be read to its end,
+// even if decoding does not require it, e.g. for YAML with more than one
+// document.
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadURLWithOptions(context contextpkg.Context, url exturl.URL, format string, forceFormat bool, locate bool, options *ReadURLOptions) (Value, Locator, *URLInfo, error) {
+	if options == nil {
+		options = new(ReadURLOptions)
+	}
+
+	var info URLInfo
+	var reader io.ReadCloser
+	var err error
+	if networkUrl, ok := url.(*exturl.NetworkURL); ok {
+		reader, err = openHTTP(context, networkUrl, &info)
+	} else {
+		reader, err = url.Open(context)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	reader = util.NewContextualReadCloser(context, reader)
+	defer reader.Close()
+
+	var reader_ io.Reader = reader
+	var hash_ hash.Hash
+	if options.Hash != nil {
+		hash_ = options.Hash()
+		reader_ = io.TeeReader(reader, hash_)
+	}
+
+	value, locator, err := Read(reader_, getURLFormat(url, format, forceFormat), locate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if hash_ != nil {
+		// Make sure we hash the entire content
+		if _, err := io.Copy(io.Discard, reader_); err != nil {
+			return nil, nil, nil, err
 		}
+		info.Hash = hash_.Sum(nil)
+	}
 
-		return Read(reader, format_, locate)
+	return value, locator, &info, nil
+}
+
+func getURLFormat(url exturl.URL, format string, forceFormat bool) string {
+	if !forceFormat {
+		if format_ := url.Format(); format_ != "" {
+			return format_
+		}
+	}
+	return format
+}
+
+// Like [exturl.NetworkURL.Open] but also fills in the HTTP headers
+func openHTTP(context contextpkg.Context, url *exturl.NetworkURL, info *URLInfo) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(context, http.MethodGet, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if response, err := http.DefaultClient.Do(request); err == nil {
+		if response.StatusCode == http.StatusOK {
+			info.ETag = response.Header.Get("ETag")
+			if lastModified := response.Header.Get("Last-Modified"); lastModified != "" {
+				if lastModified_, err := http.ParseTime(lastModified); err == nil {
+					info.LastModified = lastModified_
+				}
+			}
+			return response.Body, nil
+		} else {
+			response.Body.Close()
+			return nil, fmt.Errorf("HTTP status: %s", response.Status)
+		}
 	} else {
-		return nil, nil, err
+		return nil, err
 	}
 }
 
@@ -91,10 +282,22 @@ func ReadURL(context contextpkg.Context, url exturl.URL, format string, forceFor
 // ARD [Map]. If you need to manipulate these maps you should use the
 // [yamlkeys] utility functions.
 func ReadYAML(reader io.Reader, locate bool) (Value, Locator, error) {
+	return ReadYAMLWithOptions(reader, locate, nil)
+}
+
+// Like [ReadYAML] but with [YAMLDecodeOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadYAMLWithOptions(reader io.Reader, locate bool, options *YAMLDecodeOptions) (Value, Locator, error) {
+	if options == nil {
+		options = new(YAMLDecodeOptions)
+	}
+
 	var node yaml.Node
 	decoder := yaml.NewDecoder(reader)
 	if err := decoder.Decode(&node); err == nil {
-		if value, err := yamlkeys.DecodeNode(&node); err == nil {
+		decoder := yamlNodeDecoder{options: options}
+		if value, err := decoder.decode(&node); err == nil {
 			var locator Locator
 			if locate {
 				locator = NewYAMLLocator(&node)
@@ -108,20 +311,86 @@ func ReadYAML(reader io.Reader, locate bool) (Value, Locator, error) {
 	}
 }
 
+// Reads all YAML documents (i.e. separated by `---`) from an [io.Reader] and
+// decodes them to ARD values.
 func ReadAllYAML(reader io.Reader) (List, error) {
 	return yamlkeys.DecodeAll(reader)
 }
 
 // Reads JSON from an [io.Reader] and decodes it to an ARD [Value].
+// Only the first JSON value is decoded, with the remainder ignored.
 //
 // If useStringMaps is true returns maps as [StringMap], otherwise they will
 // be [Map].
 func ReadJSON(reader io.Reader, useStringMaps bool) (Value, error) {
+	return ReadJSONWithOptions(reader, &JSONDecodeOptions{UseStringMaps: useStringMaps})
+}
+
+// Reads JSON from an [io.Reader] and decodes it to an ARD [Value].
+// Only the first JSON value is decoded, with the remainder ignored.
+//
+// If a [WarnFunc] is set (see [SetWarnFunc]) then ignored trailing data is
+// warned about, but only for readers that are known to be finite, e.g.
+// [bytes.Reader] and regular files, because checking for it requires reading
+// ahead, which would block on streams.
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadJSONWithOptions(reader io.Reader, options *JSONDecodeOptions) (Value, error) {
+	if options == nil {
+		options = new(JSONDecodeOptions)
+	}
+
+	source := reader
+	if options.UTF8 == UTF8Strict {
+		reader = newUTF8ValidatingReader(reader)
+	}
+
 	var value Value
-	decoder := json.NewDecoder(reader)
-	if err := decoder.Decode(&value); err == nil {
+	var err error
+	if (len(options.IncludePaths) > 0) || (len(options.ExcludePaths) > 0) {
+		producer := NewJSONEventProducerWithOptions(reader, &JSONEventProducerOptions{
+			IncludePaths: options.IncludePaths,
+			ExcludePaths: options.ExcludePaths,
+		})
+		if options.UseNumber || options.PreserveIntegers {
+			producer.decoder.UseNumber()
+		}
+		builder := NewValueBuilder(true)
+		if err = PipeEvents(producer, builder); err == nil {
+			// The root value itself might have been skipped, in which case
+			// there were no events
+			if builder.done {
+				value = builder.value
+			}
+			warnJSONTrailingData(producer.decoder, source)
+		}
+	} else {
+		decoder := getJSONAPI().NewDecoder(reader)
+		if options.UseNumber || options.PreserveIntegers {
+			decoder.UseNumber()
+		}
+		if err = decoder.Decode(&value); err == nil {
+			warnJSONTrailingData(decoder, source)
+		}
+	}
+
+	if err == nil {
+		if options.PreserveIntegers {
+			if value, err = preserveJSONIntegers(value, options.UseNumber, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		if options.SpecialFloatStrings {
+			value = decodeJSONSpecialFloats(value)
+		}
+
+		if options.InternKeys {
+			value = InternKeys(value)
+		}
+
 		// The JSON decoder uses StringMaps, not Maps
-		if !useStringMaps {
+		if !options.UseStringMaps {
 			value, _ = ConvertStringMapsToMaps(value)
 		}
 		return value, nil
@@ -130,6 +399,48 @@ func ReadJSON(reader io.Reader, useStringMaps bool) (Value, error) {
 	}
 }
 
+// Reads all JSON values from an [io.Reader] and decodes them to ARD values.
+//
+// When arrayWrapped is false, supports a stream of concatenated values,
+// optionally separated by whitespace (e.g. JSON Lines), each of which is an
+// element of the returned list. An empty input returns an empty list.
+//
+// When arrayWrapped is true, the input must be a single array wrapping the
+// values, and its elements are returned.
+//
+// If useStringMaps is true returns maps as [StringMap], otherwise they will
+// be [Map].
+func ReadAllJSON(reader io.Reader, arrayWrapped bool, useStringMaps bool) (List, error) {
+	list := make(List, 0)
+	decoder := getJSONAPI().NewDecoder(reader)
+	for {
+		var value Value
+		if err := decoder.Decode(&value); err == nil {
+			// The JSON decoder uses StringMaps, not Maps
+			if !useStringMaps {
+				value, _ = ConvertStringMapsToMaps(value)
+			}
+			list = append(list, value)
+		} else if err == io.EOF {
+			break
+		} else {
+			return nil, err
+		}
+	}
+
+	if arrayWrapped {
+		if len(list) != 1 {
+			return nil, fmt.Errorf("expected a single JSON array, found %d values", len(list))
+		} else if list_, ok := list[0].(List); ok {
+			return list_, nil
+		} else {
+			return nil, fmt.Errorf("expected a single JSON array, found %s", GetTypeName(list[0]))
+		}
+	}
+
+	return list, nil
+}
+
 // Reads JSON from an [io.Reader] and decodes it to an ARD [Value] while
 // interpreting the XJSON extensions.
 //
@@ -137,8 +448,27 @@ func ReadJSON(reader io.Reader, useStringMaps bool) (Value, error) {
 // be [Map].
 func ReadXJSON(reader io.Reader, useStringMaps bool) (Value, error) {
 	var value Value
-	decoder := json.NewDecoder(reader)
+	decoder := getJSONAPI().NewDecoder(reader)
+	if err := decoder.Decode(&value); err == nil {
+		warnJSONTrailingData(decoder, reader)
+
+		value, _ = UnpackXJSON(value, useStringMaps)
+		return value, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Like [ReadXJSON] but for minimal XJSON, in which JSON numbers without a
+// decimal point or exponent are decoded as integers. See [PackMinimalXJSON].
+func ReadMinimalXJSON(reader io.Reader, useStringMaps bool) (Value, error) {
+	var value Value
+	decoder := getJSONAPI().NewDecoder(reader)
+	decoder.UseNumber()
 	if err := decoder.Decode(&value); err == nil {
+		warnJSONTrailingData(decoder, reader)
+
+		value = ResolveJSONNumbers(value)
 		value, _ = UnpackXJSON(value, useStringMaps)
 		return value, nil
 	} else {
@@ -185,6 +515,34 @@ func ReadCBOR(reader io.Reader, base64 bool) (Value, error) {
 	}
 }
 
+// Reads a CBOR sequence (RFC 8742), i.e. concatenated CBOR data items, from
+// an [io.Reader] and decodes all of them to ARD values. An empty input
+// returns an empty list.
+//
+// If base64 is true then the data will first be fully read and decoded from
+// Base64 to bytes.
+func ReadAllCBOR(reader io.Reader, base64 bool) (List, error) {
+	if base64 {
+		var err error
+		if reader, err = readBase64(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make(List, 0)
+	decoder := cbor.NewDecoder(reader)
+	for {
+		var value Value
+		if err := decoder.Decode(&value); err == nil {
+			list = append(list, value)
+		} else if err == io.EOF {
+			return list, nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
 // Reads MessagePack from an [io.Reader] and decodes it to an ARD [Value].
 //
 // If base64 is true then the data will first be fully read and decoded from
@@ -192,7 +550,21 @@ func ReadCBOR(reader io.Reader, base64 bool) (Value, error) {
 //
 // If useStringMaps is true returns maps as [StringMap], otherwise they will
 // be [Map].
+//
+// See [ReadMessagePackWithOptions].
 func ReadMessagePack(reader io.Reader, base64 bool, useStringMaps bool) (Value, error) {
+	return ReadMessagePackWithOptions(reader, base64, &MessagePackDecodeOptions{UseStringMaps: useStringMaps})
+}
+
+// Like [ReadMessagePack] but with [MessagePackDecodeOptions].
+//
+// Decoding is lossless: bin is decoded as []byte, the timestamp extension as
+// [time.Time], maps with non-string (including complex) keys are supported,
+// and unsigned integers remain distinct from signed integers unless
+// configured otherwise.
+//
+// The options argument can be nil, in which case default options will be used.
+func ReadMessagePackWithOptions(reader io.Reader, base64 bool, options *MessagePackDecodeOptions) (Value, error) {
 	if base64 {
 		var err error
 		if reader, err = readBase64(reader); err != nil {
@@ -200,15 +572,52 @@ func ReadMessagePack(reader io.Reader, base64 bool, useStringMaps bool) (Value,
 		}
 	}
 
-	var value Value
-	decoder := NewMessagePackDecoder(reader)
-	if err := decoder.Decode(&value); err == nil {
-		// The MessagePack decoder uses StringMaps, not Maps
-		if !useStringMaps {
-			value, _ = ConvertStringMapsToMaps(value)
+	if options == nil {
+		options = new(MessagePackDecodeOptions)
+	}
+
+	var interner keyInterner
+	if options.InternKeys {
+		interner = make(keyInterner)
+	}
+
+	value, err := decodeMessagePack(NewMessagePackDecoder(reader), options, interner)
+	if (err == nil) && (options.UTF8 != UTF8Unchecked) {
+		value, err = CheckUTF8(value, options.UTF8)
+	}
+	return value, err
+}
+
+// Utils
+
+// Note that this will read ahead in the stream, but only if warnings are enabled
+// and the reader is known to be finite
+func warnJSONTrailingData(decoder JSONDecoder, reader io.Reader) {
+	if warn := getWarnFunc(); (warn != nil) && isFiniteReader(reader) {
+		if decoder.More() {
+			if decoder_, ok := decoder.(interface{ InputOffset() int64 }); ok {
+				warn("ignored trailing JSON data", "offset", decoder_.InputOffset())
+			} else {
+				warn("ignored trailing JSON data")
+			}
 		}
-		return value, nil
-	} else {
-		return nil, err
 	}
 }
+
+// Reading ahead in other readers could block, e.g. for stdin or network
+// connections
+func isFiniteReader(reader io.Reader) bool {
+	switch reader_ := reader.(type) {
+	case *bytes.Reader, *strings.Reader, *bytes.Buffer:
+		return true
+	case *os.File:
+		if stat, err := reader_.Stat(); err == nil {
+			return stat.Mode().IsRegular()
+		}
+	case *countingReader:
+		return isFiniteReader(reader_.reader)
+	case *utf8ValidatingReader:
+		return isFiniteReader(reader_.reader)
+	}
+	return false
+}
@@ -1,14 +1,20 @@
 package ard
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tliron/kutil/reflection"
 	"github.com/tliron/kutil/util"
+	"github.com/tliron/yamlkeys"
 )
 
 type StructFieldNameMapperFunc func(fieldName string) string
@@ -23,6 +29,93 @@ type ToARD interface {
 	ToARD(reflector *Reflector) (any, error)
 }
 
+// See [Reflector].UnpackFallback.
+type UnpackFallbackFunc func(value any, reflector *Reflector) (Value, error)
+
+//
+// ReflectorOption
+//
+
+// Functional option for [NewReflector] and [ConfigureDefaultReflector].
+type ReflectorOption func(reflector *Reflector)
+
+// Sets [Reflector].StructFieldTags.
+func WithTags(tags ...string) ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.StructFieldTags = tags
+	}
+}
+
+// Sets [Reflector].StructFieldNameMapper.
+func WithNameMapper(mapper StructFieldNameMapperFunc) ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.StructFieldNameMapper = mapper
+	}
+}
+
+// Sets [Reflector].NilMeansZero to true.
+func WithNilMeansZero() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.NilMeansZero = true
+	}
+}
+
+// Sets [Reflector].IgnoreMissingStructFields to true.
+func WithIgnoreMissingStructFields() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.IgnoreMissingStructFields = true
+	}
+}
+
+// Sets [Reflector].Lenient to true.
+func WithLenient() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.Lenient = true
+	}
+}
+
+// Sets [Reflector].Quantities to true.
+func WithQuantities() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.Quantities = true
+	}
+}
+
+// Sets [Reflector].StrictMapKeys to true.
+func WithStrictMapKeys() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.StrictMapKeys = true
+	}
+}
+
+// Sets [Reflector].CollectErrors to true.
+func WithCollectErrors() ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.CollectErrors = true
+	}
+}
+
+// Sets [Reflector].TimeLayouts.
+func WithTimeLayouts(layouts ...string) ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.TimeLayouts = layouts
+	}
+}
+
+// Sets [Reflector].UnpackFallback.
+func WithUnpackFallback(fallback UnpackFallbackFunc) ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.UnpackFallback = fallback
+	}
+}
+
+// Sets [Reflector].Warn.
+func WithWarn(warn WarnFunc) ReflectorOption {
+	return func(reflector *Reflector) {
+		reflector.Warn = warn
+	}
+}
+
 //
 // Reflector
 //
@@ -45,15 +138,169 @@ type Reflector struct {
 	// While StructFieldTags can be used to specify specific unpacked names, when
 	// untagged this function, if set, will be used for translating field names to
 	// their unpacked names.
+	//
+	// Call [Reflector.ClearCache] after changing it.
 	StructFieldNameMapper StructFieldNameMapperFunc
 
+	// When true, will convert between scalar representations when packing:
+	// strings to numbers (decimal only) and booleans (via [strconv] parsing),
+	// numbers and booleans to strings, booleans to numbers (1 and 0), and
+	// numbers to booleans (non-zero is true). Otherwise, will result in a
+	// packing error.
+	Lenient bool
+
+	// When true, strings with units will be parsed via [ParseQuantity] when
+	// packing into numbers (using the normalized value) and into
+	// [time.Duration] (only for duration quantities). Whole numbers are
+	// required for integer types. For [time.Duration], Go duration syntax
+	// (see [time.ParseDuration]) is attempted first, thus "5m" is 5 minutes.
+	Quantities bool
+
+	// When packing a map into a Go map with string keys, non-string keys are
+	// converted using [MapKeyToString]. When StrictMapKeys is true, they will
+	// instead result in a packing error.
+	//
+	// Note that string keys are always parsed when packing into a Go map with
+	// numeric or boolean keys, e.g. map[int]T.
+	StrictMapKeys bool
+
+	// When true, packing and [ValidCopy] will continue after errors where it is
+	// safe to do so, and return all errors at once as [Errors]. Otherwise, will
+	// stop at the first error. Each of the errors is a [*PathError] at the
+	// path of the value that failed (see [Errors.Paths]), which is left as
+	// nil or zero in the result, or omitted if it is a map entry.
+	//
+	// Encoders that use a reflector (e.g. [WriteJSON]) will likewise return
+	// all errors at once, but they will not write anything.
+	CollectErrors bool
+
+	// Layouts for packing strings into [time.Time], attempted in order after
+	// [time.RFC3339Nano] (which also accepts RFC 3339 without fractional
+	// seconds). See [time.Parse].
+	TimeLayouts []string
+
+	// If set, will be called with warnings, such as ignored missing struct fields
+	// and lossy number conversions during packing. Otherwise the package-wide
+	// function set by [SetWarnFunc] will be used, if set.
+	Warn WarnFunc
+
+	// If set, will be called when unpacking values of unsupported types, e.g.
+	// channels, functions, or unknown types stored in interface fields, and
+	// its result will be used instead of failing with an "unsupported type"
+	// error. The result is used as is, thus it should be ARD.
+	UnpackFallback UnpackFallbackFunc
+
 	reflectFieldsCache sync.Map
 }
 
 // Creates a reflector with default struct field tags:
-// "ard", "yaml", "json".
-func NewReflector() *Reflector {
-	return &Reflector{StructFieldTags: defaultStructFieldTags}
+// "ard", "yaml", "json". Options are applied in order.
+func NewReflector(options ...ReflectorOption) *Reflector {
+	reflector := &Reflector{StructFieldTags: defaultStructFieldTags}
+	for _, option := range options {
+		option(reflector)
+	}
+	return reflector
+}
+
+var defaultReflector atomic.Pointer[Reflector]
+var defaultReflectorLock sync.Mutex
+var formatDefaultReflectors atomic.Pointer[map[string]*Reflector]
+var formatDefaultReflectorsLock sync.Mutex
+
+// Sets the package-wide default [Reflector], which is used when a reflector
+// argument is nil, e.g. by [ValidCopy], [PrepareForEncodingXJSON], and
+// [Unmarshal]. This saves having to thread a configured reflector (e.g. with
+// custom StructFieldTags or NilMeansZero) through every call.
+//
+// Can be nil, which restores the default: a new reflector created via
+// [NewReflector] for each call.
+//
+// The reflector should not be modified after it is set, as it will be shared.
+//
+// This function is safe to call concurrently.
+func SetDefaultReflector(reflector *Reflector) {
+	defaultReflectorLock.Lock()
+	defer defaultReflectorLock.Unlock()
+
+	defaultReflector.Store(reflector)
+}
+
+// Configures the package-wide default [Reflector] (see [SetDefaultReflector])
+// by applying options, in order, to a copy of the current default (or to a
+// new reflector created via [NewReflector] if there is none). The copy then
+// replaces the default, so reflectors already in use are never modified.
+//
+// Intended to be called during initialization, e.g.:
+//
+//	ard.ConfigureDefaultReflector(ard.WithTags("json"), ard.WithNilMeansZero())
+//
+// This function is safe to call concurrently.
+func ConfigureDefaultReflector(options ...ReflectorOption) {
+	defaultReflectorLock.Lock()
+	defer defaultReflectorLock.Unlock()
+
+	var reflector *Reflector
+	if current := defaultReflector.Load(); current != nil {
+		reflector = current.clone()
+	} else {
+		reflector = NewReflector()
+	}
+
+	for _, option := range options {
+		option(reflector)
+	}
+
+	defaultReflector.Store(reflector)
+}
+
+// Like [SetDefaultReflector] but only for a specific format, e.g. "xjson" or
+// "xml". It takes precedence over the package-wide default reflector.
+//
+// Can be nil, which removes the format's default reflector.
+//
+// This function is safe to call concurrently.
+func SetFormatDefaultReflector(format string, reflector *Reflector) {
+	formatDefaultReflectorsLock.Lock()
+	defer formatDefaultReflectorsLock.Unlock()
+
+	reflectors := make(map[string]*Reflector)
+	if current := formatDefaultReflectors.Load(); current != nil {
+		for format_, reflector_ := range *current {
+			reflectors[format_] = reflector_
+		}
+	}
+
+	if reflector != nil {
+		reflectors[format] = reflector
+	} else {
+		delete(reflectors, format)
+	}
+
+	formatDefaultReflectors.Store(&reflectors)
+}
+
+// Returns the default [Reflector] for a format, as set by
+// [SetFormatDefaultReflector], falling back to the package-wide default as set
+// by [SetDefaultReflector], and then to a new reflector created via
+// [NewReflector]. The format can be empty, in which case only the package-wide
+// default is considered.
+//
+// This function is safe to call concurrently.
+func DefaultReflector(format string) *Reflector {
+	if format != "" {
+		if reflectors := formatDefaultReflectors.Load(); reflectors != nil {
+			if reflector, ok := (*reflectors)[format]; ok {
+				return reflector
+			}
+		}
+	}
+
+	if reflector := defaultReflector.Load(); reflector != nil {
+		return reflector
+	} else {
+		return NewReflector()
+	}
 }
 
 // Packs an ARD value into Go types, recursively.
@@ -66,11 +313,13 @@ func NewReflector() *Reflector {
 //
 // packedValuePtr must be a pointer.
 func (self *Reflector) Pack(value Value, packedValuePtr any) error {
-	packedValuePtr_ := reflect.ValueOf(packedValuePtr)
-	if packedValuePtr_.Kind() == reflect.Pointer {
-		return self.pack(nil, value, packedValuePtr_)
+	if metrics := getMetrics(); metrics != nil {
+		start := now()
+		err := self.packPointer(value, packedValuePtr)
+		metrics.Packed(since(start), err)
+		return err
 	} else {
-		return fmt.Errorf("target is not a pointer: %T", packedValuePtr)
+		return self.packPointer(value, packedValuePtr)
 	}
 }
 
@@ -81,11 +330,14 @@ func (self *Reflector) Pack(value Value, packedValuePtr any) error {
 // using [MapKeyToString].
 //
 // Structs can provide their own custom unpacking by implementing the
-// [ToARD] interface.
+// [ToARD] interface. It is honored at every level, including for values
+// nested in slices, maps, and interface fields, and for addressable values
+// whose pointer implements it. Types registered via [RegisterPrimitiveTypes]
+// or [RegisterScalarCodec] (including pointer types) are left as is.
 //
 // packedValuePtr must be a pointer.
 func (self *Reflector) Unpack(packedValue any) (Value, error) {
-	return self.unpack(nil, reflect.ValueOf(packedValue), false)
+	return self.unpackRoot(packedValue, false)
 }
 
 // Unpacks Go types to ARD, recursively. [StringMap] is used for Go
@@ -98,10 +350,51 @@ func (self *Reflector) Unpack(packedValue any) (Value, error) {
 //
 // packedValue can be a value or a pointer.
 func (self *Reflector) UnpackStringMaps(packedValue any) (Value, error) {
-	return self.unpack(nil, reflect.ValueOf(packedValue), true)
+	return self.unpackRoot(packedValue, true)
+}
+
+// Copies the configuration but not the cache
+func (self *Reflector) clone() *Reflector {
+	return &Reflector{
+		IgnoreMissingStructFields: self.IgnoreMissingStructFields,
+		NilMeansZero:              self.NilMeansZero,
+		StructFieldTags:           append([]string(nil), self.StructFieldTags...),
+		StructFieldNameMapper:     self.StructFieldNameMapper,
+		Lenient:                   self.Lenient,
+		Quantities:                self.Quantities,
+		StrictMapKeys:             self.StrictMapKeys,
+		CollectErrors:             self.CollectErrors,
+		TimeLayouts:               append([]string(nil), self.TimeLayouts...),
+		Warn:                      self.Warn,
+		UnpackFallback:            self.UnpackFallback,
+	}
+}
+
+func (self *Reflector) packPointer(value Value, packedValuePtr any) error {
+	packedValuePtr_ := reflect.ValueOf(packedValuePtr)
+	if packedValuePtr_.Kind() == reflect.Pointer {
+		return self.pack(nil, value, packedValuePtr_)
+	} else {
+		return fmt.Errorf("target is not a pointer: %T", packedValuePtr)
+	}
+}
+
+func (self *Reflector) unpackRoot(packedValue any, useStringMaps bool) (Value, error) {
+	if metrics := getMetrics(); metrics != nil {
+		start := now()
+		value, err := self.unpack(nil, reflect.ValueOf(packedValue), useStringMaps)
+		metrics.Unpacked(since(start), err)
+		return value, err
+	} else {
+		return self.unpack(nil, reflect.ValueOf(packedValue), useStringMaps)
+	}
 }
 
 func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) error {
+	if ok, err := self.packScalar(path, value, packedValue); ok {
+		return err
+	}
+
 	packedType := packedValue.Type()
 
 	// Dereference pointers
@@ -119,6 +412,10 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 		}
 
 		packedValue = packedValue.Elem()
+
+		if ok, err := self.packScalar(path, value, packedValue); ok {
+			return err
+		}
 	}
 
 	switch value_ := value.(type) {
@@ -128,25 +425,41 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 		} else {
 			kind := packedValue.Kind()
 			if (kind != reflect.Map) && (kind != reflect.Slice) {
-				return fmt.Errorf("%s is not a pointer, map, or slice: %s", path.String(), packedType.String())
+				return newPathErrorf(path, "is not a pointer, map, or slice: %s", packedType.String())
 			}
 		}
 
 	case string:
 		if packedValue.Kind() == reflect.String {
 			packedValue.SetString(value_)
+		} else if packedType == timeType {
+			if time_, err := self.parseTime(value_); err == nil {
+				packedValue.Set(reflect.ValueOf(time_))
+			} else {
+				return newPathErrorf(path, "is not a valid timestamp: %q", value_)
+			}
+		} else if ok, err := self.packQuantity(path, value_, packedValue); ok {
+			return err
+		} else if ok, err := self.packLenient(path, value_, packedValue); ok {
+			return err
 		} else {
-			return fmt.Errorf("%s is not a string: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a string: %s", packedType.String())
 		}
 
 	case bool:
 		if packedValue.Kind() == reflect.Bool {
 			packedValue.SetBool(value_)
+		} else if ok, err := self.packLenient(path, value_, packedValue); ok {
+			return err
 		} else {
-			return fmt.Errorf("%s is not a bool: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a bool: %s", packedType.String())
 		}
 
 	case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32:
+		if warn := self.getWarnFunc(); (warn != nil) && isLossyNumberPack(value_, packedValue) {
+			warn("lossy number conversion", "path", path.String(), "value", value_, "type", packedType.String())
+		}
+
 		if reflection.IsInteger(packedValue.Kind()) {
 			value__, _ := util.ToInt64(value_)
 			packedValue.SetInt(value__)
@@ -156,15 +469,26 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 		} else if reflection.IsFloat(packedValue.Kind()) {
 			value__, _ := util.ToFloat64(value_)
 			packedValue.SetFloat(value__)
+		} else if ok, err := self.packLenient(path, value_, packedValue); ok {
+			return err
 		} else {
-			return fmt.Errorf("%s is not a number: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a number: %s", packedType.String())
+		}
+
+	case json.Number:
+		if packedType == jsonNumberType {
+			packedValue.SetString(string(value_))
+		} else if number, err := ParseJSONNumber(value_); err == nil {
+			return self.pack(path, number, packedValue)
+		} else {
+			return newPathErrorf(path, "is not a valid number: %q", string(value_))
 		}
 
 	case []byte, time.Time: // as-is values
 		if packedType == reflect.TypeOf(value_) {
 			packedValue.Set(reflect.ValueOf(value_))
 		} else {
-			return fmt.Errorf("%s is not a %T: %s", path.String(), value, packedType.String())
+			return newPathErrorf(path, "is not a %T: %s", value, packedType.String())
 		}
 
 	case List:
@@ -172,14 +496,19 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 			elemType := packedType.Elem()
 			length := len(value_)
 			list := reflect.MakeSlice(reflect.SliceOf(elemType), length, length)
+			var errs Errors
 			for index, elem := range value_ {
 				if err := self.pack(path.AppendList(index), elem, list.Index(index)); err != nil {
-					return err
+					if !self.CollectErrors {
+						return err
+					}
+					errs = appendErrors(errs, err)
 				}
 			}
 			packedValue.Set(list)
+			return errs.orNil()
 		} else {
-			return fmt.Errorf("%s is not a slice: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a slice: %s", packedType.String())
 		}
 
 	case Map:
@@ -191,20 +520,26 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 
 			keyType := packedType.Key()
 			valueType := packedType.Elem()
+			var errs Errors
 			for k, v := range value_ {
 				k_ := reflect.New(keyType)
-				path_ := path.AppendMap(MapKeyToString(k_))
-				if err := self.pack(path_, k, k_); err == nil {
+				path_ := path.AppendKey(k)
+				if err := self.packMapKey(path_, k, k_.Elem()); err == nil {
 					v_ := reflect.New(valueType)
 					if err := self.pack(path_, v, v_); err == nil {
 						packedValue.SetMapIndex(k_.Elem(), v_.Elem())
+					} else if self.CollectErrors {
+						errs = appendErrors(errs, err)
 					} else {
-						return fmt.Errorf("map value for %s", err.Error())
+						return wrapPathError(err, "map value for ")
 					}
+				} else if self.CollectErrors {
+					errs = appendErrors(errs, err)
 				} else {
-					return fmt.Errorf("map key for %s", err.Error())
+					return err
 				}
 			}
+			return errs.orNil()
 
 		case reflect.Struct:
 			// Support FromARD interface
@@ -213,19 +548,24 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 					packedValue.Set(reflect.ValueOf(value__))
 					return nil
 				} else {
-					return err
+					return prependPathError(path, err)
 				}
 			}
 
 			reflectFields := self.newReflectFields(packedType)
+			var errs Errors
 			for k, v := range value_ {
 				if err := self.packStructField(path, packedValue, MapKeyToString(k), v, reflectFields); err != nil {
-					return err
+					if !self.CollectErrors {
+						return err
+					}
+					errs = appendErrors(errs, err)
 				}
 			}
+			return errs.orNil()
 
 		default:
-			return fmt.Errorf("%s is not a map or struct: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a map or struct: %s", packedType.String())
 		}
 
 	case StringMap:
@@ -237,20 +577,26 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 
 			keyType := packedType.Key()
 			valueType := packedType.Elem()
+			var errs Errors
 			for k, v := range value_ {
 				k_ := reflect.New(keyType)
-				path_ := path.AppendMap(MapKeyToString(k_))
-				if err := self.pack(path_, k, k_); err == nil {
+				path_ := path.AppendKey(k)
+				if err := self.packMapKey(path_, k, k_.Elem()); err == nil {
 					v_ := reflect.New(valueType)
 					if err := self.pack(path_, v, v_); err == nil {
 						packedValue.SetMapIndex(k_.Elem(), v_.Elem())
+					} else if self.CollectErrors {
+						errs = appendErrors(errs, err)
 					} else {
-						return fmt.Errorf("map value for %s", err.Error())
+						return wrapPathError(err, "map value for ")
 					}
+				} else if self.CollectErrors {
+					errs = appendErrors(errs, err)
 				} else {
-					return fmt.Errorf("map key for %s", err.Error())
+					return err
 				}
 			}
+			return errs.orNil()
 
 		case reflect.Struct:
 			if fromArd, ok := packedValue.Interface().(FromARD); ok {
@@ -258,76 +604,238 @@ func (self *Reflector) pack(path Path, value Value, packedValue reflect.Value) e
 					packedValue.Set(reflect.ValueOf(value__))
 					return nil
 				} else {
-					return err
+					return prependPathError(path, err)
 				}
 			}
 
 			reflectFields := self.newReflectFields(packedType)
+			var errs Errors
 			for k, v := range value_ {
 				if err := self.packStructField(path, packedValue, k, v, reflectFields); err != nil {
-					return err
+					if !self.CollectErrors {
+						return err
+					}
+					errs = appendErrors(errs, err)
 				}
 			}
+			return errs.orNil()
 
 		default:
-			return fmt.Errorf("%s is not a map or struct: %s", path.String(), packedType.String())
+			return newPathErrorf(path, "is not a map or struct: %s", packedType.String())
 		}
 
 	default:
-		return fmt.Errorf("%s is of unsupported type: %s", path.String(), packedType.String())
+		if type_ := reflect.TypeOf(value); isRegisteredPrimitiveType(type_) && type_.AssignableTo(packedType) {
+			packedValue.Set(reflect.ValueOf(value))
+		} else {
+			return newPathErrorf(path, "is of unsupported type: %s", packedType.String())
+		}
 	}
 
 	return nil
 }
 
+// Decodes strings and []byte for types with a [ScalarCodec]
+func (self *Reflector) packScalar(path Path, value Value, packedValue reflect.Value) (bool, error) {
+	switch value.(type) {
+	case string, []byte:
+		if codec, ok := getScalarCodecForType(packedValue.Type()); ok {
+			if value_, err := codec.decode(value); err == nil {
+				if type_ := reflect.TypeOf(value_); (type_ != nil) && type_.AssignableTo(packedValue.Type()) {
+					packedValue.Set(reflect.ValueOf(value_))
+					return true, nil
+				} else {
+					return true, newPathErrorf(path, "is not a valid %s: codec returned %T", codec.TypeName, value_)
+				}
+			} else {
+				return true, newPathErrorf(path, "is not a valid %s: %s", codec.TypeName, err.Error())
+			}
+		}
+	}
+
+	return false, nil
+}
+
 func (self *Reflector) packStructField(structPath Path, structValue reflect.Value, fieldName string, value Value, fieldNames reflectFields) error {
 	path := structPath.AppendField(fieldName)
 	field := fieldNames.getField(structValue, fieldName)
 
 	if !field.IsValid() {
 		if self.IgnoreMissingStructFields {
+			if warn := self.getWarnFunc(); warn != nil {
+				warn("ignored missing struct field", "path", path.String(), "type", structValue.Type().String())
+			}
 			return nil
 		} else {
-			return fmt.Errorf("%s does not exist", path.String())
+			return newPathErrorf(path, "does not exist")
 		}
 	}
 
 	if !field.CanSet() {
-		return fmt.Errorf("%s cannot be set", path.String())
+		return newPathErrorf(path, "cannot be set")
 	}
 
 	return self.pack(path, value, field)
 }
 
+func (self *Reflector) packMapKey(path Path, key Value, packedKey reflect.Value) error {
+	key = yamlkeys.KeyData(key)
+	kind := packedKey.Kind()
+
+	if key_, ok := key.(string); ok {
+		switch {
+		case kind == reflect.String:
+			packedKey.SetString(key_)
+			return nil
+
+		case (kind == reflect.Bool) || reflection.IsInteger(kind) || reflection.IsUInteger(kind) || reflection.IsFloat(kind):
+			// Parse numeric and boolean keys
+			if ok, err := packConvertedScalar(path, key_, packedKey); ok {
+				if err != nil {
+					return wrapPathError(err, "map key for ")
+				}
+				return nil
+			}
+		}
+	} else if (kind == reflect.String) && (key != nil) {
+		if self.StrictMapKeys {
+			return wrapPathError(newPathErrorf(path, "is not a string: %s", GetTypeName(key)), "map key for ")
+		}
+
+		packedKey.SetString(MapKeyToString(key))
+		return nil
+	}
+
+	if err := self.pack(path, key, packedKey); err == nil {
+		return nil
+	} else {
+		return wrapPathError(err, "map key for ")
+	}
+}
+
+// Returns false if lenient conversion is not enabled or not supported for the
+// target kind
+func (self *Reflector) packLenient(path Path, value Value, packedValue reflect.Value) (bool, error) {
+	if self.Lenient {
+		return packConvertedScalar(path, value, packedValue)
+	} else {
+		return false, nil
+	}
+}
+
+func (self *Reflector) packQuantity(path Path, value string, packedValue reflect.Value) (bool, error) {
+	if !self.Quantities {
+		return false, nil
+	}
+
+	kind := packedValue.Kind()
+	if !reflection.IsInteger(kind) && !reflection.IsUInteger(kind) && !reflection.IsFloat(kind) {
+		return false, nil
+	}
+
+	if packedValue.Type() == durationType {
+		// Go duration syntax first, because for quantities "m" is milli,
+		// e.g. "5m" would not be a duration
+		if duration, err := time.ParseDuration(value); err == nil {
+			packedValue.SetInt(int64(duration))
+			return true, nil
+		}
+	}
+
+	quantity, err := ParseQuantity(value)
+	if err != nil {
+		return true, newPathErrorf(path, "%s", err.Error())
+	}
+
+	if packedValue.Type() == durationType {
+		if duration, ok := quantity.Duration(); ok {
+			packedValue.SetInt(int64(duration))
+			return true, nil
+		} else {
+			return true, newPathErrorf(path, "is not a duration: %q", value)
+		}
+	}
+
+	if reflection.IsFloat(kind) {
+		packedValue.SetFloat(quantity.Value)
+		return true, nil
+	}
+
+	if integer, ok := quantity.Int64(); ok {
+		if reflection.IsInteger(kind) {
+			if !packedValue.OverflowInt(integer) {
+				packedValue.SetInt(integer)
+				return true, nil
+			}
+		} else if (integer >= 0) && !packedValue.OverflowUint(uint64(integer)) {
+			packedValue.SetUint(uint64(integer))
+			return true, nil
+		}
+	}
+
+	return true, newPathErrorf(path, "cannot convert quantity %q to %s", value, packedValue.Type().String())
+}
+
+func (self *Reflector) parseTime(value string) (time.Time, error) {
+	time_, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return time_, nil
+	}
+
+	for _, layout := range self.TimeLayouts {
+		if time_, err_ := time.Parse(layout, value); err_ == nil {
+			return time_, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// Returns nil if not set
+func (self *Reflector) getWarnFunc() WarnFunc {
+	if self.Warn != nil {
+		return self.Warn
+	} else {
+		return getWarnFunc()
+	}
+}
+
 var timeType = reflect.TypeFor[time.Time]()
+var durationType = reflect.TypeFor[time.Duration]()
 
 func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMaps bool) (Value, error) {
-	// Support ToARD interface
-	if toArd, ok := packedValue.Interface().(ToARD); ok {
-		return toArd.ToARD(self)
+	if !packedValue.IsValid() {
+		return nil, nil
 	}
 
-	packedType := packedValue.Type()
-	kind := packedType.Kind()
-
-	// Dereference pointers
-	for (kind == reflect.Pointer) || (kind == reflect.Interface) {
-		if packedValue.IsNil() {
+	// Dereference pointers and interfaces, checking for custom unpacking at
+	// every level
+	for {
+		kind := packedValue.Kind()
+		if ((kind == reflect.Pointer) || (kind == reflect.Interface)) && packedValue.IsNil() {
 			return nil, nil
 		}
 
-		packedValue = packedValue.Elem()
-
-		if toArd, ok := packedValue.Interface().(ToARD); ok {
-			return toArd.ToARD(self)
+		if value, ok, err := self.unpackCustom(packedValue); ok {
+			return value, err
 		}
 
-		packedType = packedValue.Type()
-		kind = packedType.Kind()
+		if (kind == reflect.Pointer) || (kind == reflect.Interface) {
+			packedValue = packedValue.Elem()
+		} else {
+			break
+		}
 	}
 
-	if packedType == timeType {
-		return packedValue.Interface(), nil
+	packedType := packedValue.Type()
+	kind := packedType.Kind()
+
+	if packedType == jsonNumberType {
+		if number, err := ParseJSONNumber(json.Number(packedValue.String())); err == nil {
+			return number, nil
+		} else {
+			return nil, newPathErrorf(path, "is not a valid number: %q", packedValue.String())
+		}
 	}
 
 	switch kind {
@@ -360,10 +868,10 @@ func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMap
 				if key_, err := self.unpack(path_, key, useStringMaps); err == nil {
 					value_ := packedValue.MapIndex(key)
 					if map_[MapKeyToString(key_)], err = self.unpack(path_, value_, useStringMaps); err != nil {
-						return nil, fmt.Errorf("map value for %s", err.Error())
+						return nil, wrapPathError(err, "map value for ")
 					}
 				} else {
-					return nil, fmt.Errorf("map key for %s", err.Error())
+					return nil, wrapPathError(err, "map key for ")
 				}
 			}
 			return map_, nil
@@ -374,11 +882,15 @@ func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMap
 				path_ := path.AppendMap(MapKeyToString(key.Interface()))
 				if key_, err := self.unpack(path_, key, useStringMaps); err == nil {
 					value_ := packedValue.MapIndex(key)
-					if map_[key_], err = self.unpack(path_, value_, useStringMaps); err != nil {
-						return nil, fmt.Errorf("map value for %s", err.Error())
+					if value__, err := self.unpack(path_, value_, useStringMaps); err == nil {
+						if err := putInMap(map_, key_, value__, path_); err != nil {
+							return nil, err
+						}
+					} else {
+						return nil, wrapPathError(err, "map value for ")
 					}
 				} else {
-					return nil, fmt.Errorf("map key for %s", err.Error())
+					return nil, wrapPathError(err, "map key for ")
 				}
 			}
 			return map_, nil
@@ -388,7 +900,7 @@ func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMap
 		if useStringMaps {
 			map_ := make(StringMap)
 			for name, field := range self.newReflectFields(packedType) {
-				value_ := packedValue.FieldByName(field.name)
+				value_ := getStructFieldValue(packedValue, field.name)
 				if value__, err := self.unpack(path.AppendField(field.name), value_, useStringMaps); err == nil {
 					if !field.omitEmpty || !reflection.IsEmpty(value__) {
 						map_[name] = value__
@@ -401,7 +913,7 @@ func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMap
 		} else {
 			map_ := make(Map)
 			for name, field := range self.newReflectFields(packedType) {
-				value_ := packedValue.FieldByName(field.name)
+				value_ := getStructFieldValue(packedValue, field.name)
 				if value__, err := self.unpack(path.AppendField(field.name), value_, useStringMaps); err == nil {
 					if !field.omitEmpty || !reflection.IsEmpty(value__) {
 						map_[name] = value__
@@ -414,8 +926,43 @@ func (self *Reflector) unpack(path Path, packedValue reflect.Value, useStringMap
 		}
 
 	default:
-		return nil, fmt.Errorf("%s is of unsupported type: %s", path.String(), packedType.String())
+		if self.UnpackFallback != nil {
+			if value, err := self.UnpackFallback(packedValue.Interface(), self); err == nil {
+				return value, nil
+			} else {
+				return nil, prependPathError(path, err)
+			}
+		}
+
+		return nil, newPathErrorf(path, "is of unsupported type: %s", packedType.String())
+	}
+}
+
+// Supports the ToARD interface (also via a pointer to an addressable value)
+// and registered primitive types
+func (self *Reflector) unpackCustom(packedValue reflect.Value) (Value, bool, error) {
+	if !packedValue.CanInterface() {
+		return nil, false, nil
+	}
+
+	value := packedValue.Interface()
+	if toArd, ok := value.(ToARD); ok {
+		value, err := toArd.ToARD(self)
+		return value, true, err
+	}
+
+	if packedValue.CanAddr() && (packedValue.Kind() != reflect.Pointer) && (packedValue.Kind() != reflect.Interface) {
+		if toArd, ok := packedValue.Addr().Interface().(ToARD); ok {
+			value, err := toArd.ToARD(self)
+			return value, true, err
+		}
+	}
+
+	if type_ := packedValue.Type(); (type_ == timeType) || isRegisteredPrimitiveType(type_) {
+		return value, true, nil
 	}
+
+	return nil, false, nil
 }
 
 //
@@ -429,14 +976,64 @@ type reflectField struct {
 
 type reflectFields map[string]reflectField // key is user-defined name in tag
 
+// Pre-computes and caches struct field information for the types so that it
+// does not have to happen during the first packing or unpacking. Useful for
+// warming up at startup.
+//
+// Arguments can be values of the types, pointers to them, or [reflect.Type].
+// Returns an error if a type is not a struct.
+func (self *Reflector) Register(types ...any) error {
+	for _, type_ := range types {
+		type__, ok := type_.(reflect.Type)
+		if !ok {
+			type__ = reflect.TypeOf(type_)
+		}
+
+		for (type__ != nil) && (type__.Kind() == reflect.Pointer) {
+			type__ = type__.Elem()
+		}
+
+		if (type__ == nil) || (type__.Kind() != reflect.Struct) {
+			return fmt.Errorf("not a struct: %T", type_)
+		}
+
+		self.newReflectFields(type__)
+	}
+
+	return nil
+}
+
+// Clears the cache of struct field information.
+//
+// Note that the cache is keyed by the StructFieldTags configuration, so it is
+// not necessary to clear it when changing them. However, it must be cleared
+// when changing StructFieldNameMapper, because functions cannot be reliably
+// compared (e.g. closures created from the same function literal).
+func (self *Reflector) ClearCache() {
+	self.reflectFieldsCache.Range(func(key any, value any) bool {
+		self.reflectFieldsCache.Delete(key)
+		return true
+	})
+}
+
+type reflectFieldsCacheKey struct {
+	type_ reflect.Type
+	tags  string
+}
+
 func (self *Reflector) newReflectFields(type_ reflect.Type) reflectFields {
-	if reflectFields_, ok := self.reflectFieldsCache.Load(type_); ok {
+	cacheKey := reflectFieldsCacheKey{
+		type_: type_,
+		tags:  strings.Join(self.StructFieldTags, ","),
+	}
+
+	if reflectFields_, ok := self.reflectFieldsCache.Load(cacheKey); ok {
 		return reflectFields_.(reflectFields)
 	}
 
 	reflectFields_ := make(reflectFields)
 
-	for _, structField := range reflection.GetStructFields(type_) {
+	for _, structField := range getStructFields(type_) {
 		reflectField := reflectField{name: structField.Name}
 
 		// Try tags in order
@@ -468,7 +1065,7 @@ func (self *Reflector) newReflectFields(type_ reflect.Type) reflectFields {
 		}
 	}
 
-	self.reflectFieldsCache.Store(type_, reflectFields_)
+	self.reflectFieldsCache.Store(cacheKey, reflectFields_)
 
 	return reflectFields_
 }
@@ -476,3 +1073,195 @@ func (self *Reflector) newReflectFields(type_ reflect.Type) reflectFields {
 func (self reflectFields) getField(structValue reflect.Value, name string) reflect.Value {
 	return structValue.FieldByName(self[name].name)
 }
+
+// Utils
+
+// Returns true if packing the number into the value would lose information,
+// e.g. due to overflow or truncation.
+func isLossyNumberPack(value Value, packedValue reflect.Value) bool {
+	number := toNumber(value)
+	kind := packedValue.Kind()
+
+	switch {
+	case reflection.IsInteger(kind):
+		switch number.kind {
+		case signedKind:
+			return packedValue.OverflowInt(number.signed)
+		case unsignedKind:
+			return (number.unsigned > math.MaxInt64) || packedValue.OverflowInt(int64(number.unsigned))
+		default:
+			float := number.float
+			return (float != math.Trunc(float)) || (float < math.MinInt64) || (float >= math.MaxInt64) || packedValue.OverflowInt(int64(float))
+		}
+
+	case reflection.IsUInteger(kind):
+		switch number.kind {
+		case signedKind:
+			return (number.signed < 0) || packedValue.OverflowUint(uint64(number.signed))
+		case unsignedKind:
+			return packedValue.OverflowUint(number.unsigned)
+		default:
+			float := number.float
+			return (float != math.Trunc(float)) || (float < 0) || (float >= math.MaxUint64) || packedValue.OverflowUint(uint64(float))
+		}
+
+	case reflection.IsFloat(kind):
+		var float float64
+		switch number.kind {
+		case signedKind:
+			var accuracy big.Accuracy
+			if float, accuracy = new(big.Float).SetInt64(number.signed).Float64(); accuracy != big.Exact {
+				return true
+			}
+		case unsignedKind:
+			var accuracy big.Accuracy
+			if float, accuracy = new(big.Float).SetUint64(number.unsigned).Float64(); accuracy != big.Exact {
+				return true
+			}
+		default:
+			float = number.float
+		}
+
+		if kind == reflect.Float32 {
+			return !math.IsNaN(float) && !math.IsInf(float, 0) && (float64(float32(float)) != float)
+		}
+	}
+
+	return false
+}
+
+// Returns false if conversion is not supported for the value and target kind
+func packConvertedScalar(path Path, value Value, packedValue reflect.Value) (bool, error) {
+	kind := packedValue.Kind()
+
+	switch value_ := value.(type) {
+	case string:
+		var err error
+		switch {
+		case kind == reflect.Bool:
+			var boolean bool
+			if boolean, err = strconv.ParseBool(strings.TrimSpace(value_)); err == nil {
+				packedValue.SetBool(boolean)
+				return true, nil
+			}
+
+		case reflection.IsInteger(kind):
+			var integer int64
+			if integer, err = strconv.ParseInt(strings.TrimSpace(value_), 10, packedValue.Type().Bits()); err == nil {
+				packedValue.SetInt(integer)
+				return true, nil
+			}
+
+		case reflection.IsUInteger(kind):
+			var uinteger uint64
+			if uinteger, err = strconv.ParseUint(strings.TrimSpace(value_), 10, packedValue.Type().Bits()); err == nil {
+				packedValue.SetUint(uinteger)
+				return true, nil
+			}
+
+		case reflection.IsFloat(kind):
+			var float float64
+			if float, err = strconv.ParseFloat(strings.TrimSpace(value_), packedValue.Type().Bits()); err == nil {
+				packedValue.SetFloat(float)
+				return true, nil
+			}
+
+		default:
+			return false, nil
+		}
+
+		if numError, ok := err.(*strconv.NumError); ok {
+			err = numError.Err
+		}
+		return true, newPathErrorf(path, "cannot convert %q to %s: %s", value_, packedValue.Type().String(), err.Error())
+
+	case bool:
+		switch {
+		case kind == reflect.String:
+			packedValue.SetString(strconv.FormatBool(value_))
+			return true, nil
+
+		case reflection.IsInteger(kind):
+			packedValue.SetInt(boolToInt(value_))
+			return true, nil
+
+		case reflection.IsUInteger(kind):
+			packedValue.SetUint(uint64(boolToInt(value_)))
+			return true, nil
+
+		case reflection.IsFloat(kind):
+			packedValue.SetFloat(float64(boolToInt(value_)))
+			return true, nil
+		}
+
+	default:
+		// Numbers
+		switch kind {
+		case reflect.String:
+			packedValue.SetString(ValueToString(value_))
+			return true, nil
+
+		case reflect.Bool:
+			packedValue.SetBool(toNumber(value_).toFloat() != 0)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func boolToInt(value bool) int64 {
+	if value {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// Like [reflection.GetStructFields] but embedded fields that are not structs
+// (e.g. interfaces) are treated as regular fields
+func getStructFields(type_ reflect.Type) []reflect.StructField {
+	var structFields []reflect.StructField
+	length := type_.NumField()
+	for index := 0; index < length; index++ {
+		structField := type_.Field(index)
+		if structField.Anonymous {
+			embedded := structField.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for _, structField = range getStructFields(embedded) {
+					structFields = appendStructField(structFields, structField)
+				}
+				continue
+			}
+		}
+
+		if structField.IsExported() {
+			structFields = appendStructField(structFields, structField)
+		}
+	}
+	return structFields
+}
+
+// Fields of embedded structs override fields with the same name
+func appendStructField(structFields []reflect.StructField, structField reflect.StructField) []reflect.StructField {
+	for index, structField_ := range structFields {
+		if structField_.Name == structField.Name {
+			structFields[index] = structField
+			return structFields
+		}
+	}
+	return append(structFields, structField)
+}
+
+// Returns an invalid value if the field is in a nil embedded struct pointer
+func getStructFieldValue(structValue reflect.Value, name string) reflect.Value {
+	if structField, ok := structValue.Type().FieldByName(name); ok {
+		if value, err := structValue.FieldByIndexErr(structField.Index); err == nil {
+			return value
+		}
+	}
+	return reflect.Value{}
+}
@@ -2,119 +2,137 @@ package ard
 
 import (
 	"bytes"
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
-	"strings"
-
-	"github.com/fxamacker/cbor/v2"
-	"gopkg.in/yaml.v3"
+	"io"
 )
 
-// Encodes and then decodes the value via a supported format.
 //
-// Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
+// RoundtripOptions
 //
-// While this function can be used to "canonicalize" values to ARD, it is
-// generally be more efficient to call [ValidCopy] instead.
-func Roundtrip(value Value, format string, reflector *Reflector) (Value, error) {
-	switch format {
-	case "yaml":
-		return RoundtripYAML(value)
 
-	case "json":
-		return RoundtripJSON(value)
+type RoundtripOptions struct {
+	// The map type of the result. Defaults to [AnyKeyedMaps], as with [Read].
+	MapMode MapMode
 
-	case "xjson":
-		return RoundtripXJSON(value, reflector)
+	// Used for encoding. If nil then the default reflector for the format
+	// will be used (see [DefaultReflector]).
+	Reflector *Reflector
 
-	case "xml":
-		return RoundtripXML(value, reflector)
+	// For "json" and "xjson", sorts map keys according to [Compare] when
+	// encoding (see [JSONEncodeOptions]). Other formats ignore it, as their
+	// output is already sorted (e.g. [WriteYAML]) or unordered.
+	SortKeys bool
 
-	case "cbor":
-		return RoundtripCBOR(value)
+	// For "cbor" and "messagepack", encodes through Base64 (see
+	// [CBOREncodeOptions] and [MessagePackEncodeOptions]).
+	Base64 bool
+}
 
-	case "messagepack":
-		return RoundtripMessagePack(value)
+// Encodes and then decodes the value via a supported format (see
+// [SupportedFormats]).
+//
+// The value is encoded as by [Write] and decoded as by [Read], so the result
+// is exactly what would be read back after writing. As with [Read], all
+// resulting maps are guaranteed to be [Map] (and not [StringMap]). Use
+// [RoundtripWithOptions] or [Codec.Roundtrip] to choose the map type.
+//
+// While this function can be used to "canonicalize" values to ARD, it is
+// generally be more efficient to call [ValidCopy] instead.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func Roundtrip(value Value, format string, reflector *Reflector) (Value, error) {
+	return RoundtripWithOptions(value, format, &RoundtripOptions{Reflector: reflector})
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported format: %q", format)
+// Like [Roundtrip] but with [RoundtripOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func RoundtripWithOptions(value Value, format string, options *RoundtripOptions) (Value, error) {
+	if options == nil {
+		options = new(RoundtripOptions)
 	}
-}
 
-func RoundtripYAML(value Value) (Value, error) {
-	var builder strings.Builder
-	encoder := yaml.NewEncoder(&builder)
-	if err := encoder.Encode(value); err == nil {
-		value_, _, err := ReadYAML(strings.NewReader(builder.String()), false)
-		return value_, err
-	} else {
+	var buffer bytes.Buffer
+	if err := writeForRoundtrip(value, &buffer, format, options); err != nil {
 		return nil, err
 	}
-}
 
-func RoundtripJSON(value Value) (Value, error) {
-	var builder strings.Builder
-	encoder := json.NewEncoder(&builder)
-	if err := encoder.Encode(value); err == nil {
-		return ReadJSON(strings.NewReader(builder.String()), true)
+	if value_, err := readForRoundtrip(&buffer, format, options); err == nil {
+		return options.MapMode.Convert(value_), nil
 	} else {
 		return nil, err
 	}
 }
 
+// Like [Roundtrip] for "yaml".
+func RoundtripYAML(value Value) (Value, error) {
+	return Roundtrip(value, "yaml", nil)
+}
+
+// Like [Roundtrip] for "json".
+func RoundtripJSON(value Value) (Value, error) {
+	return Roundtrip(value, "json", nil)
+}
+
+// Like [Roundtrip] for "xjson".
 func RoundtripXJSON(value Value, reflector *Reflector) (Value, error) {
-	if value_, err := PrepareForEncodingXJSON(value, false, reflector); err == nil {
-		var builder strings.Builder
-		encoder := json.NewEncoder(&builder)
-		if err := encoder.Encode(value_); err == nil {
-			return ReadXJSON(strings.NewReader(builder.String()), true)
-		} else {
-			return nil, err
-		}
-	} else {
-		return nil, err
-	}
+	return Roundtrip(value, "xjson", reflector)
 }
 
+// Like [Roundtrip] for "xml".
 func RoundtripXML(value Value, reflector *Reflector) (Value, error) {
-	if value_, err := PrepareForEncodingXML(value, false, reflector); err == nil {
-		var writer strings.Builder
-		if _, err := writer.WriteString(xml.Header); err == nil {
-			encoder := xml.NewEncoder(&writer)
-			encoder.Indent("", "")
-			if err := encoder.Encode(value_); err == nil {
-				return ReadXML(strings.NewReader(writer.String()))
-			} else {
-				return nil, err
-			}
-		} else {
-			return nil, err
-		}
-	} else {
-		return nil, err
-	}
+	return Roundtrip(value, "xml", reflector)
 }
 
+// Like [Roundtrip] for "cbor".
 func RoundtripCBOR(value Value) (Value, error) {
-	if bytes, err := cbor.Marshal(value); err == nil {
-		var value_ Value
-		if err := cbor.Unmarshal(bytes, &value_); err == nil {
-			return value_, nil
-		} else {
-			return nil, err
+	return Roundtrip(value, "cbor", nil)
+}
+
+// Like [Roundtrip] for "messagepack".
+func RoundtripMessagePack(value Value) (Value, error) {
+	return Roundtrip(value, "messagepack", nil)
+}
+
+// Utils
+
+func writeForRoundtrip(value Value, writer io.Writer, format string, options *RoundtripOptions) error {
+	switch format {
+	case "json":
+		if options.SortKeys {
+			return WriteJSONWithOptions(value, writer, options.Reflector, &JSONEncodeOptions{SortKeys: true})
+		}
+
+	case "xjson":
+		if options.SortKeys {
+			return WriteXJSONWithOptions(value, writer, options.Reflector, &JSONEncodeOptions{SortKeys: true})
+		}
+
+	case "cbor":
+		if options.Base64 {
+			return WriteCBORWithOptions(value, writer, &CBOREncodeOptions{Base64: true})
+		}
+
+	case "messagepack":
+		if options.Base64 {
+			return WriteMessagePackWithOptions(value, writer, &MessagePackEncodeOptions{Base64: true})
 		}
-	} else {
-		return nil, err
 	}
+
+	return Write(value, writer, format, options.Reflector)
 }
 
-func RoundtripMessagePack(value Value) (Value, error) {
-	var buffer bytes.Buffer
-	encoder := NewMessagePackEncoder(&buffer)
-	if err := encoder.Encode(value); err == nil {
-		return ReadMessagePack(&buffer, false, true)
-	} else {
-		return nil, err
+func readForRoundtrip(reader io.Reader, format string, options *RoundtripOptions) (Value, error) {
+	if options.Base64 {
+		switch format {
+		case "cbor":
+			return ReadCBOR(reader, true)
+
+		case "messagepack":
+			return ReadMessagePack(reader, true, false)
+		}
 	}
+
+	value, _, err := Read(reader, format, false)
+	return value, err
 }
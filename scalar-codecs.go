@@ -0,0 +1,229 @@
+package ard
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// ScalarCodec
+//
+
+// Converts values of a custom scalar type, e.g. uuid.UUID, *big.Rat, or
+// [net/netip.Addr], to and from their representations. See
+// [RegisterScalarCodec].
+type ScalarCodec struct {
+	// Identifies the type in encoded data, e.g. "uuid". Used for YAML tags
+	// (prefixed with "!") and for XJSON codes. Also returned by
+	// [GetTypeName].
+	TypeName TypeName
+
+	// Required.
+	ToString func(value Value) string
+
+	// Required. Must return a value of the registered type.
+	FromString func(string_ string) (Value, error)
+
+	// Optional. Used for binary formats (CBOR and MessagePack). If nil then
+	// ToString will be used instead.
+	ToBinary func(value Value) []byte
+
+	// Optional. Used by [Reflector.Pack] for []byte values. Must return a value
+	// of the registered type.
+	FromBinary func(bytes []byte) (Value, error)
+}
+
+func (self *ScalarCodec) encode(value Value, binary bool) Value {
+	if binary && (self.ToBinary != nil) {
+		return self.ToBinary(value)
+	} else {
+		return self.ToString(value)
+	}
+}
+
+func (self *ScalarCodec) decode(value Value) (Value, error) {
+	switch value_ := value.(type) {
+	case string:
+		return self.FromString(value_)
+
+	case []byte:
+		if self.FromBinary != nil {
+			return self.FromBinary(value_)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot decode %s from %s", self.TypeName, GetTypeName(value))
+}
+
+var scalarCodecs atomic.Pointer[scalarCodecRegistry]
+var scalarCodecsLock sync.Mutex
+
+// Registers a [ScalarCodec] for the type of an example value. This is a single
+// extension point for custom scalar types that is shared by all formats:
+//
+//   - The type is registered via [RegisterPrimitiveTypes], so that values are
+//     left as is by [ValidCopy] and [Reflector.Unpack].
+//   - [Reflector.Pack] decodes strings and []byte into fields of the type.
+//   - [GetTypeName] returns the codec's TypeName and [ValueToString] uses its
+//     ToString.
+//   - YAML encodes values as strings tagged with "!" and the TypeName, e.g.
+//     "!uuid", and decodes such tagged strings back to values.
+//   - XJSON encodes values as {"$ard.scalar": {"type": ..., "value": ...}}
+//     and decodes them back to values.
+//   - JSON and XML encode values as strings, and CBOR and MessagePack as
+//     []byte (or strings if there is no ToBinary). They cannot be decoded
+//     back to values by the format, but can be by [Reflector.Pack].
+//
+// Registering a codec for a type or TypeName that is already registered
+// replaces it.
+//
+// This function is safe to call concurrently.
+func RegisterScalarCodec(example any, codec ScalarCodec) error {
+	if example == nil {
+		return fmt.Errorf("scalar codec example is nil: %s", codec.TypeName)
+	}
+	if codec.TypeName == "" {
+		return fmt.Errorf("scalar codec for %T has no type name", example)
+	}
+	if (codec.ToString == nil) || (codec.FromString == nil) {
+		return fmt.Errorf("scalar codec for %T must have ToString and FromString", example)
+	}
+
+	scalarCodecsLock.Lock()
+	defer scalarCodecsLock.Unlock()
+
+	exampleType := reflect.TypeOf(example)
+
+	registry := scalarCodecRegistry{
+		byType: make(map[reflect.Type]*ScalarCodec),
+		byName: make(map[TypeName]*ScalarCodec),
+	}
+	if current := scalarCodecs.Load(); current != nil {
+		for type_, codec_ := range current.byType {
+			if (type_ != exampleType) && (codec_.TypeName != codec.TypeName) {
+				registry.byType[type_] = codec_
+				registry.byName[codec_.TypeName] = codec_
+			}
+		}
+	}
+
+	registry.byType[exampleType] = &codec
+	registry.byName[codec.TypeName] = &codec
+	scalarCodecs.Store(&registry)
+
+	RegisterPrimitiveTypes(example)
+	return nil
+}
+
+//
+// XJSONScalar
+//
+
+const XJSONScalarCode = "$ard.scalar"
+
+type XJSONScalar struct {
+	TypeName TypeName
+	Value    string
+}
+
+// ([json.Marshaler] interface)
+func (self XJSONScalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(StringMap{
+		XJSONScalarCode: StringMap{
+			"type":  self.TypeName,
+			"value": self.Value,
+		},
+	})
+}
+
+// Requires the type to have been registered via [RegisterScalarCodec].
+func UnpackXJSONScalar(code StringMap) (Value, bool) {
+	if scalar, ok := code[XJSONScalarCode]; ok {
+		if scalar_, ok := scalar.(StringMap); ok {
+			if typeName, ok := scalar_["type"].(string); ok {
+				if value, ok := scalar_["value"].(string); ok {
+					if codec, ok := getScalarCodecByName(TypeName(typeName)); ok {
+						if value_, err := codec.FromString(value); err == nil {
+							return value_, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// Utils
+
+type scalarCodecRegistry struct {
+	byType map[reflect.Type]*ScalarCodec
+	byName map[TypeName]*ScalarCodec
+}
+
+func hasScalarCodecs() bool {
+	return scalarCodecs.Load() != nil
+}
+
+func getScalarCodec(value Value) (*ScalarCodec, bool) {
+	if value != nil {
+		return getScalarCodecForType(reflect.TypeOf(value))
+	}
+	return nil, false
+}
+
+func getScalarCodecForType(type_ reflect.Type) (*ScalarCodec, bool) {
+	if registry := scalarCodecs.Load(); registry != nil {
+		codec, ok := registry.byType[type_]
+		return codec, ok
+	}
+	return nil, false
+}
+
+func getScalarCodecByName(name TypeName) (*ScalarCodec, bool) {
+	if registry := scalarCodecs.Load(); registry != nil {
+		codec, ok := registry.byName[name]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// For YAML tags, e.g. "!uuid"
+func getScalarCodecByTag(tag string) (*ScalarCodec, bool) {
+	if name, ok := strings.CutPrefix(tag, "!"); ok {
+		return getScalarCodecByName(TypeName(name))
+	}
+	return nil, false
+}
+
+// Replaces registered scalars with their representations, in place when
+// possible
+func encodeScalars(value Value, binary bool) Value {
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			value_[key] = encodeScalars(element, binary)
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = encodeScalars(element, binary)
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = encodeScalars(element, binary)
+		}
+
+	default:
+		if codec, ok := getScalarCodec(value); ok {
+			return codec.encode(value, binary)
+		}
+	}
+
+	return value
+}
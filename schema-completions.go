@@ -0,0 +1,131 @@
+package ard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Exports completion metadata for a [Schema] as an ARD [StringMap] that can
+// be encoded (e.g. via [Write]) for use by editors and CLIs.
+//
+// Keys are paths in [Path.String] format (including wildcards), with the
+// empty string for the root. Values are [StringMap] with these optional
+// entries:
+//
+//   - "type": the expected [TypeName] as a string, either declared in the
+//     schema or implied by the children ("ard.map" or "ard.list")
+//   - "keys": a sorted [List] of the valid child keys (may include "*")
+//   - "indexed": true if list elements ("[*]" or "[n]") are described
+//
+// Note that [Schema] does not currently support enumerations, so none are
+// exported.
+func ExportCompletions(schema Schema) (StringMap, error) {
+	completions := make(StringMap)
+
+	get := func(path string) StringMap {
+		if completion, ok := completions[path]; ok {
+			return completion.(StringMap)
+		}
+		completion := make(StringMap)
+		completions[path] = completion
+		return completion
+	}
+
+	for pattern, type_ := range schema {
+		path, err := parseSchemaPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		get(schemaPatternString(path))["type"] = string(type_)
+
+		for length := len(path); length > 0; length-- {
+			element := path[length-1]
+			parent := get(schemaPatternString(path[:length-1]))
+
+			switch element.Type {
+			case ListPathType, SequencedListPathType:
+				parent["indexed"] = true
+				if _, ok := parent["type"]; !ok {
+					parent["type"] = string(TypeList)
+				}
+
+			default:
+				var key string
+				if element.Type == FieldPathType {
+					key = element.Value.(string)
+				} else {
+					key = ValueToString(element.Value)
+				}
+
+				keys, _ := parent["keys"].(List)
+				if !containsKey(keys, key) {
+					parent["keys"] = append(keys, key)
+				}
+				if _, ok := parent["type"]; !ok {
+					parent["type"] = string(TypeMap)
+				}
+			}
+		}
+	}
+
+	for _, completion := range completions {
+		if keys, ok := completion.(StringMap)["keys"].(List); ok {
+			sort.Slice(keys, func(i int, j int) bool {
+				return keys[i].(string) < keys[j].(string)
+			})
+		}
+	}
+
+	return completions, nil
+}
+
+// Utils
+
+// List wildcards ("[*]") are represented as list elements with index -1
+func parseSchemaPattern(pattern string) (Path, error) {
+	if path, err := ParsePath(strings.ReplaceAll(pattern, "[*]", "[-1]")); err == nil {
+		return path, nil
+	} else {
+		return nil, fmt.Errorf("malformed schema path %q: %w", pattern, err)
+	}
+}
+
+func schemaPatternString(path Path) string {
+	return strings.ReplaceAll(path.String(), "[-1]", "[*]")
+}
+
+// Non-string map keys are quoted, such that integer keys are not confused
+// with list indexes
+func schemaKeyPathElement(key Value) PathElement {
+	element := NewKeyPathElement(key)
+	if element.Type == KeyPathType {
+		element = NewMapPathElement(MapKeyToString(key))
+	}
+	return element
+}
+
+// Returns nil if the path has no non-string map keys
+func schemaKeyPath(path Path) Path {
+	var path_ Path
+	for index, element := range path {
+		if element.Type == KeyPathType {
+			if path_ == nil {
+				path_ = make(Path, len(path))
+				copy(path_, path)
+			}
+			path_[index] = NewMapPathElement(MapKeyToString(element.Value))
+		}
+	}
+	return path_
+}
+
+func containsKey(keys List, key string) bool {
+	for _, key_ := range keys {
+		if key_ == key {
+			return true
+		}
+	}
+	return false
+}
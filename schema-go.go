@@ -0,0 +1,315 @@
+package ard
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//
+// GoGeneratorOptions
+//
+
+type GoGeneratorOptions struct {
+	// Package name for the generated code. Defaults to "main".
+	Package string
+
+	// Name of the root type. Defaults to "Root".
+	TypeName string
+
+	// Struct field tag. Defaults to "ard". Note that it must be one of the
+	// [Reflector].StructFieldTags in order to be used for packing and
+	// unpacking.
+	Tag string
+}
+
+// Generates formatted Go source code with type definitions matching a
+// [Schema], for use with [Reflector.Pack] and [Reflector.Unpack]. This allows
+// keeping structs and schemas in sync. Use [InferSchema] to generate code
+// from an example value.
+//
+// Maps with keys are generated as structs, with exported field names derived
+// from the keys and with the original keys in struct field tags. Nested
+// structs are named by appending their field names to their containing
+// struct's name, with "Item" appended for list elements and map wildcard
+// ("*") values. Maps with only a wildcard are generated as map[string]T. Maps
+// with both keys and a wildcard cannot be represented and are an error.
+//
+// Semantic string types (e.g. [TypeIP]) are generated as string. Nulls,
+// untyped maps, and untyped lists are generated as any, map[string]any, and
+// []any respectively.
+//
+// Specific list indexes in the schema are treated as the list wildcard
+// ("[*]").
+//
+// The options argument can be nil, in which case default options will be used.
+func GenerateGo(schema Schema, options *GoGeneratorOptions) ([]byte, error) {
+	var options_ GoGeneratorOptions
+	if options != nil {
+		options_ = *options
+	}
+	if options_.Package == "" {
+		options_.Package = "main"
+	}
+	if options_.TypeName == "" {
+		options_.TypeName = "Root"
+	}
+	if options_.Tag == "" {
+		options_.Tag = "ard"
+	}
+
+	root := new(goSchemaNode)
+
+	patterns := make([]string, 0, len(schema))
+	for pattern := range schema {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		path, err := parseSchemaPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		node := root
+		for _, element := range path {
+			node = node.child(element)
+		}
+		node.type_ = schema[pattern]
+	}
+
+	generator := goGenerator{
+		options: &options_,
+		names:   make(map[string]struct{}),
+	}
+
+	rootType, err := generator.goType(root, options_.TypeName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	if rootType != options_.TypeName {
+		fmt.Fprintf(&body, "type %s %s\n\n", options_.TypeName, rootType)
+	}
+
+	// Note that more structs may be queued while we are writing
+	for index := 0; index < len(generator.structs); index++ {
+		if err := generator.writeStruct(&body, generator.structs[index]); err != nil {
+			return nil, err
+		}
+	}
+
+	var code strings.Builder
+	code.WriteString("// Code generated by go-ard. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&code, "package %s\n\n", options_.Package)
+	if generator.importTime {
+		code.WriteString("import \"time\"\n\n")
+	}
+	code.WriteString(body.String())
+
+	if source, err := format.Source([]byte(code.String())); err == nil {
+		return source, nil
+	} else {
+		return nil, fmt.Errorf("generated malformed Go code: %w", err)
+	}
+}
+
+// Utils
+
+type goSchemaNode struct {
+	type_    TypeName
+	fields   map[string]*goSchemaNode
+	elements *goSchemaNode
+	values   *goSchemaNode // map wildcard
+}
+
+func (self *goSchemaNode) child(element PathElement) *goSchemaNode {
+	switch element.Type {
+	case ListPathType, SequencedListPathType:
+		if self.elements == nil {
+			self.elements = new(goSchemaNode)
+		}
+		return self.elements
+
+	default:
+		if isPathWildcard(element) {
+			if self.values == nil {
+				self.values = new(goSchemaNode)
+			}
+			return self.values
+		}
+
+		var key string
+		if element.Type == KeyPathType {
+			key = ValueToString(element.Value)
+		} else {
+			key = element.Value.(string)
+		}
+
+		if self.fields == nil {
+			self.fields = make(map[string]*goSchemaNode)
+		}
+		child, ok := self.fields[key]
+		if !ok {
+			child = new(goSchemaNode)
+			self.fields[key] = child
+		}
+		return child
+	}
+}
+
+type goStruct struct {
+	name string
+	node *goSchemaNode
+	path Path
+}
+
+type goGenerator struct {
+	options    *GoGeneratorOptions
+	structs    []goStruct
+	names      map[string]struct{}
+	importTime bool
+}
+
+func (self *goGenerator) goType(node *goSchemaNode, name string, path Path) (string, error) {
+	isMap := (node.fields != nil) || (node.values != nil)
+	isList := node.elements != nil
+
+	if (isMap && isList) || (isMap && (node.type_ != NoType) && (node.type_ != TypeMap)) || (isList && (node.type_ != NoType) && (node.type_ != TypeList)) {
+		return "", fmt.Errorf("conflicting types in schema at %q", schemaPatternString(path))
+	}
+
+	if (node.fields != nil) && (node.values != nil) {
+		// Struct fields cannot hold the values for other keys
+		return "", fmt.Errorf("both keys and wildcard values in schema at %q", schemaPatternString(path))
+	}
+
+	switch {
+	case node.fields != nil:
+		name = self.uniqueName(name)
+		self.structs = append(self.structs, goStruct{name, node, path})
+		return name, nil
+
+	case node.values != nil:
+		if type_, err := self.goType(node.values, name+"Item", path.Append(PathElement{FieldPathType, "*"})); err == nil {
+			return "map[string]" + type_, nil
+		} else {
+			return "", err
+		}
+
+	case node.elements != nil:
+		if type_, err := self.goType(node.elements, name+"Item", path.AppendList(-1)); err == nil {
+			return "[]" + type_, nil
+		} else {
+			return "", err
+		}
+	}
+
+	switch node.type_ {
+	case TypeString, TypeIP, TypeCIDR, TypeURL, TypeEmail, TypeHostname, TypeSemVer:
+		return "string", nil
+	case TypeBoolean:
+		return "bool", nil
+	case TypeInteger:
+		return "int64", nil
+	case TypeFloat:
+		return "float64", nil
+	case TypeBytes:
+		return "[]byte", nil
+	case TypeTimestamp:
+		self.importTime = true
+		return "time.Time", nil
+	case TypeMap:
+		return "map[string]any", nil
+	case TypeList:
+		return "[]any", nil
+	case TypeNull, NoType:
+		return "any", nil
+	default:
+		return "", fmt.Errorf("unsupported type in schema at %q: %s", schemaPatternString(path), node.type_)
+	}
+}
+
+func (self *goGenerator) writeStruct(writer *strings.Builder, struct_ goStruct) error {
+	keys := make([]string, 0, len(struct_.node.fields))
+	for key := range struct_.node.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(writer, "type %s struct {\n", struct_.name)
+
+	fieldNames := make(map[string]struct{})
+	for _, key := range keys {
+		fieldName := goIdentifier(key)
+		for suffix := 2; ; suffix++ {
+			if _, ok := fieldNames[fieldName]; !ok {
+				break
+			}
+			fieldName = goIdentifier(key) + strconv.Itoa(suffix)
+		}
+		fieldNames[fieldName] = struct{}{}
+
+		type_, err := self.goType(struct_.node.fields[key], struct_.name+fieldName, struct_.path.AppendField(key))
+		if err != nil {
+			return err
+		}
+
+		tag := self.options.Tag + ":" + strconv.Quote(key)
+		if strings.Contains(tag, "`") {
+			tag = strconv.Quote(tag)
+		} else {
+			tag = "`" + tag + "`"
+		}
+
+		fmt.Fprintf(writer, "\t%s %s %s\n", fieldName, type_, tag)
+	}
+
+	writer.WriteString("}\n\n")
+	return nil
+}
+
+func (self *goGenerator) uniqueName(name string) string {
+	name_ := name
+	for suffix := 2; ; suffix++ {
+		if _, ok := self.names[name_]; !ok {
+			break
+		}
+		name_ = name + strconv.Itoa(suffix)
+	}
+	self.names[name_] = struct{}{}
+	return name_
+}
+
+// Converts to an exported Go identifier, e.g. "max-connections" to
+// "MaxConnections"
+func goIdentifier(key string) string {
+	var builder strings.Builder
+	upper := true
+	for _, rune_ := range key {
+		if unicode.IsLetter(rune_) || unicode.IsDigit(rune_) {
+			if upper {
+				builder.WriteRune(unicode.ToUpper(rune_))
+				upper = false
+			} else {
+				builder.WriteRune(rune_)
+			}
+		} else {
+			upper = true
+		}
+	}
+
+	identifier := builder.String()
+	if identifier == "" {
+		return "Field"
+	} else if unicode.IsDigit([]rune(identifier)[0]) {
+		return "X" + identifier
+	} else {
+		return identifier
+	}
+}
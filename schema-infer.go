@@ -0,0 +1,81 @@
+package ard
+
+// Infers a [Schema] from an example value, e.g. for [GenerateGo] or for
+// coercing other values of the same shape via [CoerceToSchema].
+//
+// All list elements are described by a single "[*]" pattern. Maps are
+// described key by key (wildcards are never inferred), with both [Map] and
+// [StringMap] as [TypeMap]. Non-string keys are quoted, e.g.
+// `responses["200"]`, so that integer keys are not confused with list
+// indexes. Non-ARD values are ignored.
+//
+// When values at the same pattern have different types, integers and floats
+// are unified to [TypeFloat] and nulls are ignored. Other conflicts remove
+// the pattern from the schema.
+func InferSchema(value Value) Schema {
+	inferrer := schemaInferrer{
+		schema:    make(Schema),
+		conflicts: make(map[string]struct{}),
+	}
+
+	inferrer.infer(value, nil)
+
+	for pattern := range inferrer.conflicts {
+		delete(inferrer.schema, pattern)
+	}
+
+	return inferrer.schema
+}
+
+// Utils
+
+type schemaInferrer struct {
+	schema    Schema
+	conflicts map[string]struct{}
+}
+
+func (self *schemaInferrer) infer(value Value, path Path) {
+	var type_ TypeName
+	switch value_ := value.(type) {
+	case Map:
+		type_ = TypeMap
+		for key, element := range value_ {
+			self.infer(element, path.Append(schemaKeyPathElement(key)))
+		}
+
+	case StringMap:
+		type_ = TypeMap
+		for key, element := range value_ {
+			self.infer(element, path.AppendField(key))
+		}
+
+	case List:
+		type_ = TypeList
+		for _, element := range value_ {
+			// Index -1 is the list wildcard (see parseSchemaPattern)
+			self.infer(element, path.AppendList(-1))
+		}
+
+	default:
+		type_ = GetTypeName(value)
+		if _, ok := TypeZeroes[type_]; !ok {
+			return
+		}
+	}
+
+	if len(path) > 0 {
+		self.add(schemaPatternString(path), type_)
+	}
+}
+
+func (self *schemaInferrer) add(pattern string, type_ TypeName) {
+	if existing, ok := self.schema[pattern]; !ok || (existing == TypeNull) {
+		self.schema[pattern] = type_
+	} else if (type_ != existing) && (type_ != TypeNull) {
+		if ((existing == TypeInteger) && (type_ == TypeFloat)) || ((existing == TypeFloat) && (type_ == TypeInteger)) {
+			self.schema[pattern] = TypeFloat
+		} else {
+			self.conflicts[pattern] = struct{}{}
+		}
+	}
+}
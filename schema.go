@@ -0,0 +1,310 @@
+package ard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tliron/kutil/util"
+)
+
+//
+// Schema
+//
+
+// Expected types per path, used for schema-guided decoding.
+//
+// Keys are paths in [Path.String] format. "*" can be used as a wildcard
+// for a whole path element, e.g. "servers[*].port" or "services.*.port".
+// Exact paths take precedence over wildcards. Non-string map keys can also be
+// quoted, e.g. `responses["200"]` for the integer key 200.
+type Schema map[string]TypeName
+
+// Decodes supported formats to an ARD [Value] while coercing scalars to the
+// types expected by the schema. See [CoerceToSchema].
+//
+// A [Locator] will be returned if possible, and is used for reporting
+// positions of coercion failures.
+func DecodeWithSchema(code []byte, format string, schema Schema) (Value, Locator, error) {
+	return ReadWithSchema(bytes.NewReader(code), format, schema)
+}
+
+// Reads and decodes supported formats to an ARD [Value] while coercing
+// scalars to the types expected by the schema. See [CoerceToSchema].
+//
+// A [Locator] will be returned if possible, and is used for reporting
+// positions of coercion failures.
+func ReadWithSchema(reader io.Reader, format string, schema Schema) (Value, Locator, error) {
+	if value, locator, err := Read(reader, format, true); err == nil {
+		value, err = CoerceToSchema(value, schema, locator)
+		return value, locator, err
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Coerces scalars to the types expected by the schema. This is useful for
+// formats in which scalars are ambiguous, e.g. in YAML 8080 and "8080" are
+// different types, and "true" and true are different types.
+//
+// Supported coercions are:
+//
+//   - [TypeString]: from any primitive via [ValueToString]
+//   - [TypeInteger]: from integers, floats without a fractional part, and decimal strings
+//   - [TypeFloat]: from integers, floats, and strings
+//   - [TypeBoolean]: from booleans and strings via [strconv.ParseBool]
+//   - [TypeBytes]: from base64 strings
+//   - [TypeTimestamp]: from RFC 3339 strings
+//
+// Other types are verified but not coerced. Nil values are left as is.
+//
+// Coercion happens in place when possible. The returned error will be
+// [Errors] containing a [*CoercionError] for every failure, so it is
+// possible to report all failures at once. The locator argument can be nil,
+// otherwise it will be used to add positions to the errors.
+func CoerceToSchema(value Value, schema Schema, locator Locator) (Value, error) {
+	if len(schema) == 0 {
+		return value, nil
+	}
+
+	coercer := schemaCoercer{schema: schema, locator: locator}
+
+	// Sort for deterministic precedence of wildcards
+	patterns := make([]string, 0, len(schema))
+	for pattern := range schema {
+		if strings.Contains(pattern, "*") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if re, err := compilePathPattern(pattern); err == nil {
+			coercer.patterns = append(coercer.patterns, schemaPattern{re, schema[pattern]})
+		} else {
+			return nil, err
+		}
+	}
+
+	value = coercer.coerce(value, nil)
+	return value, coercer.errs.orNil()
+}
+
+//
+// CoercionError
+//
+
+type CoercionError struct {
+	Path   Path
+	Type   TypeName
+	Value  Value
+	Line   int // -1 if unknown
+	Column int // -1 if unknown
+	Err    error
+}
+
+// ([error] interface)
+func (self *CoercionError) Error() string {
+	var builder strings.Builder
+	builder.WriteString(self.Path.String())
+	if self.Line != -1 {
+		builder.WriteString(fmt.Sprintf(" (%d:%d)", self.Line, self.Column))
+	}
+	builder.WriteString(fmt.Sprintf(": cannot coerce %s to %s", GetTypeName(self.Value), self.Type))
+	if self.Err != nil {
+		builder.WriteString(": ")
+		builder.WriteString(self.Err.Error())
+	}
+	return builder.String()
+}
+
+func (self *CoercionError) Unwrap() error {
+	return self.Err
+}
+
+func (self *CoercionError) getPath() Path {
+	return self.Path
+}
+
+// Utils
+
+type schemaPattern struct {
+	re    *regexp.Regexp
+	type_ TypeName
+}
+
+type schemaCoercer struct {
+	schema   Schema
+	patterns []schemaPattern
+	locator  Locator
+	errs     Errors
+}
+
+func (self *schemaCoercer) coerce(value Value, path Path) Value {
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			value_[key] = self.coerce(element, path.AppendKey(key))
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			value_[key] = self.coerce(element, path.AppendKey(key))
+		}
+
+	case List:
+		for index, element := range value_ {
+			value_[index] = self.coerce(element, path.AppendList(index))
+		}
+	}
+
+	if type_, ok := self.getType(path); ok && (value != nil) {
+		if value_, err := coerceToType(value, type_); err == nil {
+			return value_
+		} else {
+			line, column := -1, -1
+			if self.locator != nil {
+				if line_, column_, ok := self.locator.Locate(path...); ok {
+					line, column = line_, column_
+				}
+			}
+
+			if err == errIncompatibleType {
+				err = nil
+			}
+
+			self.errs = append(self.errs, &CoercionError{
+				Path:   path,
+				Type:   type_,
+				Value:  value,
+				Line:   line,
+				Column: column,
+				Err:    err,
+			})
+		}
+	}
+
+	return value
+}
+
+func (self *schemaCoercer) getType(path Path) (TypeName, bool) {
+	if type_, ok := self.getPatternType(path.String()); ok {
+		return type_, true
+	}
+
+	// Non-string keys can also be quoted (see InferSchema)
+	if path_ := schemaKeyPath(path); path_ != nil {
+		return self.getPatternType(path_.String())
+	}
+
+	return NoType, false
+}
+
+func (self *schemaCoercer) getPatternType(path string) (TypeName, bool) {
+	if type_, ok := self.schema[path]; ok {
+		return type_, true
+	}
+
+	for _, pattern := range self.patterns {
+		if pattern.re.MatchString(path) {
+			return pattern.type_, true
+		}
+	}
+
+	return NoType, false
+}
+
+var errIncompatibleType = errors.New("incompatible type")
+
+func coerceToType(value Value, type_ TypeName) (Value, error) {
+	switch type_ {
+	case TypeString:
+		switch value.(type) {
+		case string:
+			return value, nil
+		case Map, StringMap, List:
+		default:
+			return ValueToString(value), nil
+		}
+
+	case TypeInteger:
+		switch value_ := value.(type) {
+		case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+			return value, nil
+		case float64:
+			return floatToInteger(value_)
+		case float32:
+			return floatToInteger(float64(value_))
+		case string:
+			value_ = strings.TrimSpace(value_)
+			if integer, err := strconv.ParseInt(value_, 10, 64); err == nil {
+				return integer, nil
+			} else if uinteger, err_ := strconv.ParseUint(value_, 10, 64); err_ == nil {
+				return uinteger, nil
+			} else {
+				return nil, err
+			}
+		}
+
+	case TypeFloat:
+		switch value_ := value.(type) {
+		case float64, float32:
+			return value, nil
+		case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+			float, _ := util.ToFloat64(value_)
+			return float, nil
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(value_), 64)
+		}
+
+	case TypeBoolean:
+		switch value_ := value.(type) {
+		case bool:
+			return value, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(value_))
+		}
+
+	case TypeBytes:
+		switch value_ := value.(type) {
+		case []byte:
+			return value, nil
+		case string:
+			return util.FromBase64(value_)
+		}
+
+	case TypeTimestamp:
+		switch value_ := value.(type) {
+		case time.Time:
+			return value, nil
+		case string:
+			return time.Parse(time.RFC3339Nano, strings.TrimSpace(value_))
+		}
+
+	default:
+		if validator, ok := TypeValidators[type_]; ok {
+			if validator(value) {
+				return value, nil
+			}
+		} else {
+			return nil, fmt.Errorf("unsupported type: %s", type_)
+		}
+	}
+
+	return nil, errIncompatibleType
+}
+
+func floatToInteger(value float64) (Value, error) {
+	if (value == math.Trunc(value)) && (value >= math.MinInt64) && (value < math.MaxInt64) {
+		return int64(value), nil
+	} else {
+		return nil, fmt.Errorf("not an integer: %s", strconv.FormatFloat(value, 'g', -1, 64))
+	}
+}
@@ -0,0 +1,163 @@
+package ard
+
+import (
+	"reflect"
+	"regexp"
+)
+
+//
+// SearchOptions
+//
+
+// All set criteria must match. Unset criteria are ignored.
+type SearchOptions struct {
+	// Matches [Map] and [StringMap] keys, converted using [MapKeyToString].
+	// When set, only map entries can match (not list elements or the root).
+	KeyRegex *regexp.Regexp
+
+	// Matches string values. When set, only strings can match.
+	ValueRegex *regexp.Regexp
+
+	// Matches values via [TypeValidators] (thus semantic types such as
+	// [TypeIP] are supported, and [StringMap] matches [TypeMap]). Otherwise
+	// matches values via [GetTypeName].
+	Type TypeName
+}
+
+//
+// SearchResult
+//
+
+type SearchResult struct {
+	Path  Path
+	Value Value
+}
+
+// Searches a value recursively, returning the paths and values of all
+// matches. For example, use a KeyRegex of "(?i)password" to find all keys
+// that contain "password".
+//
+// The results are in depth-first order, with map entries sorted by key
+// according to [Compare], so that they are deterministic.
+//
+// The options argument can be nil, in which case all values will match.
+func Search(value Value, options *SearchOptions) []SearchResult {
+	if options == nil {
+		options = new(SearchOptions)
+	}
+
+	var results []SearchResult
+	search(value, nil, nil, false, options, &results)
+	return results
+}
+
+// Returns the paths at which a target value appears within a root value,
+// which is useful for debugging aliasing of shared subtrees and for exploring
+// large documents.
+//
+// When byEquality is true values are compared via [Equals]. Otherwise they are
+// compared by identity: [Map], [StringMap], and [List] (and []byte) match only
+// if they are the very same instance, e.g. when the same subtree was inserted
+// in several places, while other values are compared via the "==" operator.
+//
+// The paths are in depth-first order, with map entries sorted by key according
+// to [Compare], as with [Search]. The values nested in a match are not
+// searched.
+func PathsOf(root Value, target Value, byEquality bool) []Path {
+	var paths []Path
+	pathsOf(root, target, byEquality, nil, &paths)
+	return paths
+}
+
+// Utils
+
+func pathsOf(value Value, target Value, byEquality bool, path Path, paths *[]Path) {
+	if byEquality {
+		if Equals(value, target) {
+			*paths = append(*paths, path)
+			return
+		}
+	} else if isSameValue(value, target) {
+		*paths = append(*paths, path)
+		return
+	}
+
+	switch value_ := value.(type) {
+	case Map, StringMap:
+		for _, entry := range sortedCompareEntries(value_) {
+			pathsOf(entry.value, target, byEquality, path.AppendKey(entry.key), paths)
+		}
+
+	case List:
+		for index, element := range value_ {
+			pathsOf(element, target, byEquality, path.AppendList(index), paths)
+		}
+	}
+}
+
+// Identity for maps and slices; "==" for other comparable values
+func isSameValue(a Value, b Value) bool {
+	type_ := reflect.TypeOf(a)
+	if type_ != reflect.TypeOf(b) {
+		return false
+	}
+
+	switch a.(type) {
+	case nil:
+		return true
+	case Map, StringMap, List, []byte:
+		a_ := reflect.ValueOf(a)
+		b_ := reflect.ValueOf(b)
+		return (a_.Pointer() == b_.Pointer()) && (a_.Len() == b_.Len())
+	}
+
+	if type_.Comparable() {
+		return a == b
+	}
+
+	return false
+}
+
+func search(value Value, path Path, key Value, isEntry bool, options *SearchOptions, results *[]SearchResult) {
+	if options.matches(value, key, isEntry) {
+		*results = append(*results, SearchResult{path, value})
+	}
+
+	switch value_ := value.(type) {
+	case Map, StringMap:
+		for _, entry := range sortedCompareEntries(value_) {
+			search(entry.value, path.AppendKey(entry.key), entry.key, true, options, results)
+		}
+
+	case List:
+		for index, element := range value_ {
+			search(element, path.AppendList(index), nil, false, options, results)
+		}
+	}
+}
+
+func (self *SearchOptions) matches(value Value, key Value, isEntry bool) bool {
+	if self.KeyRegex != nil {
+		if !isEntry || !self.KeyRegex.MatchString(MapKeyToString(key)) {
+			return false
+		}
+	}
+
+	if self.ValueRegex != nil {
+		if string_, ok := value.(string); !ok || !self.ValueRegex.MatchString(string_) {
+			return false
+		}
+	}
+
+	if self.Type != NoType {
+		if validator, ok := TypeValidators[self.Type]; ok {
+			if !validator(value) {
+				return false
+			}
+		} else if GetTypeName(value) != self.Type {
+			return false
+		}
+	}
+
+	return true
+}
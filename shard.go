@@ -0,0 +1,157 @@
+package ard
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+//
+// ShardManifest
+//
+
+// Describes a [List] that was split across several encoded outputs by
+// [WriteSharded]. It can itself be encoded, e.g. via [Write], to be stored
+// alongside the shards.
+type ShardManifest struct {
+	// A supported format (see [SupportedFormats])
+	Format string `ard:"format"`
+
+	// Total number of elements in all shards
+	Count int `ard:"count"`
+
+	// In order
+	Shards []ShardInfo `ard:"shards"`
+}
+
+//
+// ShardInfo
+//
+
+type ShardInfo struct {
+	Index int `ard:"index"`
+
+	// The index of the shard's first element in the whole list
+	Offset int `ard:"offset"`
+
+	// Number of elements in the shard
+	Count int `ard:"count"`
+
+	// CRC-32 (IEEE) of the encoded shard
+	Checksum uint32 `ard:"checksum"`
+}
+
+// Splits a [List] into shards of up to shardSize elements each, and encodes
+// each shard as a [List] to a supported format (see [SupportedFormats]). This
+// is useful for exporting datasets that are too large for a single file.
+//
+// The opener is called for every shard with its index, starting at 0, and the
+// writer it returns is closed after the shard is written. An empty list has
+// no shards.
+//
+// Returns a [ShardManifest] describing the shards, which can be used to read
+// them back via [ReadSharded].
+func WriteSharded(value List, shardSize int, opener func(index int) (io.WriteCloser, error), format string) (*ShardManifest, error) {
+	if shardSize < 1 {
+		return nil, fmt.Errorf("shard size must be at least 1: %d", shardSize)
+	}
+
+	manifest := ShardManifest{
+		Format: format,
+		Count:  len(value),
+	}
+
+	for offset := 0; offset < len(value); offset += shardSize {
+		end := min(offset+shardSize, len(value))
+		info := ShardInfo{
+			Index:  len(manifest.Shards),
+			Offset: offset,
+			Count:  end - offset,
+		}
+
+		writer, err := opener(info.Index)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", info.Index, err)
+		}
+
+		hash := crc32.NewIEEE()
+		err = Write(value[offset:end], io.MultiWriter(writer, hash), format, nil)
+		if err_ := writer.Close(); err == nil {
+			err = err_
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", info.Index, err)
+		}
+
+		info.Checksum = hash.Sum32()
+		manifest.Shards = append(manifest.Shards, info)
+	}
+
+	return &manifest, nil
+}
+
+// Reads shards written by [WriteSharded] and joins them back into a single
+// [List]. Checksums and element counts are verified against the manifest.
+//
+// The opener is called for every shard with its index, and the reader it
+// returns is closed after the shard is read.
+func ReadSharded(manifest *ShardManifest, opener func(index int) (io.ReadCloser, error)) (List, error) {
+	if manifest == nil {
+		return nil, errors.New("no shard manifest")
+	}
+
+	list := make(List, 0, manifest.Count)
+	for _, info := range manifest.Shards {
+		if len(list) != info.Offset {
+			return nil, fmt.Errorf("shard %d: offset is %d, expected %d", info.Index, info.Offset, len(list))
+		}
+
+		shard, err := readShard(info, manifest.Format, opener)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", info.Index, err)
+		}
+
+		list = append(list, shard...)
+	}
+
+	if len(list) != manifest.Count {
+		return nil, fmt.Errorf("shards have %d elements, expected %d", len(list), manifest.Count)
+	}
+
+	return list, nil
+}
+
+// Utils
+
+func readShard(info ShardInfo, format string, opener func(index int) (io.ReadCloser, error)) (List, error) {
+	reader, err := opener(info.Index)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	hash := crc32.NewIEEE()
+	value, _, err := Read(io.TeeReader(reader, hash), format, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure we hash the entire content
+	if _, err := io.Copy(hash, reader); err != nil {
+		return nil, err
+	}
+
+	if checksum := hash.Sum32(); checksum != info.Checksum {
+		return nil, fmt.Errorf("checksum mismatch: expected %08x, got %08x", info.Checksum, checksum)
+	}
+
+	if list, ok := value.(List); ok {
+		if len(list) != info.Count {
+			return nil, fmt.Errorf("has %d elements, expected %d", len(list), info.Count)
+		}
+		return list, nil
+	} else {
+		return nil, fmt.Errorf("is not a list: %s", GetTypeName(value))
+	}
+}
@@ -1,6 +1,12 @@
 package ard
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/tliron/kutil/util"
 	"github.com/tliron/yamlkeys"
 )
@@ -8,14 +14,274 @@ import (
 // Provides consistent stringification of primitive ARD [Value].
 //
 // Non-ARD types will be converted via [util.ToString].
+//
+// See [ValueToStringWithOptions] for configurable stringification, including
+// of [Map], [StringMap], and [List].
 func ValueToString(value Value) string {
 	return util.ToString(value)
 }
 
+// Like [ValueToStringWithOptions] with default options but with strings, []byte, lists, and maps truncated
+// to at most maxLength characters, bytes, elements, and entries respectively,
+// with an ellipsis and the original length appended, e.g. "abc...(1024
+// chars)". Useful for interpolating values of unknown size into log lines and
+// error messages.
+//
+// See [ValueToStringOptions].MaxLength.
+func ValueToStringN(value Value, maxLength int) string {
+	return ValueToStringWithOptions(value, &ValueToStringOptions{MaxLength: maxLength})
+}
+
+// Provides consistent stringification of ARD [Value]. Note that it differs
+// from [ValueToString] for some values, e.g. nil is "null" rather than "nil"
+// and []byte is encoded (by default as base64) rather than used as is.
+//
+// Primitives are stringified according to the options, which can be nil
+// to use the defaults. [Map], [StringMap], and [List] are stringified as
+// compact JSON-like text, in which strings are always quoted and map keys
+// are sorted. Nil is stringified as "null".
+//
+// Non-ARD types will be stringified via their [fmt.Stringer] or [error]
+// implementations if available, otherwise via [fmt.Sprintf]("%+v").
+func ValueToStringWithOptions(value Value, options *ValueToStringOptions) string {
+	if options == nil {
+		options = &defaultValueToStringOptions
+	}
+
+	if string_, ok := value.(string); ok && !options.QuoteStrings && !options.truncates(len(string_)) {
+		// Fast path
+		return string_
+	}
+
+	var builder strings.Builder
+	writeValueString(&builder, value, options)
+	return builder.String()
+}
+
 // Provides consistent stringification of keys for ARD [StringMap].
 //
 // Used by functions such as [ConvertMapsToStringMaps] and
 // [CopyMapsToStringMaps].
+//
+// Strings are returned as is and [fmt.Stringer] implementations are
+// stringified via their String method. Thus complex keys wrapped via
+// [MakeKey] are stringified as compact YAML.
 func MapKeyToString(key any) string {
 	return yamlkeys.KeyString(key)
 }
+
+//
+// ValueToStringOptions
+//
+
+// The zero value uses the default for every option.
+type ValueToStringOptions struct {
+	// Format for floats as in [strconv.FormatFloat], e.g. 'f' to avoid scientific
+	// notation. If 0 then 'g' will be used with the smallest precision necessary,
+	// and FloatPrecision is ignored.
+	FloatFormat byte
+
+	// Precision for floats as in [strconv.FormatFloat]. 0 (the default) and -1
+	// mean the smallest precision necessary, so that values are never rounded
+	// unless explicitly requested. Ignored if FloatFormat is 0.
+	FloatPrecision int
+
+	// Layout for [time.Time] as in [time.Time.Format]. If empty then
+	// [time.RFC3339Nano] will be used.
+	TimestampLayout string
+
+	// Representation for []byte. Defaults to base64.
+	BytesEncoding BytesEncoding
+
+	// When true, strings, as well as the representations of []byte and
+	// [time.Time], will be quoted. Note that these are always quoted when
+	// nested in [Map], [StringMap], and [List].
+	QuoteStrings bool
+
+	// When greater than 0, strings, []byte, lists, and maps that are longer
+	// than this will be truncated, with an ellipsis and their original length
+	// appended. For strings the length is in characters (runes), for []byte in
+	// bytes (before encoding), for lists in elements, and for maps in entries.
+	//
+	// Note that the limit applies to each nested value separately.
+	MaxLength int
+}
+
+var defaultValueToStringOptions ValueToStringOptions
+
+func (self *ValueToStringOptions) formatFloat(value float64, bitSize int) string {
+	if self.FloatFormat == 0 {
+		return strconv.FormatFloat(value, 'g', -1, bitSize)
+	} else {
+		return strconv.FormatFloat(value, self.FloatFormat, floatPrecision(self.FloatPrecision), bitSize)
+	}
+}
+
+// Utils
+
+// 0 means the smallest precision necessary, as does -1
+func floatPrecision(precision int) int {
+	if precision == 0 {
+		return -1
+	} else {
+		return precision
+	}
+}
+
+func writeValueString(builder *strings.Builder, value Value, options *ValueToStringOptions) {
+	switch value_ := value.(type) {
+	case nil:
+		builder.WriteString("null")
+
+	case string:
+		if options.truncates(len(value_)) {
+			if runes := []rune(value_); options.truncates(len(runes)) {
+				options.writeString(builder, string(runes[:options.MaxLength]))
+				writeTruncated(builder, len(runes), "chars")
+				break
+			}
+		}
+		options.writeString(builder, value_)
+
+	case bool:
+		builder.WriteString(strconv.FormatBool(value_))
+
+	case int64:
+		builder.WriteString(strconv.FormatInt(value_, 10))
+	case int32:
+		builder.WriteString(strconv.FormatInt(int64(value_), 10))
+	case int16:
+		builder.WriteString(strconv.FormatInt(int64(value_), 10))
+	case int8:
+		builder.WriteString(strconv.FormatInt(int64(value_), 10))
+	case int:
+		builder.WriteString(strconv.FormatInt(int64(value_), 10))
+
+	case uint64:
+		builder.WriteString(strconv.FormatUint(value_, 10))
+	case uint32:
+		builder.WriteString(strconv.FormatUint(uint64(value_), 10))
+	case uint16:
+		builder.WriteString(strconv.FormatUint(uint64(value_), 10))
+	case uint8:
+		builder.WriteString(strconv.FormatUint(uint64(value_), 10))
+	case uint:
+		builder.WriteString(strconv.FormatUint(uint64(value_), 10))
+
+	case float64:
+		builder.WriteString(options.formatFloat(value_, 64))
+	case float32:
+		builder.WriteString(options.formatFloat(float64(value_), 32))
+
+	case []byte:
+		if options.truncates(len(value_)) {
+			options.writeString(builder, EncodeBytes(value_[:options.MaxLength], options.BytesEncoding))
+			writeTruncated(builder, len(value_), "bytes")
+		} else {
+			options.writeString(builder, EncodeBytes(value_, options.BytesEncoding))
+		}
+
+	case time.Time:
+		if options.TimestampLayout == "" {
+			options.writeString(builder, value_.Format(time.RFC3339Nano))
+		} else {
+			options.writeString(builder, value_.Format(options.TimestampLayout))
+		}
+
+	case List:
+		nestedOptions := options.nested()
+		builder.WriteRune('[')
+		for index, element := range value_ {
+			if index > 0 {
+				builder.WriteRune(',')
+			}
+			if options.truncates(index + 1) {
+				writeTruncated(builder, len(value_), "items")
+				break
+			}
+			writeValueString(builder, element, nestedOptions)
+		}
+		builder.WriteRune(']')
+
+	case Map:
+		entries := make([]stringEntry, 0, len(value_))
+		for key, element := range value_ {
+			entries = append(entries, stringEntry{MapKeyToString(key), element})
+		}
+		writeMapString(builder, entries, options.nested())
+
+	case StringMap:
+		entries := make([]stringEntry, 0, len(value_))
+		for key, element := range value_ {
+			entries = append(entries, stringEntry{key, element})
+		}
+		writeMapString(builder, entries, options.nested())
+
+	default:
+		if codec, ok := getScalarCodec(value); ok {
+			options.writeString(builder, codec.ToString(value))
+		} else if stringer, ok := value.(fmt.Stringer); ok {
+			builder.WriteString(stringer.String())
+		} else if err, ok := value.(error); ok {
+			builder.WriteString(err.Error())
+		} else {
+			builder.WriteString(fmt.Sprintf("%+v", value_))
+		}
+	}
+}
+
+type stringEntry struct {
+	key   string
+	value Value
+}
+
+func writeMapString(builder *strings.Builder, entries []stringEntry, options *ValueToStringOptions) {
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	builder.WriteRune('{')
+	for index, entry := range entries {
+		if index > 0 {
+			builder.WriteRune(',')
+		}
+		if options.truncates(index + 1) {
+			writeTruncated(builder, len(entries), "entries")
+			break
+		}
+		builder.WriteString(strconv.Quote(entry.key))
+		builder.WriteRune(':')
+		writeValueString(builder, entry.value, options)
+	}
+	builder.WriteRune('}')
+}
+
+func (self *ValueToStringOptions) writeString(builder *strings.Builder, value string) {
+	if self.QuoteStrings {
+		builder.WriteString(strconv.Quote(value))
+	} else {
+		builder.WriteString(value)
+	}
+}
+
+func (self *ValueToStringOptions) truncates(length int) bool {
+	return (self.MaxLength > 0) && (length > self.MaxLength)
+}
+
+func writeTruncated(builder *strings.Builder, length int, unit string) {
+	builder.WriteString("...(")
+	builder.WriteString(strconv.Itoa(length))
+	builder.WriteRune(' ')
+	builder.WriteString(unit)
+	builder.WriteRune(')')
+}
+
+func (self *ValueToStringOptions) nested() *ValueToStringOptions {
+	if self.QuoteStrings {
+		return self
+	}
+
+	options := *self
+	options.QuoteStrings = true
+	return &options
+}
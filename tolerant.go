@@ -0,0 +1,184 @@
+package ard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+	"github.com/tliron/yamlkeys"
+	"gopkg.in/yaml.v3"
+)
+
+//
+// InvalidValue
+//
+
+// A placeholder for a value that could not be decoded. See [DecodeTolerant].
+//
+// InvalidValue is not an ARD value.
+type InvalidValue struct {
+	// The original text, if available
+	Source string
+
+	Err error
+}
+
+// ([fmt.Stringer] interface)
+func (self *InvalidValue) String() string {
+	return fmt.Sprintf("invalid %q: %s", self.Source, self.Err.Error())
+}
+
+// Like [Decode] but tolerates localized errors, so that a partial value can
+// be returned for broken documents, e.g. for linters and editors. Tolerated
+// errors are returned as [Diagnostics]:
+//
+//   - For "yaml", "json", "xjson", and "xml", invalid UTF-8 sequences are
+//     replaced with U+FFFD, with a warning for each.
+//   - For "yaml", scalars that cannot be decoded (e.g. "!!int x") are replaced
+//     with an [*InvalidValue], with an error. Map entries with malformed or
+//     duplicate keys and malformed merges are skipped, with an error. For
+//     duplicate keys the first entry is kept.
+//   - For "xml", scalars that cannot be decoded and elements with unsupported
+//     tags are replaced with an [*InvalidValue], with an error. Map entries
+//     with malformed keys are skipped, with an error, and unknown map elements
+//     are skipped, with a warning.
+//
+// Other formats are decoded as with [Decode]. Errors that are not localized,
+// e.g. syntax errors, are still returned as an error, in which case the value
+// is nil.
+//
+// Diagnostics have line and column numbers where available (currently only
+// for YAML).
+func DecodeTolerant(code []byte, format string) (Value, Diagnostics, error) {
+	var diagnostics Diagnostics
+
+	switch format {
+	case "yaml", "json", "xjson", "xml":
+		code = diagnostics.sanitizeUTF8(code)
+	}
+
+	switch format {
+	case "yaml":
+		var node yaml.Node
+		if err := yaml.NewDecoder(bytes.NewReader(code)).Decode(&node); err != nil {
+			return nil, diagnostics, yamlkeys.WrapWithDecodeError(err)
+		}
+
+		decoder := yamlNodeDecoder{options: new(YAMLDecodeOptions), diagnostics: &diagnostics}
+		if value, err := decoder.decode(&node); err == nil {
+			return value, diagnostics, nil
+		} else {
+			return nil, diagnostics, err
+		}
+
+	case "xml":
+		document := etree.NewDocument()
+		if err := document.ReadFromBytes(code); err != nil {
+			return nil, diagnostics, err
+		}
+
+		elements := document.ChildElements()
+		if length := len(elements); length != 1 {
+			return nil, diagnostics, fmt.Errorf("unsupported XML: %d documents", length)
+		}
+
+		decoder := xmlDecoder{diagnostics: &diagnostics}
+		if value, err := decoder.unpack(elements[0], nil); err == nil {
+			return value, diagnostics, nil
+		} else {
+			return nil, diagnostics, err
+		}
+
+	default:
+		if value, _, err := Decode(code, format, false); err == nil {
+			return value, diagnostics, nil
+		} else {
+			return nil, diagnostics, err
+		}
+	}
+}
+
+// Like [DecodeTolerant] but reads from an [io.Reader]. The data is fully read
+// before decoding.
+func ReadTolerant(reader io.Reader, format string) (Value, Diagnostics, error) {
+	if code, err := io.ReadAll(reader); err == nil {
+		return DecodeTolerant(code, format)
+	} else {
+		return nil, nil, err
+	}
+}
+
+// Utils
+
+func (self *Diagnostics) addError(path Path, line int, column int, err error) {
+	message := err.Error()
+	if len(path) > 0 {
+		message = path.String() + ": " + message
+	}
+
+	*self = append(*self, &Diagnostic{
+		Severity: SeverityError,
+		Message:  message,
+		Path:     path,
+		Line:     line,
+		Column:   column,
+		Err:      err,
+	})
+}
+
+func (self *Diagnostics) addWarning(path Path, message string, keysAndValues ...any) {
+	if len(path) > 0 {
+		message = path.String() + " " + message
+	}
+
+	*self = append(*self, &Diagnostic{
+		Severity:      SeverityWarning,
+		Message:       message,
+		Path:          path,
+		Line:          -1,
+		Column:        -1,
+		KeysAndValues: keysAndValues,
+	})
+}
+
+// Replaces invalid UTF-8 sequences with U+FFFD, recording a warning for each
+func (self *Diagnostics) sanitizeUTF8(code []byte) []byte {
+	if utf8.Valid(code) {
+		return code
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(code))
+	line, column := 1, 1
+	invalid := false
+	for len(code) > 0 {
+		rune_, size := utf8.DecodeRune(code)
+		if (rune_ == utf8.RuneError) && (size == 1) {
+			// Consecutive invalid bytes are replaced together
+			if !invalid {
+				*self = append(*self, &Diagnostic{
+					Severity: SeverityWarning,
+					Message:  "replaced invalid UTF-8",
+					Line:     line,
+					Column:   column,
+				})
+				buffer.WriteRune(utf8.RuneError)
+				column++
+				invalid = true
+			}
+		} else {
+			buffer.Write(code[:size])
+			if rune_ == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+			invalid = false
+		}
+		code = code[size:]
+	}
+	return buffer.Bytes()
+}
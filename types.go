@@ -2,6 +2,9 @@ package ard
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tliron/kutil/util"
@@ -30,11 +33,19 @@ const (
 	TypeNull      TypeName = "ard.null"
 	TypeBytes     TypeName = "ard.bytes"
 	TypeTimestamp TypeName = "ard.timestamp"
+
+	// Semantic string formats (not returned by GetTypeName)
+	TypeIP       TypeName = "ard.ip"
+	TypeCIDR     TypeName = "ard.cidr"
+	TypeURL      TypeName = "ard.url"
+	TypeEmail    TypeName = "ard.email"
+	TypeHostname TypeName = "ard.hostname"
+	TypeSemVer   TypeName = "ard.semver"
 )
 
 // Returns a canonical name for all supported ARD types, including
-// primitives, [Map], [List], and [time.Time]. Note that [StringMap]
-// is not supported by this function.
+// primitives, [Map], [List], and [time.Time], as well as for types with a
+// [ScalarCodec]. Note that [StringMap] is not supported by this function.
 //
 // Unspported types will use [fmt.Sprintf]("%T").
 func GetTypeName(value Value) TypeName {
@@ -58,6 +69,9 @@ func GetTypeName(value Value) TypeName {
 	case time.Time:
 		return TypeTimestamp
 	default:
+		if codec, ok := getScalarCodec(value); ok {
+			return codec.TypeName
+		}
 		return TypeName(fmt.Sprintf("%T", value))
 	}
 }
@@ -94,6 +108,14 @@ var TypeValidators = map[TypeName]TypeValidator{
 	TypeNull:      IsNull,
 	TypeBytes:     IsBytes,
 	TypeTimestamp: IsTimestamp,
+
+	// Semantic string formats
+	TypeIP:       IsIP,
+	TypeCIDR:     IsCIDR,
+	TypeURL:      IsURL,
+	TypeEmail:    IsEmail,
+	TypeHostname: IsHostname,
+	TypeSemVer:   IsSemVer,
 }
 
 // Returns true if value is a [Map] (map[any]any).
@@ -152,13 +174,57 @@ func IsTimestamp(value Value) bool {
 }
 
 // Returns true if value is a string, bool, int64, int32, int16, int8, int,
-// uint64, uint32, uint16, uint8, uint, float64, float32, nil, []byte, or
-// [time.Time].
+// uint64, uint32, uint16, uint8, uint, float64, float32, nil, []byte,
+// [time.Time], or of a type registered via [RegisterPrimitiveTypes].
 func IsPrimitiveType(value Value) bool {
 	switch value.(type) {
 	case string, bool, int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32, nil, []byte, time.Time:
 		return true
 	default:
-		return false
+		return isRegisteredPrimitiveType(reflect.TypeOf(value))
+	}
+}
+
+var primitiveTypes atomic.Pointer[map[reflect.Type]struct{}]
+var primitiveTypesLock sync.Mutex
+
+// Registers additional types to be treated as "as-is" primitives, e.g.
+// uuid.UUID or decimal.Decimal, by providing example values of those types.
+//
+// Values of these types will be accepted by [IsPrimitiveType], left as is by
+// [ValidCopy] and [Reflector].Unpack, and packed by [Reflector].Pack into
+// fields of assignable types, all without reflection into their structure.
+// Thus domain types can flow through pipelines until final encoding, where
+// they must be supported by the codec (e.g. by implementing
+// [encoding/json.Marshaler]).
+//
+// This function is safe to call concurrently.
+func RegisterPrimitiveTypes(values ...any) {
+	primitiveTypesLock.Lock()
+	defer primitiveTypesLock.Unlock()
+
+	types := make(map[reflect.Type]struct{})
+	if current := primitiveTypes.Load(); current != nil {
+		for type_ := range *current {
+			types[type_] = struct{}{}
+		}
+	}
+
+	for _, value := range values {
+		if value != nil {
+			types[reflect.TypeOf(value)] = struct{}{}
+		}
+	}
+
+	primitiveTypes.Store(&types)
+}
+
+func isRegisteredPrimitiveType(type_ reflect.Type) bool {
+	if type_ != nil {
+		if types := primitiveTypes.Load(); types != nil {
+			_, ok := (*types)[type_]
+			return ok
+		}
 	}
+	return false
 }
@@ -0,0 +1,197 @@
+package ard
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// UTF8Mode
+//
+
+// How to handle strings that are not valid UTF-8, which are otherwise passed
+// through by some decoders only to fail much later, e.g. when encoding to XML.
+type UTF8Mode int
+
+const (
+	// Strings are not validated
+	UTF8Unchecked UTF8Mode = 0
+
+	// Invalid UTF-8 is an error
+	UTF8Strict UTF8Mode = 1
+
+	// Invalid UTF-8 sequences are replaced with U+FFFD
+	UTF8Replace UTF8Mode = 2
+)
+
+// ([fmt.Stringer] interface)
+func (self UTF8Mode) String() string {
+	switch self {
+	case UTF8Unchecked:
+		return "unchecked"
+	case UTF8Strict:
+		return "strict"
+	case UTF8Replace:
+		return "replace"
+	default:
+		return strconv.Itoa(int(self))
+	}
+}
+
+// Validates that all strings in a value, including map keys, are valid UTF-8
+// according to the mode.
+//
+// For [UTF8Strict] returns a [*PathError] for the first invalid string. For
+// [UTF8Replace] invalid sequences are replaced with U+FFFD, in place when
+// possible. Note that replacement can make string map keys collide, in which
+// case one of the entries is kept.
+//
+// Complex keys (see [MakeKey]) are not validated.
+func CheckUTF8(value Value, mode UTF8Mode) (Value, error) {
+	if mode == UTF8Unchecked {
+		return value, nil
+	}
+
+	return checkUTF8(value, mode, nil)
+}
+
+// Utils
+
+func checkUTF8(value Value, mode UTF8Mode, path Path) (Value, error) {
+	switch value_ := value.(type) {
+	case string:
+		if utf8.ValidString(value_) {
+			return value_, nil
+		} else if mode == UTF8Replace {
+			return strings.ToValidUTF8(value_, string(utf8.RuneError)), nil
+		} else {
+			return nil, newPathErrorf(path, "is not valid UTF-8: %q", value_)
+		}
+
+	case Map:
+		var replaced Map
+		for key, element := range value_ {
+			path_ := path.AppendKey(key)
+			if element_, err := checkUTF8(element, mode, path_); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+
+			if key_, ok := key.(string); ok && !utf8.ValidString(key_) {
+				if mode == UTF8Replace {
+					// Can't change keys while iterating
+					if replaced == nil {
+						replaced = make(Map)
+					}
+					replaced[key] = strings.ToValidUTF8(key_, string(utf8.RuneError))
+				} else {
+					return nil, newPathErrorf(path_, "key is not valid UTF-8: %q", key_)
+				}
+			}
+		}
+		for key, key_ := range replaced {
+			value_[key_] = value_[key]
+			delete(value_, key)
+		}
+
+	case StringMap:
+		var replaced map[string]string
+		for key, element := range value_ {
+			path_ := path.AppendField(key)
+			if element_, err := checkUTF8(element, mode, path_); err == nil {
+				value_[key] = element_
+			} else {
+				return nil, err
+			}
+
+			if !utf8.ValidString(key) {
+				if mode == UTF8Replace {
+					// Can't change keys while iterating
+					if replaced == nil {
+						replaced = make(map[string]string)
+					}
+					replaced[key] = strings.ToValidUTF8(key, string(utf8.RuneError))
+				} else {
+					return nil, newPathErrorf(path_, "key is not valid UTF-8: %q", key)
+				}
+			}
+		}
+		for key, key_ := range replaced {
+			value_[key_] = value_[key]
+			delete(value_, key)
+		}
+
+	case List:
+		for index, element := range value_ {
+			if element_, err := checkUTF8(element, mode, path.AppendList(index)); err == nil {
+				value_[index] = element_
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// Fails reading at the first invalid UTF-8 sequence, with its position
+type utf8ValidatingReader struct {
+	reader  io.Reader
+	pending []byte // incomplete sequence at the end of the previous read
+	line    int
+	column  int
+}
+
+func newUTF8ValidatingReader(reader io.Reader) *utf8ValidatingReader {
+	return &utf8ValidatingReader{reader: reader, line: 1, column: 1}
+}
+
+// ([io.Reader] interface)
+func (self *utf8ValidatingReader) Read(p []byte) (int, error) {
+	count, err := self.reader.Read(p)
+	if count > 0 {
+		if err_ := self.validate(p[:count], err == io.EOF); err_ != nil {
+			return 0, err_
+		}
+	} else if (err == io.EOF) && (len(self.pending) > 0) {
+		return 0, self.error()
+	}
+	return count, err
+}
+
+func (self *utf8ValidatingReader) validate(data []byte, eof bool) error {
+	if len(self.pending) > 0 {
+		data = append(self.pending, data...)
+		self.pending = nil
+	}
+
+	for len(data) > 0 {
+		if !eof && !utf8.FullRune(data) {
+			self.pending = append([]byte(nil), data...)
+			return nil
+		}
+
+		rune_, size := utf8.DecodeRune(data)
+		if (rune_ == utf8.RuneError) && (size == 1) {
+			return self.error()
+		}
+
+		if rune_ == '\n' {
+			self.line++
+			self.column = 1
+		} else {
+			self.column++
+		}
+		data = data[size:]
+	}
+
+	return nil
+}
+
+func (self *utf8ValidatingReader) error() error {
+	return fmt.Errorf("invalid UTF-8 at line %d, column %d", self.line, self.column)
+}
@@ -0,0 +1,43 @@
+package ard
+
+import (
+	"sync/atomic"
+)
+
+//
+// WarnFunc
+//
+
+// Receives warnings about potential silent data loss, e.g. ignored struct
+// fields and lossy number conversions. Warnings are not errors and do not
+// stop processing.
+//
+// The message is followed by alternating keys and values. This signature is
+// compatible with the [slog.Logger.Warn] method and the commonlog
+// Logger.Warning method, so that these can be used directly.
+type WarnFunc func(message string, keysAndValues ...any)
+
+var globalWarn atomic.Pointer[WarnFunc]
+
+// Sets the package-wide [WarnFunc]. It is used by the decoders and is the
+// fallback for [Reflector] if its own Warn field is not set.
+//
+// Can be nil, which disables warnings (the default).
+//
+// This function is safe to call concurrently.
+func SetWarnFunc(warn WarnFunc) {
+	if warn != nil {
+		globalWarn.Store(&warn)
+	} else {
+		globalWarn.Store(nil)
+	}
+}
+
+// Returns nil if not set
+func getWarnFunc() WarnFunc {
+	if warn := globalWarn.Load(); warn != nil {
+		return *warn
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,236 @@
+package ard
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Encodes an ARD [Value] to supported formats and writes it to an [io.Writer].
+//
+// Supported formats are "yaml", "json", "xjson", "xml", "cbor", and "messagepack".
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func Write(value Value, writer io.Writer, format string, reflector *Reflector) error {
+	if metrics := getMetrics(); metrics != nil {
+		counter := countingWriter{writer: writer}
+		start := now()
+		err := write(value, &counter, format, reflector)
+		metrics.Encoded(format, counter.count, since(start), err)
+		return err
+	} else {
+		return write(value, writer, format, reflector)
+	}
+}
+
+func write(value Value, writer io.Writer, format string, reflector *Reflector) error {
+	if format_, ok := getSupportedFormat(format); ok {
+		return format_.write(value, writer, reflector)
+	} else {
+		return fmt.Errorf("unsupported format: %q", format)
+	}
+}
+
+// Encodes an ARD [Value] to YAML and writes it to an [io.Writer]. The value is
+// converted via [ToYAMLDocumentNode], so that complex map keys, bytes, and
+// timestamps are supported.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+//
+// Map keys are sorted and strings are quoted only when necessary, so that the
+// output is deterministic and idiomatic (see the SortKeys and PlainStrings
+// fields of [YAMLNodeOptions]). For other options use [WriteYAMLWithOptions].
+func WriteYAML(value Value, writer io.Writer, reflector *Reflector) error {
+	return WriteYAMLWithOptions(value, writer, reflector, &YAMLNodeOptions{SortKeys: true, PlainStrings: true})
+}
+
+// Like [WriteYAML] but with [YAMLNodeOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteYAMLWithOptions(value Value, writer io.Writer, reflector *Reflector, options *YAMLNodeOptions) error {
+	if node, err := ToYAMLDocumentNodeWithOptions(value, options, reflector); err == nil {
+		encoder := yaml.NewEncoder(writer)
+		if err := encoder.Encode(node); err == nil {
+			return encoder.Close()
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Encodes an ARD [Value] to JSON and writes it to an [io.Writer].
+//
+// Because JSON only supports string keys, the value is first converted via
+// [ValidCopyMapsToStringMaps]. Note that type information that JSON cannot
+// represent will be lost. Use [WriteXJSON] to preserve it.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+//
+// Uses the default [JSONEncodeOptions]. See [WriteJSONWithOptions].
+func WriteJSON(value Value, writer io.Writer, reflector *Reflector) error {
+	return WriteJSONWithOptions(value, writer, reflector, nil)
+}
+
+// Like [WriteJSON] but with [JSONEncodeOptions].
+//
+// Output is deterministic: the same value will always be written the same
+// way, with map keys sorted.
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteJSONWithOptions(value Value, writer io.Writer, reflector *Reflector, options *JSONEncodeOptions) error {
+	if reflector == nil {
+		reflector = DefaultReflector("json")
+	}
+
+	sortKeys := (options != nil) && options.SortKeys
+
+	var value_ Value
+	var err error
+	if sortKeys {
+		// Keys will be converted to strings after sorting
+		value_, err = ValidCopy(value, reflector)
+	} else {
+		value_, err = ValidCopyMapsToStringMaps(value, reflector)
+	}
+
+	if err == nil {
+		if hasScalarCodecs() {
+			value_ = encodeScalars(value_, false)
+		}
+		if value_, err = encodeJSONSpecialFloats(value_, nil, (options != nil) && options.SpecialFloatStrings); err == nil {
+			if sortKeys {
+				value_ = sortJSONMaps(value_)
+			}
+			return encodeJSON(value_, writer, options)
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Encodes an ARD [Value] to JSON with the XJSON extensions and writes it to an
+// [io.Writer].
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func WriteXJSON(value Value, writer io.Writer, reflector *Reflector) error {
+	if value_, err := PrepareForEncodingXJSON(value, false, reflector); err == nil {
+		encoder := getJSONAPI().NewEncoder(writer)
+		return encoder.Encode(value_)
+	} else {
+		return err
+	}
+}
+
+// Like [WriteXJSON] but with [JSONEncodeOptions]. SpecialFloatStrings is
+// ignored, as XJSON always supports NaN and infinite floats.
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteXJSONWithOptions(value Value, writer io.Writer, reflector *Reflector, options *JSONEncodeOptions) error {
+	if value_, err := PrepareForEncodingXJSON(value, false, reflector); err == nil {
+		if (options != nil) && options.SortKeys {
+			value_ = sortXJSONMaps(value_)
+		}
+		return encodeJSON(value_, writer, options)
+	} else {
+		return err
+	}
+}
+
+// Like [WriteXJSON] but for minimal XJSON. See [PackMinimalXJSON].
+func WriteMinimalXJSON(value Value, writer io.Writer, reflector *Reflector) error {
+	if value_, err := PrepareForEncodingMinimalXJSON(value, false, reflector); err == nil {
+		encoder := getJSONAPI().NewEncoder(writer)
+		return encoder.Encode(value_)
+	} else {
+		return err
+	}
+}
+
+// Encodes an ARD [Value] to XML and writes it to an [io.Writer].
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func WriteXML(value Value, writer io.Writer, reflector *Reflector) error {
+	if value_, err := PrepareForEncodingXML(value, false, reflector); err == nil {
+		if _, err := io.WriteString(writer, xml.Header); err == nil {
+			encoder := xml.NewEncoder(writer)
+			encoder.Indent("", "")
+			return encoder.Encode(value_)
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Encodes an ARD [Value] to CBOR and writes it to an [io.Writer].
+//
+// Uses the default [CBOREncodeOptions]. See [WriteCBORWithOptions].
+func WriteCBOR(value Value, writer io.Writer) error {
+	if hasScalarCodecs() {
+		value = encodeScalars(Copy(value), true)
+	}
+	encoder := cbor.NewEncoder(writer)
+	return encoder.Encode(value)
+}
+
+//
+// CBOREncodeOptions
+//
+
+type CBOREncodeOptions struct {
+	// When true the output is encoded to Base64, for embedding in text
+	// mediums, e.g. environment variables or JSON strings. It can be read via
+	// the base64 argument of [ReadCBOR].
+	Base64 bool
+}
+
+// Like [WriteCBOR] but with [CBOREncodeOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteCBORWithOptions(value Value, writer io.Writer, options *CBOREncodeOptions) error {
+	if (options != nil) && options.Base64 {
+		return writeBase64(writer, func(writer io.Writer) error {
+			return WriteCBOR(value, writer)
+		})
+	} else {
+		return WriteCBOR(value, writer)
+	}
+}
+
+// Encodes an ARD [Value] to MessagePack and writes it to an [io.Writer].
+//
+// Uses the default [MessagePackEncodeOptions]. See
+// [WriteMessagePackWithOptions].
+func WriteMessagePack(value Value, writer io.Writer) error {
+	if hasScalarCodecs() {
+		value = encodeScalars(Copy(value), true)
+	}
+	encoder := NewMessagePackEncoder(writer)
+	return encoder.Encode(value)
+}
+
+// Like [WriteMessagePack] but with [MessagePackEncodeOptions].
+//
+// The options argument can be nil, in which case default options will be used.
+func WriteMessagePackWithOptions(value Value, writer io.Writer, options *MessagePackEncodeOptions) error {
+	if (options != nil) && options.Base64 {
+		return writeBase64(writer, func(writer io.Writer) error {
+			return WriteMessagePack(value, writer)
+		})
+	} else {
+		return WriteMessagePack(value, writer)
+	}
+}
@@ -1,11 +1,12 @@
 package ard
 
 import (
+	"bytes"
 	"encoding/json"
 	"strconv"
 	"strings"
 
-	"github.com/tliron/kutil/util"
+	"github.com/tliron/yamlkeys"
 )
 
 /*
@@ -15,10 +16,14 @@ in JSON:
 1) integers and unsigned integers are preserved as distinct from floats
 2) raw bytes can be encoded using Base64
 3) maps are allowed to have non-string keys
+4) NaN and infinite floats are supported
+
+Map keys beginning with "$ard." or "$$" are escaped by prepending "$", so that
+they cannot be confused with the codes below.
 
 This particular implementation is not designed for performance but rather for
 widest compability, relying on Go's built-in JSON support or 3rd-party
-implementations compatible with it.
+implementations compatible with it (see SetJSONAPI).
 
 Inspired by: https://www.mongodb.com/docs/manual/reference/mongodb-extended-json/
 */
@@ -26,6 +31,7 @@ Inspired by: https://www.mongodb.com/docs/manual/reference/mongodb-extended-json
 const (
 	XJSONIntegerCode  = "$ard.integer"
 	XJSONUIntegerCode = "$ard.uinteger"
+	XJSONFloatCode    = "$ard.float"
 	XJSONBytesCode    = "$ard.bytes"
 	XJSONMapCode      = "$ard.map"
 )
@@ -40,6 +46,10 @@ const (
 // The reflector argument can be nil, in which case a
 // default reflector will be used.
 func PrepareForEncodingXJSON(value Value, inPlace bool, reflector *Reflector) (any, error) {
+	if reflector == nil {
+		reflector = DefaultReflector("xjson")
+	}
+
 	if !inPlace {
 		var err error
 		if value, err = ValidCopy(value, reflector); err != nil {
@@ -51,7 +61,75 @@ func PrepareForEncodingXJSON(value Value, inPlace bool, reflector *Reflector) (a
 	return value, nil
 }
 
+// Like [PrepareForEncodingXJSON] but for minimal XJSON. See [PackMinimalXJSON].
+func PrepareForEncodingMinimalXJSON(value Value, inPlace bool, reflector *Reflector) (any, error) {
+	if reflector == nil {
+		reflector = DefaultReflector("xjson")
+	}
+
+	if !inPlace {
+		var err error
+		if value, err = ValidCopy(value, reflector); err != nil {
+			return nil, err
+		}
+	}
+
+	value, _ = PackMinimalXJSON(value)
+	return value, nil
+}
+
+// Converts an ARD [Value] to a structure that can be encoded as XJSON via
+// [json.Marshal]. Returns true if the value was converted.
 func PackXJSON(value Value) (any, bool) {
+	return packXJSON(value, false)
+}
+
+// Like [PackXJSON] but for minimal XJSON.
+//
+// In minimal XJSON integers are written as plain JSON numbers when their
+// magnitude is at most 2^53 (beyond which many JSON implementations lose
+// precision). To keep the encoding lossless all floats are written with a
+// decimal point or exponent (see [XJSONFloat]), e.g. 1.0 is written as
+// "1.0" rather than "1". It must thus be decoded with [ReadMinimalXJSON] or
+// [DecodeMinimalXJSON].
+func PackMinimalXJSON(value Value) (any, bool) {
+	return packXJSON(value, true)
+}
+
+func packXJSON(value Value, minimal bool) (any, bool) {
+	switch value_ := value.(type) {
+	case float64:
+		if _, ok := formatSpecialFloat(value_); ok {
+			return XJSONSpecialFloat(value_), true
+		}
+
+	case float32:
+		if _, ok := formatSpecialFloat(float64(value_)); ok {
+			return XJSONSpecialFloat(value_), true
+		}
+	}
+
+	if minimal {
+		switch value_ := value.(type) {
+		case int, int64, int32, int16, int8:
+			if integer := toNumber(value_).signed; (integer >= -maxExactFloatInteger) && (integer <= maxExactFloatInteger) {
+				return integer, true
+			}
+
+		case float64:
+			return XJSONFloat(value_), true
+
+		case float32:
+			// Use the shortest representation of the float32
+			float, _ := strconv.ParseFloat(strconv.FormatFloat(float64(value_), 'g', -1, 32), 64)
+			return XJSONFloat(float), true
+		}
+	}
+
+	if codec, ok := getScalarCodec(value); ok {
+		return XJSONScalar{codec.TypeName, codec.ToString(value)}, true
+	}
+
 	switch value_ := value.(type) {
 	case int:
 		return XJSONInteger(int64(value_)), true
@@ -84,7 +162,7 @@ func PackXJSON(value Value) (any, bool) {
 
 		for index, element := range value_ {
 			var converted_ bool
-			element, converted_ = PackXJSON(element)
+			element, converted_ = packXJSON(element, minimal)
 			convertedList[index] = element
 			if converted_ {
 				converted = true
@@ -96,16 +174,16 @@ func PackXJSON(value Value) (any, bool) {
 		}
 
 	case StringMap:
-		if escapedValue, ok := escapeXjsonStringMap(value_); ok {
-			return escapedValue, true
-		}
-
 		convertedStringMap := make(StringMap)
 		converted := false
 		var converted_ bool
 
 		for key, value__ := range value_ {
-			if convertedStringMap[key], converted_ = PackXJSON(value__); converted_ {
+			key_ := escapeXjsonKey(key)
+			if key_ != key {
+				converted = true
+			}
+			if convertedStringMap[key_], converted_ = packXJSON(value__, minimal); converted_ {
 				converted = true
 			}
 		}
@@ -115,27 +193,23 @@ func PackXJSON(value Value) (any, bool) {
 		}
 
 	case Map:
-		if escapedValue, ok := escapeXjsonMap(value_); ok {
-			return escapedValue, true
-		}
-
 		// We'll build two maps at the same time, but only return one
 		convertedStringMap := make(StringMap)
 		convertedXJsonMap := make(XJSONMap)
 		useXJsonMap := false
 
 		for key, value__ := range value_ {
-			value__, _ = PackXJSON(value__)
+			value__, _ = packXJSON(value__, minimal)
 
 			if key_, ok := key.(string); ok {
 				convertedXJsonMap[key] = value__
 
 				// We can stop building the stringMap if we switched to xJsonMap
 				if !useXJsonMap {
-					convertedStringMap[key_] = value__
+					convertedStringMap[escapeXjsonKey(key_)] = value__
 				}
 			} else {
-				key, _ = PackXJSON(key)
+				key, _ = packXJSON(key, minimal)
 				convertedXJsonMap[key] = value__
 				useXJsonMap = true
 			}
@@ -183,10 +257,14 @@ func UnpackXJSON(value any, useStringMaps bool) (Value, bool) {
 				return integer, true
 			} else if uinteger, ok := UnpackXJSONUInteger(value_); ok {
 				return uinteger, true
+			} else if float, ok := UnpackXJSONFloat(value_); ok {
+				return float, true
 			} else if bytes, ok := UnpackXJSONBytes(value_); ok {
 				return bytes, true
 			} else if map_, ok := UnpackXJSONMap(value_, useStringMaps); ok {
 				return map_, true
+			} else if scalar, ok := UnpackXJSONScalar(value_); ok {
+				return scalar, true
 			} else {
 				// Handle escape code:
 				// $$ -> $
@@ -206,14 +284,14 @@ func UnpackXJSON(value any, useStringMaps bool) (Value, bool) {
 			map_ := make(StringMap)
 			for key, value__ := range value_ {
 				value__, _ = UnpackXJSON(value__, useStringMaps)
-				map_[key] = value__
+				map_[unescapeXjsonKey(key)] = value__
 			}
 			return map_, true
 		} else {
 			map_ := make(Map)
 			for key, value__ := range value_ {
 				value__, _ = UnpackXJSON(value__, useStringMaps)
-				map_[key] = value__
+				map_[unescapeXjsonKey(key)] = value__
 			}
 			return map_, true
 		}
@@ -246,6 +324,57 @@ func UnpackXJSONInteger(code StringMap) (int64, bool) {
 	return 0, false
 }
 
+//
+// XJSONFloat
+//
+
+// Used for minimal XJSON. See [PackMinimalXJSON].
+type XJSONFloat float64
+
+// Always includes a decimal point or exponent, so that the number can be
+// distinguished from an integer.
+//
+// ([json.Marshaler] interface)
+func (self XJSONFloat) MarshalJSON() ([]byte, error) {
+	if bytes_, err := json.Marshal(float64(self)); err == nil {
+		if !bytes.ContainsAny(bytes_, ".eE") {
+			bytes_ = append(bytes_, '.', '0')
+		}
+		return bytes_, nil
+	} else {
+		return nil, err
+	}
+}
+
+//
+// XJSONSpecialFloat
+//
+
+// For NaN and infinite floats, which JSON cannot represent.
+type XJSONSpecialFloat float64
+
+// Encoded as "NaN", "Infinity", or "-Infinity".
+//
+// ([json.Marshaler] interface)
+func (self XJSONSpecialFloat) MarshalJSON() ([]byte, error) {
+	if token, ok := formatSpecialFloat(float64(self)); ok {
+		return json.Marshal(StringMap{
+			XJSONFloatCode: token,
+		})
+	} else {
+		return json.Marshal(float64(self))
+	}
+}
+
+func UnpackXJSONFloat(code StringMap) (float64, bool) {
+	if float, ok := code[XJSONFloatCode]; ok {
+		if float_, ok := float.(string); ok {
+			return parseSpecialFloat(float_)
+		}
+	}
+	return 0, false
+}
+
 //
 // XJSONUInteger
 //
@@ -279,14 +408,14 @@ type XJSONBytes []byte
 // ([json.Marshaler] interface)
 func (self XJSONBytes) MarshalJSON() ([]byte, error) {
 	return json.Marshal(StringMap{
-		XJSONBytesCode: util.ToBase64(self),
+		XJSONBytesCode: encodeTextBytes(self),
 	})
 }
 
 func UnpackXJSONBytes(code StringMap) ([]byte, bool) {
 	if bytes, ok := code[XJSONBytesCode]; ok {
 		if bytes_, ok := bytes.(string); ok {
-			if bytes__, err := util.FromBase64(bytes_); err == nil {
+			if bytes__, err := decodeTextBytes(bytes_); err == nil {
 				return bytes__, true
 			}
 		}
@@ -318,7 +447,12 @@ func UnpackXJSONMap(code StringMap, useStringMaps bool) (Map, bool) {
 			map___ := make(Map)
 			for _, entry := range map__ {
 				if entry_, ok := UnpackXJSONMapEntry(entry, useStringMaps); ok {
-					map___[entry_.Key] = entry_.Value
+					// Complex keys must be wrapped
+					if key, err := MakeKey(entry_.Key); err == nil {
+						map___[key] = entry_.Value
+					} else {
+						return nil, false
+					}
 				} else {
 					return nil, false
 				}
@@ -354,40 +488,41 @@ func UnpackXJSONMapEntry(entry Value, useStringMaps bool) (*XJSONMapEntry, bool)
 	return nil, false
 }
 
-func escapeXjsonMap(map_ Map) (Value, bool) {
-	if len(map_) == 1 {
-		if value, ok := map_[XJSONIntegerCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONIntegerCode: value}, true
-		} else if value, ok := map_[XJSONUIntegerCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONUIntegerCode: value}, true
-		} else if value, ok := map_[XJSONBytesCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONBytesCode: value}, true
-		} else if value, ok := map_[XJSONMapCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONMapCode: value}, true
-		}
+func unpackXjsonKey(key Value) Value {
+	switch key_ := key.(type) {
+	case XJSONInteger:
+		return int64(key_)
+	case XJSONUInteger:
+		return uint64(key_)
+	case XJSONFloat:
+		return float64(key_)
+	case XJSONSpecialFloat:
+		return float64(key_)
+	case XJSONBytes:
+		return []byte(key_)
+	case XJSONMap:
+		return Map(key_)
+	default:
+		return yamlkeys.KeyData(key)
 	}
-	return nil, false
 }
 
-func escapeXjsonStringMap(map_ StringMap) (Value, bool) {
-	if len(map_) == 1 {
-		if value, ok := map_[XJSONIntegerCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONIntegerCode: value}, true
-		} else if value, ok := map_[XJSONUIntegerCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONUIntegerCode: value}, true
-		} else if value, ok := map_[XJSONBytesCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONBytesCode: value}, true
-		} else if value, ok := map_[XJSONMapCode]; ok {
-			value, _ = PackXJSON(value)
-			return StringMap{"$" + XJSONMapCode: value}, true
-		}
+// Keys beginning with "$ard." (which might be confused with our codes) or
+// with "$$" (which might be confused with our escaping) are escaped by
+// prepending "$"
+func escapeXjsonKey(key string) string {
+	if strings.HasPrefix(key, "$ard.") || strings.HasPrefix(key, "$$") {
+		return "$" + key
+	} else {
+		return key
+	}
+}
+
+// $$ -> $
+func unescapeXjsonKey(key string) string {
+	if strings.HasPrefix(key, "$$") {
+		return key[1:]
+	} else {
+		return key
 	}
-	return nil, false
 }
@@ -0,0 +1,103 @@
+package ard
+
+import (
+	"bytes"
+	"testing"
+)
+
+var xjsonKeys = []string{
+	"plain",
+	"$",
+	"$x",
+	"$ard",
+	"$ard.",
+	"$ard.integer",
+	"$ard.uinteger",
+	"$ard.bytes",
+	"$ard.map",
+	"$ard.foo",
+	"$$",
+	"$$x",
+	"$$ard.map",
+	"$$$ard.integer",
+}
+
+func TestXJSONKeyEscaping(t *testing.T) {
+	for _, key := range xjsonKeys {
+		for name, value := range map[string]Value{
+			"single-entry map":        Map{key: int64(1)},
+			"multi-entry map":         Map{key: int64(1), "other": "x"},
+			"single-entry string map": StringMap{key: int64(1)},
+			"multi-entry string map":  StringMap{key: int64(1), "other": "x"},
+			"$ard.map entry key":      Map{key: int64(1), int64(2): "x"},
+			"$ard.map entry value":    Map{int64(2): Map{key: "x"}},
+			"nested $ard.map entries": Map{int64(1): Map{key: Map{int64(2): key}}},
+			"nested":                  Map{key: Map{key: List{Map{key: int64(1)}}}},
+		} {
+			testXJSONRoundtrip(t, key+" in "+name, value)
+		}
+	}
+}
+
+func TestEscapeXjsonKey(t *testing.T) {
+	for _, test := range []struct {
+		key     string
+		escaped string
+	}{
+		{"plain", "plain"},
+		{"$", "$"},
+		{"$x", "$x"},
+		{"$ard", "$ard"},
+		{"$ard.", "$$ard."},
+		{"$ard.map", "$$ard.map"},
+		{"$ard.foo", "$$ard.foo"},
+		{"$$", "$$$"},
+		{"$$x", "$$$x"},
+		{"$$ard.map", "$$$ard.map"},
+	} {
+		if escaped := escapeXjsonKey(test.key); escaped != test.escaped {
+			t.Errorf("%q: expected escaping to %q, got %q", test.key, test.escaped, escaped)
+		}
+		if unescaped := unescapeXjsonKey(test.escaped); unescaped != test.key {
+			t.Errorf("%q: expected unescaping to %q, got %q", test.escaped, test.key, unescaped)
+		}
+	}
+}
+
+func testXJSONRoundtrip(t *testing.T, name string, value Value) {
+	t.Helper()
+
+	for _, minimal := range []bool{false, true} {
+		name_ := name
+		if minimal {
+			name_ += " (minimal)"
+		}
+
+		var buffer bytes.Buffer
+		var err error
+		if minimal {
+			err = WriteMinimalXJSON(value, &buffer, nil)
+		} else {
+			err = WriteXJSON(value, &buffer, nil)
+		}
+		if err != nil {
+			t.Errorf("%s: %s", name_, err.Error())
+			continue
+		}
+
+		var value_ Value
+		if minimal {
+			value_, err = DecodeMinimalXJSON(buffer.Bytes(), false)
+		} else {
+			value_, err = DecodeXJSON(buffer.Bytes(), false)
+		}
+		if err != nil {
+			t.Errorf("%s: %s", name_, err.Error())
+			continue
+		}
+
+		if expected := CopyStringMapsToMaps(value); !Equals(value_, expected) {
+			t.Errorf("%s: expected %s, got %s from %s", name_, ValueToString(expected), ValueToString(value_), buffer.String())
+		}
+	}
+}
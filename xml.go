@@ -31,6 +31,10 @@ const (
 // The reflector argument can be nil, in which case a
 // default reflector will be used.
 func PrepareForEncodingXML(value Value, inPlace bool, reflector *Reflector) (any, error) {
+	if reflector == nil {
+		reflector = DefaultReflector("xml")
+	}
+
 	if !inPlace {
 		var err error
 		if value, err = ValidCopy(value, reflector); err != nil {
@@ -50,6 +54,10 @@ func PackXML(value Value) any {
 		return XMLBytes{bytes}
 	}
 
+	if codec, ok := getScalarCodec(value); ok {
+		return codec.ToString(value)
+	}
+
 	value_ := reflect.ValueOf(value)
 
 	switch value_.Type().Kind() {
@@ -79,85 +87,12 @@ func PackXML(value Value) any {
 	return value
 }
 
+// Unpacks an XML element to an ARD [Value].
+//
+// Unknown elements within maps are skipped with a warning. See [SetWarnFunc].
 func UnpackXML(element *etree.Element) (Value, error) {
-	switch element.Tag {
-	case XMLNilTag:
-		return nil, nil
-
-	case XMLListTag:
-		children := element.ChildElements()
-		list := make(List, len(children))
-		for index, entry := range children {
-			if entry_, err := UnpackXML(entry); err == nil {
-				list[index] = entry_
-			} else {
-				return nil, err
-			}
-		}
-		return list, nil
-
-	case XMLMapTag:
-		map_ := make(Map)
-		for _, entry := range element.ChildElements() {
-			if entry_, err := NewXMLMapEntry(entry); err == nil {
-				//fmt.Printf("%T\n", entry_.Key)
-				map_[entry_.key] = entry_.value
-			} else {
-				return nil, err
-			}
-		}
-		return map_, nil
-
-	case "string":
-		return element.Text(), nil
-
-	case "int":
-		if int_, err := strconv.ParseInt(element.Text(), 10, 64); err == nil {
-			return int64(int_), nil
-		} else {
-			return nil, err
-		}
-
-	case "int64":
-		return strconv.ParseInt(element.Text(), 10, 64)
-
-	case "int32":
-		return strconv.ParseInt(element.Text(), 10, 32)
-
-	case "int8":
-		return strconv.ParseInt(element.Text(), 10, 8)
-
-	case "uint":
-		if uint_, err := strconv.ParseUint(element.Text(), 10, 64); err == nil {
-			return uint64(uint_), nil
-		} else {
-			return nil, err
-		}
-
-	case "uint64":
-		return strconv.ParseUint(element.Text(), 10, 64)
-
-	case "uint32":
-		return strconv.ParseUint(element.Text(), 10, 32)
-
-	case "uint8":
-		return strconv.ParseUint(element.Text(), 10, 8)
-
-	case "float64":
-		return strconv.ParseFloat(element.Text(), 64)
-
-	case "float32":
-		return strconv.ParseFloat(element.Text(), 32)
-
-	case "bool":
-		return strconv.ParseBool(element.Text())
-
-	case "bytes":
-		return util.FromBase64(element.Text())
-
-	default:
-		return nil, fmt.Errorf("element has unsupported tag: %s", xmlElementToString(element))
-	}
+	var decoder xmlDecoder
+	return decoder.unpack(element, nil)
 }
 
 //
@@ -213,7 +148,7 @@ var bytesStart = xml.StartElement{Name: xml.Name{Local: XMLBytesTag}}
 // ([xml.Marshaler] interface)
 func (self XMLBytes) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
 	if err := encoder.EncodeToken(bytesStart); err == nil {
-		if err := encoder.EncodeToken(xml.CharData(util.StringToBytes(util.ToBase64(self.bytes)))); err == nil {
+		if err := encoder.EncodeToken(xml.CharData(util.StringToBytes(encodeTextBytes(self.bytes)))); err == nil {
 			return encoder.EncodeToken(bytesStart.End())
 		} else {
 			return err
@@ -293,54 +228,209 @@ func (self XMLMapEntry) MarshalXML(encoder *xml.Encoder, start xml.StartElement)
 }
 
 func NewXMLMapEntry(element *etree.Element) (XMLMapEntry, error) {
-	var self XMLMapEntry
+	var decoder xmlDecoder
+	entry, _, err := decoder.newMapEntry(element, nil)
+	return entry, err
+}
+
+// Utilities
+
+// The element is copied, because setting it as a document root would detach
+// it from its parent
+func xmlElementToString(element *etree.Element) string {
+	document := etree.NewDocument()
+	document.SetRoot(element.Copy())
+	if s, err := document.WriteToString(); err == nil {
+		return s
+	} else {
+		return element.GetPath()
+	}
+}
+
+//
+// xmlDecoder
+//
+
+type xmlDecoder struct {
+	// When not nil, localized errors are recorded instead of returned (see
+	// [DecodeTolerant])
+	diagnostics *Diagnostics
+}
+
+func (self *xmlDecoder) unpack(element *etree.Element, path Path) (Value, error) {
+	switch element.Tag {
+	case XMLNilTag:
+		return nil, nil
+
+	case XMLListTag:
+		children := element.ChildElements()
+		list := make(List, len(children))
+		for index, entry := range children {
+			if entry_, err := self.unpack(entry, path.AppendList(index)); err == nil {
+				list[index] = entry_
+			} else {
+				return nil, err
+			}
+		}
+		return list, nil
+
+	case XMLMapTag:
+		map_ := make(Map)
+		for _, entry := range element.ChildElements() {
+			if entry.Tag != XMLMapEntryTag {
+				if warn := getWarnFunc(); warn != nil {
+					warn("skipped unknown XML map element", "tag", entry.Tag)
+				}
+				if self.diagnostics != nil {
+					self.diagnostics.addWarning(path, "skipped unknown XML map element", "tag", entry.Tag)
+				}
+				continue
+			}
+
+			if entry_, ok, err := self.newMapEntry(entry, path); err == nil {
+				if ok {
+					if err := putInMap(map_, entry_.key, entry_.value, path.AppendKey(entry_.key)); err != nil {
+						return nil, err
+					}
+				}
+			} else {
+				return nil, err
+			}
+		}
+		return map_, nil
+
+	case "string":
+		return element.Text(), nil
+
+	case "int":
+		if int_, err := strconv.ParseInt(element.Text(), 10, 64); err == nil {
+			return int64(int_), nil
+		} else {
+			return self.invalid(element, path, err)
+		}
+
+	case "int64":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseInt(text, 10, 64) })
+
+	case "int32":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseInt(text, 10, 32) })
+
+	case "int8":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseInt(text, 10, 8) })
+
+	case "uint":
+		if uint_, err := strconv.ParseUint(element.Text(), 10, 64); err == nil {
+			return uint64(uint_), nil
+		} else {
+			return self.invalid(element, path, err)
+		}
+
+	case "uint64":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseUint(text, 10, 64) })
+
+	case "uint32":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseUint(text, 10, 32) })
+
+	case "uint8":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseUint(text, 10, 8) })
+
+	case "float64":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseFloat(text, 64) })
+
+	case "float32":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseFloat(text, 32) })
+
+	case "bool":
+		return self.scalar(element, path, func(text string) (Value, error) { return strconv.ParseBool(text) })
+
+	case "bytes":
+		return self.scalar(element, path, func(text string) (Value, error) { return decodeTextBytes(text) })
+
+	default:
+		return self.invalid(element, path, fmt.Errorf("element has unsupported tag: %s", xmlElementToString(element)))
+	}
+}
+
+// Returns false if the entry should be skipped
+func (self *xmlDecoder) newMapEntry(element *etree.Element, path Path) (XMLMapEntry, bool, error) {
+	var entry XMLMapEntry
 
 	if element.Tag == XMLMapEntryTag {
+		var valueElement *etree.Element
 		for _, child := range element.ChildElements() {
 			switch child.Tag {
 			case XMLMapEntryKeyTag:
-				if key, err := getXmlElementSingleChild(child); err == nil {
-					self.key = key
+				// Keys must be valid, even when tolerant
+				var decoder xmlDecoder
+				if key, err := decoder.singleChild(child, path); err == nil {
+					entry.key = key
+				} else if err := self.tolerate(path, err); err == nil {
+					return entry, false, nil
 				} else {
-					return self, err
+					return entry, false, err
 				}
 
 			case XMLMapEntryValueTag:
-				if value, err := getXmlElementSingleChild(child); err == nil {
-					self.value = value
-				} else {
-					return self, err
-				}
+				valueElement = child
 
 			default:
-				return self, fmt.Errorf("element has unsupported tag: %s", xmlElementToString(element))
+				if err := self.tolerate(path, fmt.Errorf("element has unsupported tag: %s", xmlElementToString(element))); err != nil {
+					return entry, false, err
+				}
 			}
 		}
-	}
-
-	return self, nil
-}
-
-// Utilities
 
-func xmlElementToString(element *etree.Element) string {
-	document := etree.NewDocument()
-	document.SetRoot(element)
-	if s, err := document.WriteToString(); err == nil {
-		return s
-	} else {
-		return element.GetPath()
+		// The value's path requires the key
+		if valueElement != nil {
+			if value, err := self.singleChild(valueElement, path.AppendKey(entry.key)); err == nil {
+				entry.value = value
+			} else {
+				return entry, false, err
+			}
+		}
 	}
+
+	return entry, true, nil
 }
 
-func getXmlElementSingleChild(element *etree.Element) (any, error) {
+func (self *xmlDecoder) singleChild(element *etree.Element, path Path) (Value, error) {
 	children := element.ChildElements()
 	length := len(children)
 	if length == 1 {
-		return UnpackXML(children[0])
+		return self.unpack(children[0], path)
 	} else if length == 0 {
 		return nil, nil
 	} else {
-		return nil, fmt.Errorf("element has more than one child: %s", xmlElementToString(element))
+		return self.invalid(element, path, fmt.Errorf("element has more than one child: %s", xmlElementToString(element)))
 	}
 }
+
+func (self *xmlDecoder) scalar(element *etree.Element, path Path, parse func(text string) (Value, error)) (Value, error) {
+	if value, err := parse(element.Text()); err == nil {
+		return value, nil
+	} else {
+		return self.invalid(element, path, err)
+	}
+}
+
+// When tolerant, records the error and returns an [*InvalidValue] in its
+// stead
+func (self *xmlDecoder) invalid(element *etree.Element, path Path, err error) (Value, error) {
+	if self.diagnostics == nil {
+		return nil, err
+	}
+
+	self.diagnostics.addError(path, -1, -1, err)
+	return &InvalidValue{xmlElementToString(element), err}, nil
+}
+
+// When tolerant, records the error and returns nil, otherwise returns the
+// error
+func (self *xmlDecoder) tolerate(path Path, err error) error {
+	if self.diagnostics == nil {
+		return err
+	}
+
+	self.diagnostics.addError(path, -1, -1, err)
+	return nil
+}
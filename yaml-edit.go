@@ -0,0 +1,332 @@
+package ard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+//
+// PathValueEdit
+//
+
+type PathValueEdit struct {
+	Path  Path
+	Value Value
+}
+
+// Sets values in YAML source code by rewriting only the text of the affected
+// scalars, thus preserving formatting, comments, and ordering elsewhere. Only
+// the first document in the source is edited.
+//
+// Each edit must refer to an existing scalar (not via an alias or merge key)
+// and its new value must be a scalar. The quoting style of the original scalar
+// is preserved when possible, as are its anchor and explicit tag. A scalar
+// with an explicit standard tag (e.g. "!!int") can only be set to a value of
+// that type. Block scalars (literal and folded), empty plain scalars (e.g. an
+// implicit null), and plain scalars that span multiple lines are not supported.
+//
+// Returns an error if any edit fails, in which case no edits are applied.
+func EditYAMLInPlace(source []byte, edits []PathValueEdit) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, err
+	}
+
+	lineOffsets := getLineOffsets(source)
+
+	type span struct {
+		start int
+		end   int
+		text  []byte
+	}
+
+	spans := make([]span, len(edits))
+	for index, edit := range edits {
+		node := findYAMLValueNode(&root, edit.Path)
+		if node == nil {
+			return nil, fmt.Errorf("%s: not found", edit.Path.String())
+		} else if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: not a scalar", edit.Path.String())
+		}
+
+		start, err := getYAMLOffset(source, lineOffsets, node.Line, node.Column)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", edit.Path.String(), err)
+		}
+
+		if (node.Anchor != "") || (node.Style&yaml.TaggedStyle != 0) {
+			// The node's position is that of its properties
+			start = skipYAMLNodeProperties(source, start)
+		}
+
+		end, err := getYAMLScalarEnd(source, start, node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", edit.Path.String(), err)
+		}
+
+		text, err := encodeYAMLScalar(edit.Value, node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", edit.Path.String(), err)
+		}
+
+		spans[index] = span{start, end, text}
+	}
+
+	sort.Slice(spans, func(i int, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, span := range spans {
+		if span.start < last {
+			return nil, errors.New("overlapping edits")
+		}
+		buffer.Write(source[last:span.start])
+		buffer.Write(span.text)
+		last = span.end
+	}
+	buffer.Write(source[last:])
+
+	return buffer.Bytes(), nil
+}
+
+// Utils
+
+// Unlike [FindYAMLNode] will only return exact matches of value nodes.
+// Does not follow aliases or merge keys.
+func findYAMLValueNode(node *yaml.Node, path Path) *yaml.Node {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	for _, element := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			var found *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyNode := node.Content[i]
+				if keyNode.Kind != yaml.ScalarNode {
+					continue
+				}
+
+				switch element.Type {
+				case FieldPathType, MapPathType:
+					if (keyNode.Tag == "!!str") && (keyNode.Value == element.Value.(string)) {
+						found = node.Content[i+1]
+					}
+
+				case KeyPathType, ListPathType:
+					// Integer list indexes are also used as map keys
+					if key, ok := decodeYAMLScalarKey(keyNode); ok && equalsNumerically(key, element.Value) {
+						found = node.Content[i+1]
+					}
+				}
+
+				if found != nil {
+					break
+				}
+			}
+
+			if found == nil {
+				return nil
+			}
+			node = found
+
+		case yaml.SequenceNode:
+			switch element.Type {
+			case ListPathType, SequencedListPathType:
+				index := element.Value.(int)
+				if (index < 0) || (index >= len(node.Content)) {
+					return nil
+				}
+				node = node.Content[index]
+				if element.Type == SequencedListPathType {
+					if (node.Kind == yaml.MappingNode) && (len(node.Content) == 2) {
+						node = node.Content[1]
+					} else {
+						return nil
+					}
+				}
+
+			default:
+				return nil
+			}
+
+		default:
+			return nil
+		}
+	}
+
+	return node
+}
+
+// Byte offsets of the beginnings of lines
+func getLineOffsets(source []byte) []int {
+	offsets := []int{0}
+	for index, byte_ := range source {
+		if byte_ == '\n' {
+			offsets = append(offsets, index+1)
+		}
+	}
+	return offsets
+}
+
+// Line and column are 1-based, with the column counted in runes
+func getYAMLOffset(source []byte, lineOffsets []int, line int, column int) (int, error) {
+	if (line < 1) || (line > len(lineOffsets)) {
+		return 0, fmt.Errorf("line out of range: %d", line)
+	}
+
+	offset := lineOffsets[line-1]
+	for ; column > 1; column-- {
+		if (offset >= len(source)) || (source[offset] == '\n') {
+			return 0, fmt.Errorf("column out of range: %d:%d", line, column)
+		}
+		_, size := utf8.DecodeRune(source[offset:])
+		offset += size
+	}
+
+	return offset, nil
+}
+
+// Skips the anchor and tag tokens (in any order) and the whitespace and
+// comments that follow them
+func skipYAMLNodeProperties(source []byte, start int) int {
+	length := len(source)
+
+	for (start < length) && ((source[start] == '&') || (source[start] == '!')) {
+		for (start < length) && !isYAMLWhitespace(source[start]) {
+			start++
+		}
+
+		for start < length {
+			if isYAMLWhitespace(source[start]) {
+				start++
+			} else if source[start] == '#' {
+				for (start < length) && (source[start] != '\n') {
+					start++
+				}
+			} else {
+				break
+			}
+		}
+	}
+
+	return start
+}
+
+func isYAMLWhitespace(byte_ byte) bool {
+	switch byte_ {
+	case ' ', '\t', '\r', '\n':
+		return true
+	default:
+		return false
+	}
+}
+
+func getYAMLScalarEnd(source []byte, start int, node *yaml.Node) (int, error) {
+	length := len(source)
+
+	switch node.Style &^ yaml.TaggedStyle {
+	case 0, yaml.FlowStyle:
+		if node.Value == "" {
+			return 0, errors.New("unsupported empty plain scalar")
+		}
+
+		// Plain scalars have no escaping
+		end := start + len(node.Value)
+		if (end <= length) && (string(source[start:end]) == node.Value) {
+			return end, nil
+		} else {
+			return 0, errors.New("unsupported multi-line plain scalar")
+		}
+
+	case yaml.DoubleQuotedStyle:
+		if (start < length) && (source[start] == '"') {
+			for index := start + 1; index < length; index++ {
+				switch source[index] {
+				case '\\':
+					index++
+				case '"':
+					return index + 1, nil
+				}
+			}
+		}
+
+	case yaml.SingleQuotedStyle:
+		if (start < length) && (source[start] == '\'') {
+			for index := start + 1; index < length; index++ {
+				if source[index] == '\'' {
+					if (index+1 < length) && (source[index+1] == '\'') {
+						// Escaped quote
+						index++
+					} else {
+						return index + 1, nil
+					}
+				}
+			}
+		}
+
+	default:
+		return 0, errors.New("unsupported block scalar")
+	}
+
+	return 0, errors.New("malformed quoted scalar")
+}
+
+func encodeYAMLScalar(value Value, original *yaml.Node) ([]byte, error) {
+	if node, ok := ToYAMLNode(value, false); ok {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("not a scalar: %s", GetTypeName(value))
+		}
+
+		if (original.Style&yaml.TaggedStyle != 0) && strings.HasPrefix(original.Tag, "!!") && (node.Tag != original.Tag) {
+			// The original tag is kept, so it must agree with the new value
+			return nil, fmt.Errorf("explicitly tagged as %q, not %q: %s", original.Tag, node.Tag, GetTypeName(value))
+		}
+
+		if node.Tag == "!!str" {
+			// Preserve the original quoting style (the encoder will quote if necessary)
+			switch original.Style &^ yaml.TaggedStyle {
+			case 0, yaml.FlowStyle:
+				node.Style = 0
+			case yaml.SingleQuotedStyle:
+				node.Style = yaml.SingleQuotedStyle
+			}
+		}
+
+		if text, err := yaml.Marshal(node); err == nil {
+			text = bytes.TrimSuffix(text, []byte{'\n'})
+			if bytes.ContainsRune(text, '\n') || ((node.Style == 0) && bytes.ContainsAny(text, ",[]{}")) {
+				// Fall back to double quotes, which escape newlines and are safe
+				// in flow collections
+				node.Style = yaml.DoubleQuotedStyle
+				if text, err = yaml.Marshal(node); err == nil {
+					text = bytes.TrimSuffix(text, []byte{'\n'})
+				} else {
+					return nil, err
+				}
+			}
+
+			if bytes.ContainsRune(text, '\n') {
+				return nil, errors.New("value cannot be written on a single line")
+			}
+
+			return text, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("unsupported value type: %s", GetTypeName(value))
+	}
+}
@@ -0,0 +1,40 @@
+package ard
+
+import (
+	"testing"
+)
+
+func TestEditYAMLInPlaceNodeProperties(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		value    Value
+		expected string
+	}{
+		{"a: &q x # c\nb: *q\n", "y", "a: &q y # c\nb: *q\n"},
+		{"a: !!str x\n", "y", "a: !!str y\n"},
+		{"a: !!str x\n", "true", "a: !!str \"true\"\n"},
+		{"a: !env 'x'\n", "y", "a: !env 'y'\n"},
+		{"a: &q !!int 5\n", 6, "a: &q !!int 6\n"},
+		{"a: !!int &q 5\n", 6, "a: !!int &q 6\n"},
+		{"a: &q # c\n  \"x\"\n", "y", "a: &q # c\n  \"y\"\n"},
+	} {
+		if result, err := EditYAMLInPlace([]byte(test.source), []PathValueEdit{{Path{NewFieldPathElement("a")}, test.value}}); err != nil {
+			t.Errorf("%q: %s", test.source, err)
+		} else if string(result) != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.source, test.expected, result)
+		}
+	}
+}
+
+func TestEditYAMLInPlaceUnsupported(t *testing.T) {
+	for _, source := range []string{
+		"a: !!int 5\n",
+		"a:\nb: 1\n",
+		"a: &q\nb: 1\n",
+		"a: x\n  y\n",
+	} {
+		if result, err := EditYAMLInPlace([]byte(source), []PathValueEdit{{Path{NewFieldPathElement("a")}, "z"}}); err == nil {
+			t.Errorf("%q: expected error, got %q", source, result)
+		}
+	}
+}
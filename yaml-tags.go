@@ -0,0 +1,359 @@
+package ard
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tliron/yamlkeys"
+	"gopkg.in/yaml.v3"
+)
+
+//
+// TaggedValue
+//
+
+// A value with an application-specific YAML tag, e.g. "!secret" or
+// "tag:example.com,2000:vault", that is otherwise not supported by ARD. See
+// [YAMLDecodeOptions].KeepTags.
+//
+// TaggedValue is not an ARD value. It is supported by [Copy] (which copies its
+// Value) and by the YAML encoders, which re-emit the tag. [ValidCopy] replaces
+// it with a copy of its Value, discarding the tag, thus to encode tagged values
+// to other formats call [ValidCopy] first. Other encoders will otherwise treat
+// it as a Go struct.
+type TaggedValue struct {
+	Tag   string
+	Value Value
+}
+
+// ([yaml.Marshaler] interface)
+func (self *TaggedValue) MarshalYAML() (any, error) {
+	if node, ok := ToYAMLNode(self, false); ok {
+		return node, nil
+	} else {
+		return nil, fmt.Errorf("unsupported value type: %T", self.Value)
+	}
+}
+
+// ([fmt.Stringer] interface)
+func (self *TaggedValue) String() string {
+	return fmt.Sprintf("%s %s", self.Tag, ValueToString(self.Value))
+}
+
+//
+// TagHandlers
+//
+
+// Handles the value of a [TaggedValue], e.g. by reading a file for "!include"
+// or fetching a secret for "!vault". The value has already been resolved.
+type TagHandler func(value Value) (Value, error)
+
+// Maps tags to their handlers.
+type TagHandlers map[string]TagHandler
+
+// Replaces all [TaggedValue] in the value, recursively, with the results of
+// their tags' handlers. Nested tagged values are handled first, thus
+// handlers never see them.
+//
+// Tagged values without a handler are left as is, unless strict is true, in
+// which case they are errors.
+//
+// Replacement happens in place when possible. The root value is returned,
+// which is only different from the argument if it itself is a [TaggedValue].
+func (self TagHandlers) Resolve(value Value, strict bool) (Value, error) {
+	return self.resolve(value, nil, strict)
+}
+
+func (self TagHandlers) resolve(value Value, path Path, strict bool) (Value, error) {
+	var err error
+	switch value_ := value.(type) {
+	case Map:
+		for key, element := range value_ {
+			if value_[key], err = self.resolve(element, path.AppendKey(key), strict); err != nil {
+				return nil, err
+			}
+		}
+
+	case StringMap:
+		for key, element := range value_ {
+			if value_[key], err = self.resolve(element, path.AppendField(key), strict); err != nil {
+				return nil, err
+			}
+		}
+
+	case List:
+		for index, element := range value_ {
+			if value_[index], err = self.resolve(element, path.AppendList(index), strict); err != nil {
+				return nil, err
+			}
+		}
+
+	case *TaggedValue:
+		if value_.Value, err = self.resolve(value_.Value, path, strict); err != nil {
+			return nil, err
+		}
+
+		if handler, ok := self[value_.Tag]; ok {
+			if value__, err := handler(value_.Value); err == nil {
+				return value__, nil
+			} else {
+				return nil, &PathError{path, fmt.Errorf("%s %s: %w", path.String(), value_.Tag, err)}
+			}
+		} else if strict {
+			return nil, newPathErrorf(path, "has unsupported tag: %s", value_.Tag)
+		}
+	}
+
+	return value, nil
+}
+
+//
+// YAMLDecodeOptions
+//
+
+type YAMLDecodeOptions struct {
+	// When true, nodes with tags that are not in the YAML core schema (i.e.
+	// not "!!" tags) are decoded as [TaggedValue] instead of having their tags
+	// discarded. Tags on map keys are always discarded.
+	KeepTags bool
+}
+
+// Utils
+
+type yamlNodeDecoder struct {
+	options *YAMLDecodeOptions
+
+	// When not nil, localized errors are recorded instead of returned (see
+	// [DecodeTolerant])
+	diagnostics *Diagnostics
+
+	// Nodes that are, or contain, nodes that need our handling; all other
+	// nodes are decoded by yamlkeys
+	custom map[*yaml.Node]bool
+}
+
+func (self *yamlNodeDecoder) decode(node *yaml.Node) (Value, error) {
+	if self.diagnostics == nil {
+		// When tolerant we must handle all nodes
+		self.custom = make(map[*yaml.Node]bool)
+		if !self.mark(node) {
+			return yamlkeys.DecodeNode(node)
+		}
+	} else {
+		self.custom = nil
+	}
+
+	return self.decodeAt(node, nil)
+}
+
+func (self *yamlNodeDecoder) decodeAt(node *yaml.Node, path Path) (Value, error) {
+	if (self.custom != nil) && !self.custom[node] {
+		return yamlkeys.DecodeNode(node)
+	}
+
+	if isCustomYAMLTag(node.Tag) {
+		if value, err := self.decodeContent(node, path); err == nil {
+			if codec, ok := getScalarCodecByTag(node.Tag); ok {
+				if value_, err := codec.decode(value); err == nil {
+					return value_, nil
+				} else {
+					return self.invalid(node, path, fmt.Errorf("malformed YAML @%d,%d: %s", node.Line, node.Column, err.Error()))
+				}
+			} else if self.options.KeepTags {
+				return &TaggedValue{node.Tag, value}, nil
+			} else {
+				return value, nil
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return self.decodeContent(node, path)
+}
+
+// Decodes the node as if it were untagged; its descendants keep their tags
+func (self *yamlNodeDecoder) decodeContent(node *yaml.Node, path Path) (Value, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return self.decodeAt(node.Alias, path)
+
+	case yaml.DocumentNode:
+		if len(node.Content) != 1 {
+			return nil, fmt.Errorf("malformed YAML @%d,%d: document content count is %d", node.Line, node.Column, len(node.Content))
+		}
+
+		return self.decodeAt(node.Content[0], path)
+
+	case yaml.MappingNode:
+		map_ := make(Map)
+		mergeMap := make(Map)
+
+		// Content is a slice of pairs of key followed by value
+		length := len(node.Content)
+		if length%2 != 0 {
+			return nil, fmt.Errorf("malformed YAML @%d,%d: map is not a list of key-value pairs", node.Line, node.Column)
+		}
+
+		for index := 0; index < length; index += 2 {
+			keyNode := node.Content[index]
+			valueNode := node.Content[index+1]
+
+			if (keyNode.Kind == yaml.ScalarNode) && (keyNode.Tag == "!!merge") {
+				// See: https://yaml.org/type/merge.html
+				value, err := self.decodeAt(valueNode, path)
+				if err != nil {
+					return nil, err
+				}
+
+				switch value_ := value.(type) {
+				case Map:
+					yamlkeys.MapMerge(mergeMap, value_, false)
+
+				case List:
+					for _, element := range value_ {
+						if map__, ok := element.(Map); ok {
+							yamlkeys.MapMerge(mergeMap, map__, false)
+						} else if err := self.tolerate(keyNode, path, fmt.Errorf("malformed YAML @%d,%d: merge", node.Line, node.Column)); err != nil {
+							return nil, err
+						}
+					}
+
+				default:
+					if err := self.tolerate(keyNode, path, fmt.Errorf("malformed YAML @%d,%d: merge", node.Line, node.Column)); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+
+			key, keyData, err := yamlkeys.DecodeKeyNode(keyNode)
+			if err != nil {
+				if err := self.tolerate(keyNode, path, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			// Check for duplicate keys
+			if keyData == nil {
+				keyData = key
+			}
+			if _, duplicate := yamlkeys.MapGet(map_, keyData); duplicate {
+				// The first entry is kept
+				if err := self.tolerate(keyNode, path, yamlkeys.NewDuplicateKeyErrorFor(key, keyNode)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if map_[key], err = self.decodeAt(valueNode, path.AppendKey(key)); err != nil {
+				return nil, err
+			}
+		}
+
+		yamlkeys.MapMerge(map_, mergeMap, false)
+
+		return map_, nil
+
+	case yaml.SequenceNode:
+		list := make(List, len(node.Content))
+		for index, childNode := range node.Content {
+			var err error
+			if list[index], err = self.decodeAt(childNode, path.AppendList(index)); err != nil {
+				return nil, err
+			}
+		}
+
+		return list, nil
+
+	default:
+		if (node.Kind == yaml.ScalarNode) && (node.Tag == "!!binary") && hasCustomYAMLBinary() {
+			// The YAML decoder would only decode it from standard base64;
+			// note that line breaks are allowed
+			if bytes, err := decodeTextBytes(strings.Join(strings.Fields(node.Value), "")); err == nil {
+				return bytes, nil
+			} else {
+				return self.invalid(node, path, fmt.Errorf("malformed YAML @%d,%d: !!binary: %s", node.Line, node.Column, err.Error()))
+			}
+		}
+
+		if isCustomYAMLTag(node.Tag) {
+			// Scalars have no descendants, so we can decode a copy
+			node_ := *node
+			node_.Tag = ""
+			node = &node_
+		}
+
+		if value, err := yamlkeys.DecodeNode(node); err == nil {
+			return value, nil
+		} else {
+			return self.invalid(node, path, err)
+		}
+	}
+}
+
+// When tolerant, records the error and returns an [*InvalidValue] in its
+// stead
+func (self *yamlNodeDecoder) invalid(node *yaml.Node, path Path, err error) (Value, error) {
+	if self.diagnostics == nil {
+		return nil, err
+	}
+
+	self.diagnostics.addError(path, node.Line, node.Column, err)
+	return &InvalidValue{node.Value, err}, nil
+}
+
+// When tolerant, records the error and returns nil, otherwise returns the
+// error
+func (self *yamlNodeDecoder) tolerate(node *yaml.Node, path Path, err error) error {
+	if self.diagnostics == nil {
+		return err
+	}
+
+	self.diagnostics.addError(path, node.Line, node.Column, err)
+	return nil
+}
+
+// Marks the node and its descendants in custom, returning true if the node
+// needs our handling
+func (self *yamlNodeDecoder) mark(node *yaml.Node) bool {
+	if custom, ok := self.custom[node]; ok {
+		return custom
+	}
+
+	// Guard against circular aliases
+	self.custom[node] = false
+
+	custom := false
+	if isCustomYAMLTag(node.Tag) {
+		custom = self.options.KeepTags || hasScalarCodecs()
+	} else if (node.Kind == yaml.ScalarNode) && (node.Tag == "!!binary") {
+		custom = hasCustomYAMLBinary()
+	}
+
+	if node.Alias != nil {
+		if self.mark(node.Alias) {
+			custom = true
+		}
+	}
+
+	// All descendants must be marked
+	for _, child := range node.Content {
+		if self.mark(child) {
+			custom = true
+		}
+	}
+
+	self.custom[node] = custom
+	return custom
+}
+
+// With the standard encoding "!!binary" is left to the YAML decoder
+func hasCustomYAMLBinary() bool {
+	return DefaultBytesEncoding() != Base64BytesEncoding
+}
+
+func isCustomYAMLTag(tag string) bool {
+	return (tag != "") && (tag != "!") && !strings.HasPrefix(tag, "!!")
+}
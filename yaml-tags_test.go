@@ -0,0 +1,72 @@
+package ard
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLKeepTagsNested(t *testing.T) {
+	value, _, err := DecodeYAMLWithOptions([]byte("a: !foo {b: !bar x, c: y}\nd: !baz [!qux 1, 2]\n"), false, &YAMLDecodeOptions{KeepTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	map_ := value.(Map)
+
+	a, ok := map_["a"].(*TaggedValue)
+	if !ok || (a.Tag != "!foo") {
+		t.Fatalf("a: expected !foo tagged value, got %#v", map_["a"])
+	}
+	if b, ok := a.Value.(Map)["b"].(*TaggedValue); !ok || (b.Tag != "!bar") || (b.Value != "x") {
+		t.Errorf("a.b: expected !bar tagged value, got %#v", a.Value.(Map)["b"])
+	}
+	if c := a.Value.(Map)["c"]; c != "y" {
+		t.Errorf("a.c: expected \"y\", got %#v", c)
+	}
+
+	d, ok := map_["d"].(*TaggedValue)
+	if !ok || (d.Tag != "!baz") {
+		t.Fatalf("d: expected !baz tagged value, got %#v", map_["d"])
+	}
+	if element, ok := d.Value.(List)[0].(*TaggedValue); !ok || (element.Tag != "!qux") || (element.Value != 1) {
+		t.Errorf("d[0]: expected !qux tagged value, got %#v", d.Value.(List)[0])
+	}
+}
+
+func TestYAMLKeepTagsRoundtrip(t *testing.T) {
+	code := []byte("a: !foo {b: !bar x}\nc: !baz y\n")
+	value, _, err := DecodeYAMLWithOptions(code, false, &YAMLDecodeOptions{KeepTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteYAML(value, &buffer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"!foo", "!bar", "!baz"} {
+		if !bytes.Contains(buffer.Bytes(), []byte(tag)) {
+			t.Errorf("tag %s not re-emitted in:\n%s", tag, buffer.String())
+		}
+	}
+
+	value_, _, err := DecodeYAMLWithOptions(buffer.Bytes(), false, &YAMLDecodeOptions{KeepTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, value_) {
+		t.Errorf("expected %s, got %s", ValueToString(value), ValueToString(value_))
+	}
+}
+
+func TestValidCopyUnwrapsTaggedValues(t *testing.T) {
+	value, err := ValidCopy(Map{"a": &TaggedValue{"!foo", List{&TaggedValue{"!bar", "x"}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Map{"a": List{"x"}}); !Equals(value, expected) {
+		t.Errorf("expected %s, got %s", ValueToString(expected), ValueToString(value))
+	}
+}
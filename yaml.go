@@ -1,17 +1,85 @@
 package ard
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
-	"github.com/tliron/kutil/util"
 	"gopkg.in/yaml.v3"
 )
 
+//
+// YAMLNodeOptions
+//
+
+type YAMLNodeOptions struct {
+	// When true, all scalar nodes will have the tagged style.
+	Verbose bool
+
+	// When true, map keys will be sorted according to [Compare]. Thus numeric
+	// keys will be in numeric order rather than lexical order.
+	SortKeys bool
+
+	// Overrides the style of nodes at specific paths. Keys are path patterns
+	// in [Path.String] format, in which "*" can be used as a wildcard for a
+	// whole path element, e.g. "services.*.script" or "steps[*].run". This can
+	// be used to produce idiomatic YAML for well-known fields, e.g. to force
+	// [yaml.LiteralStyle] for scripts or [yaml.FlowStyle] for short lists.
+	//
+	// When more than one pattern matches a path, the lexically first pattern
+	// is used. Styles apply only to values, not to map keys.
+	Styles map[string]yaml.Style
+
+	// Format for floats as in [strconv.FormatFloat], e.g. 'f' to avoid
+	// scientific notation. If 0 then 'g' will be used with the smallest
+	// precision necessary, and FloatPrecision is ignored. Floats are always
+	// tagged as "!!float", so they remain floats even if formatted without a
+	// decimal point. See also [ValueToStringOptions].
+	FloatFormat byte
+
+	// Precision for floats as in [strconv.FormatFloat]. 0 (the default) and -1
+	// mean the smallest precision necessary, so that values are never rounded
+	// unless explicitly requested. Ignored if FloatFormat is 0.
+	FloatPrecision int
+
+	// When true, strings (including map keys) will have the plain style,
+	// leaving it to the YAML encoder to quote them only when necessary, e.g.
+	// for "true" or "1". When false, strings are always double-quoted.
+	PlainStrings bool
+}
+
+func (self *YAMLNodeOptions) formatFloat(value float64, bitSize int) string {
+	if self.FloatFormat == 0 {
+		return fixFloat(strconv.FormatFloat(value, 'g', -1, bitSize))
+	} else {
+		return fixFloat(strconv.FormatFloat(value, self.FloatFormat, floatPrecision(self.FloatPrecision), bitSize))
+	}
+}
+
+// Calls [ToYAMLDocumentNodeWithOptions] with the verbose option.
 func ToYAMLDocumentNode(value Value, verbose bool, reflector *Reflector) (*yaml.Node, error) {
-	if value_, err := ValidCopy(value, reflector); err == nil {
-		if node, ok := ToYAMLNode(value_, verbose); ok {
+	return ToYAMLDocumentNodeWithOptions(value, &YAMLNodeOptions{Verbose: verbose}, reflector)
+}
+
+// Converts an ARD [Value] to a YAML document node. The value is first
+// canonicalized via [ValidCopy], except that [TaggedValue] is kept so that its
+// tag is re-emitted.
+//
+// The options argument can be nil, in which case default options will be used.
+//
+// The reflector argument can be nil, in which case a default reflector will be
+// used.
+func ToYAMLDocumentNodeWithOptions(value Value, options *YAMLNodeOptions, reflector *Reflector) (*yaml.Node, error) {
+	if reflector == nil {
+		reflector = DefaultReflector("yaml")
+	}
+
+	// YAML can re-emit the tags
+	if value_, err := validCopy(value, reflector, noConversion, true, nil); err == nil {
+		if node, ok := ToYAMLNodeWithOptions(value_, options); ok {
 			return &yaml.Node{
 				Kind:    yaml.DocumentNode,
 				Content: []*yaml.Node{node},
@@ -24,46 +92,82 @@ func ToYAMLDocumentNode(value Value, verbose bool, reflector *Reflector) (*yaml.
 	}
 }
 
+// Calls [ToYAMLNodeWithOptions] with the verbose option.
 func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
+	return ToYAMLNodeWithOptions(value, &YAMLNodeOptions{Verbose: verbose})
+}
+
+// Converts an ARD [Value] to a YAML node. Returns false if the value or
+// any of its nested values is not ARD.
+//
+// The options argument can be nil, in which case default options will be used.
+func ToYAMLNodeWithOptions(value Value, options *YAMLNodeOptions) (*yaml.Node, bool) {
+	if options == nil {
+		options = new(YAMLNodeOptions)
+	}
+
+	encoder := yamlNodeEncoder{options: options}
+
+	if len(options.Styles) > 0 {
+		patterns := make([]string, 0, len(options.Styles))
+		for pattern := range options.Styles {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			if pattern_, err := compilePathPattern(pattern); err == nil {
+				encoder.styles = append(encoder.styles, yamlPathStyle{pattern_, options.Styles[pattern]})
+			} else {
+				return nil, false
+			}
+		}
+	}
+
+	return encoder.encode(value, nil)
+}
+
+type yamlNodeEncoder struct {
+	options *YAMLNodeOptions
+	styles  []yamlPathStyle
+}
+
+type yamlPathStyle struct {
+	pattern *regexp.Regexp
+	style   yaml.Style
+}
+
+// Path is only tracked if we have styles
+func (self *yamlNodeEncoder) encode(value Value, path Path) (*yaml.Node, bool) {
+	options := self.options
+
 	var node yaml.Node
-	if verbose {
+	if options.Verbose {
 		node.Style = yaml.TaggedStyle
 	}
 
 	switch value_ := value.(type) {
 	// Failsafe schema: https://yaml.org/spec/1.2/spec.html#id2802346
 
-	case Map:
+	case Map, StringMap:
 		node.Kind = yaml.MappingNode
 		node.Tag = "!!map"
 		node.Style = 0
-		node.Content = make([]*yaml.Node, len(value_)*2)
-		index := 0
-		for k, v := range value_ {
-			var ok bool
-			if node.Content[index], ok = ToYAMLNode(k, verbose); ok {
-				index += 1
-				if node.Content[index], ok = ToYAMLNode(v, verbose); ok {
-					index += 1
-				} else {
-					return nil, false
-				}
-			} else {
-				return nil, false
-			}
+
+		var entries []compareEntry
+		if options.SortKeys {
+			entries = sortedCompareEntries(value_)
+		} else {
+			entries = compareEntries(value_)
 		}
 
-	case StringMap:
-		node.Kind = yaml.MappingNode
-		node.Tag = "!!map"
-		node.Style = 0
-		node.Content = make([]*yaml.Node, len(value_)*2)
+		node.Content = make([]*yaml.Node, len(entries)*2)
 		index := 0
-		for k, v := range value_ {
+		for _, entry := range entries {
 			var ok bool
-			if node.Content[index], ok = ToYAMLNode(k, verbose); ok {
+			if node.Content[index], ok = self.encode(entry.key, nil); ok {
 				index += 1
-				if node.Content[index], ok = ToYAMLNode(v, verbose); ok {
+				if node.Content[index], ok = self.encode(entry.value, self.appendKey(path, entry.key)); ok {
 					index += 1
 				} else {
 					return nil, false
@@ -80,7 +184,7 @@ func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
 		node.Content = make([]*yaml.Node, len(value_))
 		for index, v := range value_ {
 			var ok bool
-			if node.Content[index], ok = ToYAMLNode(v, verbose); !ok {
+			if node.Content[index], ok = self.encode(v, self.appendIndex(path, index)); !ok {
 				return nil, false
 			}
 		}
@@ -88,7 +192,9 @@ func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
 	case string:
 		node.Kind = yaml.ScalarNode
 		node.Tag = "!!str"
-		node.Style |= yaml.DoubleQuotedStyle
+		if !options.PlainStrings {
+			node.Style |= yaml.DoubleQuotedStyle
+		}
 		node.Value = value_
 
 	// JSON schema: https://yaml.org/spec/1.2/spec.html#id2803231
@@ -155,12 +261,12 @@ func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
 	case float64:
 		node.Kind = yaml.ScalarNode
 		node.Tag = "!!float"
-		node.Value = fixFloat(strconv.FormatFloat(value_, 'g', -1, 64))
+		node.Value = options.formatFloat(value_, 64)
 
 	case float32:
 		node.Kind = yaml.ScalarNode
 		node.Tag = "!!float"
-		node.Value = fixFloat(strconv.FormatFloat(float64(value_), 'g', -1, 32))
+		node.Value = options.formatFloat(float64(value_), 32)
 
 	// Other schemas: https://yaml.org/spec/1.2/spec.html#id2805770
 
@@ -168,7 +274,7 @@ func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
 		// See: https://yaml.org/type/binary.html
 		node.Kind = yaml.ScalarNode
 		node.Tag = "!!binary"
-		node.Value = util.ToBase64(value_)
+		node.Value = encodeTextBytes(value_)
 
 	case time.Time:
 		// See: https://yaml.org/type/timestamp.html
@@ -176,13 +282,62 @@ func ToYAMLNode(value Value, verbose bool) (*yaml.Node, bool) {
 		node.Tag = "!!timestamp"
 		node.Value = value_.Format(time.RFC3339Nano)
 
+	case *TaggedValue:
+		if node_, ok := self.encode(value_.Value, path); ok {
+			node_.Tag = value_.Tag
+			return node_, true
+		} else {
+			return nil, false
+		}
+
 	default:
-		return nil, false
+		if codec, ok := getScalarCodec(value); ok {
+			node.Kind = yaml.ScalarNode
+			node.Tag = "!" + string(codec.TypeName)
+			node.Value = codec.ToString(value)
+		} else {
+			return nil, false
+		}
+	}
+
+	if path != nil {
+		self.applyStyle(&node, path)
 	}
 
 	return &node, true
 }
 
+func (self *yamlNodeEncoder) appendKey(path Path, key Value) Path {
+	if len(self.styles) > 0 {
+		return path.AppendKey(key)
+	} else {
+		return nil
+	}
+}
+
+func (self *yamlNodeEncoder) appendIndex(path Path, index int) Path {
+	if len(self.styles) > 0 {
+		return path.AppendList(index)
+	} else {
+		return nil
+	}
+}
+
+func (self *yamlNodeEncoder) applyStyle(node *yaml.Node, path Path) {
+	path_ := path.String()
+	for _, style := range self.styles {
+		if style.pattern.MatchString(path_) {
+			if node.Kind == yaml.ScalarNode {
+				// Keep tagged style
+				node.Style = style.style | (node.Style & yaml.TaggedStyle)
+			} else {
+				node.Style = style.style
+			}
+			return
+		}
+	}
+}
+
 func fixFloat(s string) string {
 	// See: https://yaml.org/spec/1.2/spec.html#id2804092
 	switch s {
@@ -192,6 +347,9 @@ func fixFloat(s string) string {
 		return "-.inf"
 	case "NaN":
 		return ".nan"
+	case "-0":
+		// Otherwise decoded as positive zero
+		return "-0.0"
 	}
 	return s
 }
@@ -213,10 +371,25 @@ func FindYAMLNode(node *yaml.Node, path ...PathElement) *yaml.Node {
 
 	case yaml.MappingNode:
 		pathElement := path[0]
+		var matches func(keyNode *yaml.Node) bool
 		switch pathElement.Type {
 		case FieldPathType, MapPathType:
 			value := pathElement.Value.(string)
+			matches = func(keyNode *yaml.Node) bool {
+				return (keyNode.Tag == "!!str") && (keyNode.Value == value)
+			}
+
+		case KeyPathType, ListPathType:
+			// Integer list indexes are also used as map keys
+			matches = func(keyNode *yaml.Node) bool {
+				if key, ok := decodeYAMLScalarKey(keyNode); ok {
+					return equalsNumerically(key, pathElement.Value)
+				}
+				return false
+			}
+		}
 
+		if matches != nil {
 			// Content is a slice of pairs of key-followed-by-value
 			length := len(node.Content)
 			for i := 0; i < length; i += 2 {
@@ -236,8 +409,8 @@ func FindYAMLNode(node *yaml.Node, path ...PathElement) *yaml.Node {
 					}
 				}
 
-				// We only support comparisons with string keys
-				if (keyNode.Kind == yaml.ScalarNode) && (keyNode.Tag == "!!str") && (keyNode.Value == value) {
+				// We only support comparisons with string, boolean, and number keys
+				if (keyNode.Kind == yaml.ScalarNode) && matches(keyNode) {
 					valueNode := node.Content[i+1]
 					foundNode := FindYAMLNode(valueNode, path[1:]...)
 					if foundNode == valueNode {
@@ -274,3 +447,142 @@ func FindYAMLNode(node *yaml.Node, path ...PathElement) *yaml.Node {
 
 	return node
 }
+
+// Builds an index of all paths in a YAML node tree, mapping their [Path.String]
+// representation to their source positions. The tree is traversed only once, so
+// this is far more efficient than calling [FindYAMLNode] for every path in the
+// document.
+//
+// As with [FindYAMLNode], the position of a map value is that of its key. Merged
+// values (via "<<") are indexed, too, though explicit keys take precedence. Only
+// string, boolean, and number keys are supported.
+func BuildPathIndex(rootNode *yaml.Node) (map[string]Position, error) {
+	if rootNode == nil {
+		return nil, errors.New("no YAML node")
+	}
+
+	node := rootNode
+	if (node.Kind == yaml.DocumentNode) && (len(node.Content) > 0) {
+		// Length *should* be 1
+		node = node.Content[0]
+	}
+
+	index := map[string]Position{"": {node.Line, node.Column}}
+	if err := indexYAMLNode(index, node, nil, false); err == nil {
+		return index, nil
+	} else {
+		return nil, err
+	}
+}
+
+func indexYAMLNode(index map[string]Position, node *yaml.Node, path Path, merging bool) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			return indexYAMLNode(index, node.Alias, path, merging)
+		}
+
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			// Length *should* be 1
+			return indexYAMLNode(index, node.Content[0], path, merging)
+		}
+
+	case yaml.MappingNode:
+		// Content is a slice of pairs of key-followed-by-value
+		length := len(node.Content)
+		if length%2 != 0 {
+			return fmt.Errorf("malformed YAML map at %d:%d", node.Line, node.Column)
+		}
+
+		var mergeNodes []*yaml.Node
+		for i := 0; i < length; i += 2 {
+			keyNode := node.Content[i]
+			valueNode := node.Content[i+1]
+
+			if keyNode.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			switch keyNode.Tag {
+			case "!!merge":
+				// Merged values are handled after explicit keys, which take precedence
+				mergeNodes = append(mergeNodes, valueNode)
+
+			case "!!str", "!!int", "!!bool", "!!float":
+				var path_ Path
+				if keyNode.Tag == "!!str" {
+					path_ = path.AppendField(keyNode.Value)
+				} else if key, ok := decodeYAMLScalarKey(keyNode); ok {
+					path_ = path.AppendKey(key)
+				} else {
+					continue
+				}
+
+				key := path_.String()
+				if merging {
+					if _, ok := index[key]; ok {
+						continue
+					}
+				}
+
+				// We will use the key node for the location instead of the value node
+				index[key] = Position{keyNode.Line, keyNode.Column}
+				if err := indexYAMLNode(index, valueNode, path_, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, mergeNode := range mergeNodes {
+			if err := indexYAMLMerge(index, mergeNode, path); err != nil {
+				return err
+			}
+		}
+
+	case yaml.SequenceNode:
+		for i, childNode := range node.Content {
+			path_ := path.AppendList(i)
+			index[path_.String()] = Position{childNode.Line, childNode.Column}
+			if err := indexYAMLNode(index, childNode, path_, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Decodes boolean and number keys
+func decodeYAMLScalarKey(keyNode *yaml.Node) (Value, bool) {
+	switch keyNode.Tag {
+	case "!!int", "!!bool", "!!float":
+		var key Value
+		if err := keyNode.Decode(&key); err == nil {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func indexYAMLMerge(index map[string]Position, node *yaml.Node, path Path) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			return indexYAMLMerge(index, node.Alias, path)
+		}
+
+	case yaml.MappingNode:
+		return indexYAMLNode(index, node, path, true)
+
+	case yaml.SequenceNode:
+		// Earlier maps in the sequence take precedence
+		for _, childNode := range node.Content {
+			if err := indexYAMLMerge(index, childNode, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}